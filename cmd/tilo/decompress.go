@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var magics = []struct {
+	name  string
+	magic []byte
+}{
+	{"gzip", []byte{0x1f, 0x8b}},
+	{"bzip2", []byte("BZh")},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// compression names the format whose magic bytes start head, or returns ""
+// for plain text.
+func compression(head []byte) string {
+	for _, m := range magics {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.name
+		}
+	}
+	return ""
+}
+
+// fileCompression reports the compression format of file from its first
+// bytes, without moving its offset.
+func fileCompression(file *os.File) string {
+	head := make([]byte, 6)
+	n, _ := file.ReadAt(head, 0)
+	return compression(head[:n])
+}
+
+// decompress sniffs the first bytes of r and returns a reader that yields
+// the decompressed stream, or r's own bytes if it is not compressed. Close
+// releases the decompressor (the zstd one keeps goroutines running) but
+// leaves r open.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(6)
+	switch compression(head) {
+	case "gzip":
+		return gzip.NewReader(br)
+	case "bzip2":
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	case "xz":
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case "zstd":
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return io.NopCloser(br), nil
+}
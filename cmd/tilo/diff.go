@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"tilo/internal/color"
+	"tilo/internal/diff"
+)
+
+// diffMarkers prefix every row of a diff: unchanged, removed, added and
+// changed lines.
+var diffMarkers = map[diff.Op]string{
+	diff.Equal:  "  ",
+	diff.Delete: "- ",
+	diff.Insert: "+ ",
+	diff.Change: "~ ",
+}
+
+func diffRules() []color.Rule {
+	rule := func(name, pattern, colorName string) color.Rule {
+		return color.Rule{Name: name, Regex: regexp.MustCompile(pattern), Color: colorName, Style: "bold", Enabled: true}
+	}
+	return []color.Rule{
+		rule("diff_delete", `^- `, "red"),
+		rule("diff_insert", `^\+ `, "green"),
+		rule("diff_change", `^~ `, "yellow"),
+	}
+}
+
+func readDiff(args []string, side bool, exact bool) ([]string, error) {
+	if len(args) != 2 {
+		return nil, errors.New("usage: tilo diff [--side] [--exact] a.log b.log")
+	}
+	var inputs [2][]string
+	for i, path := range args {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i], err = readLines(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	rows := diff.Lines(inputs[0], inputs[1], exact)
+	if side {
+		return sideBySide(rows), nil
+	}
+	var out, added []string
+	for _, r := range rows {
+		if r.Op != diff.Change && len(added) > 0 {
+			out = append(out, added...)
+			added = nil
+		}
+		switch r.Op {
+		case diff.Equal, diff.Delete:
+			out = append(out, diffMarkers[r.Op]+r.A)
+		case diff.Insert:
+			out = append(out, diffMarkers[r.Op]+r.B)
+		case diff.Change:
+			// Show a block of changed lines as all removals, then all
+			// additions, like a unified diff.
+			out = append(out, diffMarkers[diff.Delete]+r.A)
+			added = append(added, diffMarkers[diff.Insert]+r.B)
+		}
+	}
+	return append(out, added...), nil
+}
+
+// sideBySide puts both inputs in columns split at half the terminal width.
+func sideBySide(rows []diff.Line) []string {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 40 {
+		width = 160
+	}
+	// Leave room for the marker, the separator and the viewer's line numbers.
+	half := (width - 4 - 8) / 2
+	out := make([]string, len(rows))
+	for i, r := range rows {
+		out[i] = diffMarkers[r.Op] + fitColumn(r.A, half) + " │ " + r.B
+	}
+	return out
+}
+
+func fitColumn(s string, width int) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirFiles lists the regular, non-hidden files in dir by name.
+func dirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// readDir merges the files in dir by timestamp, like --merge. With follow
+// it tails them and also picks up files created in dir later, such as
+// date-rotated logs, reading those from their start.
+func readDir(dir string, follow bool, layouts []string) ([]string, <-chan []string, []string, error) {
+	paths, err := dirFiles(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	labels := mergeLabels(paths)
+	lines, err := mergeFiles(paths, labels, layouts)
+	if err != nil || !follow {
+		return lines, nil, labels, err
+	}
+	out := make(chan []string, 16)
+	seen := map[string]bool{}
+	for i, path := range paths {
+		seen[path] = true
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if fileCompression(file) != "" {
+			_ = file.Close()
+			continue
+		}
+		if _, err := file.Seek(0, 2); err != nil {
+			_ = file.Close()
+			return nil, nil, nil, err
+		}
+		go tailLabeled(file, labels[i], out)
+	}
+	go watchDir(dir, seen, out)
+	return lines, out, labels, nil
+}
+
+// watchDir waits for files to appear in dir and tails each one it finds.
+func watchDir(dir string, seen map[string]bool, out chan<- []string) {
+	watcher := watchPath(dir)
+	defer watcher.close()
+	for {
+		watcher.wait()
+		paths, err := dirFiles(dir)
+		if err != nil {
+			continue
+		}
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			seen[path] = true
+			if fileCompression(file) != "" {
+				_ = file.Close()
+				continue
+			}
+			go tailLabeled(file, "["+filepath.Base(path)+"] ", out)
+		}
+	}
+}
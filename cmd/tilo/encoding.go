@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// inputEncoding is the --encoding flag: "auto" or one of the encodings
+// below.
+var inputEncoding = "auto"
+
+var encodingNames = map[string]string{
+	"auto":         "auto",
+	"utf-8":        "utf-8",
+	"utf8":         "utf-8",
+	"utf-16le":     "utf-16le",
+	"utf-16be":     "utf-16be",
+	"latin1":       "latin1",
+	"latin-1":      "latin1",
+	"iso-8859-1":   "latin1",
+	"windows-1252": "windows-1252",
+	"cp1252":       "windows-1252",
+}
+
+func parseEncoding(name string) (string, error) {
+	enc, ok := encodingNames[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unknown encoding %q (use utf-8, utf-16le, utf-16be, latin1 or windows-1252)", name)
+	}
+	return enc, nil
+}
+
+// detectEncoding guesses the encoding of head, the first bytes of the
+// input, and returns the length of its byte order mark.
+func detectEncoding(head []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8", 3
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return "utf-16le", 2
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return "utf-16be", 2
+	}
+	// ASCII text in UTF-16 has a NUL in every other byte.
+	if len(head) >= 4 {
+		even, odd := 0, 0
+		for i := 0; i+1 < len(head); i += 2 {
+			if head[i] == 0 {
+				even++
+			}
+			if head[i+1] == 0 {
+				odd++
+			}
+		}
+		pairs := len(head) / 2
+		switch {
+		case odd*10 > pairs*4 && even*10 < pairs:
+			return "utf-16le", 0
+		case even*10 > pairs*4 && odd*10 < pairs:
+			return "utf-16be", 0
+		}
+	}
+	// Ignore a rune cut off at the end of head.
+	for cut := 0; cut < utf8.UTFMax && cut < len(head); cut++ {
+		if utf8.Valid(head[:len(head)-cut]) {
+			return "utf-8", 0
+		}
+	}
+	return "windows-1252", 0
+}
+
+// decodeInput converts r to UTF-8, detecting its encoding from the first
+// bytes that arrive unless --encoding names one.
+func decodeInput(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	enc := inputEncoding
+	// Look at what the first read brought without waiting for more, so
+	// streams are not held up.
+	_, _ = br.Peek(2)
+	head, _ := br.Peek(br.Buffered())
+	detected, bom := detectEncoding(head)
+	if enc == "auto" {
+		enc = detected
+	}
+	if bom > 0 && enc == detected {
+		_, _ = br.Discard(bom)
+	}
+	switch enc {
+	case "utf-16le":
+		return &utf16Reader{src: br}, nil
+	case "utf-16be":
+		return &utf16Reader{src: br, bigEndian: true}, nil
+	case "latin1":
+		return &charmapReader{src: br}, nil
+	case "windows-1252":
+		return &charmapReader{src: br, table: &cp1252}, nil
+	}
+	return br, nil
+}
+
+// utf16Reader converts UTF-16 to UTF-8.
+type utf16Reader struct {
+	src       io.Reader
+	bigEndian bool
+	in        []byte
+	out       []byte
+	err       error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		buf := make([]byte, 32*1024)
+		n, err := u.src.Read(buf)
+		u.in = append(u.in, buf[:n]...)
+		u.err = err
+		u.decode()
+	}
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+func (u *utf16Reader) decode() {
+	unit := func(i int) uint16 {
+		if u.bigEndian {
+			return uint16(u.in[i])<<8 | uint16(u.in[i+1])
+		}
+		return uint16(u.in[i+1])<<8 | uint16(u.in[i])
+	}
+	i := 0
+	for ; i+1 < len(u.in); i += 2 {
+		r := rune(unit(i))
+		if utf16.IsSurrogate(r) {
+			if i+3 >= len(u.in) {
+				if u.err == nil {
+					// Wait for the second half of the pair.
+					break
+				}
+				r = utf8.RuneError
+			} else {
+				r = utf16.DecodeRune(r, rune(unit(i+2)))
+				if r != utf8.RuneError {
+					i += 2
+				}
+			}
+		}
+		u.out = utf8.AppendRune(u.out, r)
+	}
+	u.in = append(u.in[:0], u.in[i:]...)
+}
+
+// charmapReader converts a single-byte encoding to UTF-8. Bytes from 0x80
+// to 0x9f come from table when set; every other byte is its own code point,
+// as in Latin-1.
+type charmapReader struct {
+	src   io.Reader
+	table *[32]rune
+	buf   []byte
+	out   []byte
+}
+
+func (c *charmapReader) Read(p []byte) (int, error) {
+	if len(c.out) == 0 {
+		if c.buf == nil {
+			c.buf = make([]byte, 32*1024)
+		}
+		n, err := c.src.Read(c.buf)
+		for _, b := range c.buf[:n] {
+			r := rune(b)
+			if c.table != nil && b >= 0x80 && b < 0xa0 {
+				r = c.table[b-0x80]
+			}
+			c.out = utf8.AppendRune(c.out, r)
+		}
+		if len(c.out) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// cp1252 maps Windows-1252 bytes 0x80-0x9f; unassigned bytes keep their
+// Latin-1 meaning.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
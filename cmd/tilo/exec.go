@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// children are the stop functions of the commands left running to follow
+// an input, such as the ssh that tails a remote file. They are called when
+// tilo quits, whichever way it does.
+var children struct {
+	sync.Mutex
+	stops []func()
+}
+
+// keepChild kills cmd when tilo quits.
+func keepChild(cmd *exec.Cmd) {
+	keepStop(func() { _ = cmd.Process.Kill() })
+}
+
+func keepStop(stop func()) {
+	children.Lock()
+	defer children.Unlock()
+	children.stops = append(children.stops, stop)
+}
+
+func stopChildren() {
+	children.Lock()
+	defer children.Unlock()
+	for _, stop := range children.stops {
+		stop()
+	}
+	children.stops = nil
+}
+
+// exit stops the children and exits with code.
+func exit(code int) {
+	stopChildren()
+	os.Exit(code)
+}
+
+// stopChildrenOnSignal stops the children when tilo is hung up on,
+// terminated or interrupted, then lets the signal end tilo as it would
+// have. A command from runCommand has a process group of its own, so the
+// terminal's SIGHUP never reaches it.
+func stopChildrenOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		stopChildren()
+		signal.Reset(sig)
+		_ = syscall.Kill(os.Getpid(), sig.(syscall.Signal))
+	}()
+}
+
+// isCommand reports whether name is a program that -- can run rather than
+// a file to view.
+func isCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// runCommand starts argv with stdout and stderr going to one pipe and
+// streams its output. The command and anything it started are killed when
+// tilo quits first.
+func runCommand(argv []string) (<-chan []string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		_ = r.Close()
+		_ = w.Close()
+		return nil, err
+	}
+	// Only the child holds the write end now, so the stream ends with it.
+	_ = w.Close()
+	out := make(chan []string, 16)
+	go func() {
+		defer close(out)
+		for batch := range streamLines(r) {
+			out <- batch
+		}
+		status := "exited"
+		if err := cmd.Wait(); err != nil {
+			status = err.Error()
+		}
+		out <- []string{rotationMarker(argv[0], status)}
+	}()
+	keepStop(func() {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	})
+	return out, nil
+}
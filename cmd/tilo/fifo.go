@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const fifoPoll = 200 * time.Millisecond
+
+// isFIFO reports whether path is a named pipe.
+func isFIFO(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeNamedPipe != 0
+}
+
+// readFIFO follows a named pipe. It is opened without blocking so tilo starts
+// before any writer shows up, and reading carries on when a writer closes
+// the pipe and another one opens it. While no writer is connected the
+// returned notes say so.
+func readFIFO(path string) (<-chan []string, <-chan string, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := make(chan []string, 16)
+	notes := make(chan string, 4)
+	go func() {
+		defer close(out)
+		defer close(notes)
+		defer file.Close()
+		reader := bufio.NewReader(file)
+		waiting := true
+		notes <- "waiting for writer"
+		pending := ""
+		var batch []string
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" && waiting {
+				waiting = false
+				notes <- ""
+			}
+			if strings.HasSuffix(line, "\n") {
+				line = strings.TrimSuffix(pending+line, "\n")
+				batch = append(batch, strings.TrimSuffix(line, "\r"))
+				pending = ""
+			} else {
+				pending += line
+			}
+			if len(batch) > 0 && (err != nil || reader.Buffered() == 0 || len(batch) >= 1024) {
+				out <- batch
+				batch = nil
+			}
+			if err == io.EOF {
+				// The writer went away; a partial last line is complete.
+				if pending != "" {
+					out <- []string{strings.TrimSuffix(pending, "\r")}
+					pending = ""
+				}
+				if !waiting {
+					waiting = true
+					notes <- "waiting for writer"
+				}
+				time.Sleep(fifoPoll)
+			} else if err != nil {
+				return
+			}
+		}
+	}()
+	return out, notes, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	httpPollInterval = 2 * time.Second
+	// httpRecheck is how many 416s in a row, from a server that does not
+	// say how long the file is, make tilo fetch it whole to check whether
+	// it was truncated.
+	httpRecheck = 5
+)
+
+// httpClient gives up on a server that does not connect or answer, but not
+// on a large body that is still arriving.
+var httpClient = &http.Client{Transport: &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+}}
+
+// fetch gets url from byte offset on. It returns the body and whether the
+// server honored the range. A 416 returns no body and the length of the
+// file from its Content-Range, or -1 when the server left it out.
+func fetch(url string, offset int) ([]byte, bool, int, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, 0, err
+	}
+	// Ask for the raw bytes so offsets match between requests.
+	req.Header.Set("Accept-Encoding", "identity")
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.Itoa(offset)+"-")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, false, 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		return body, false, 0, err
+	case http.StatusPartialContent:
+		body, err := io.ReadAll(resp.Body)
+		return body, true, 0, err
+	case http.StatusRequestedRangeNotSatisfiable:
+		size := -1
+		if total, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes */"); ok {
+			if n, err := strconv.Atoi(total); err == nil {
+				size = n
+			}
+		}
+		return nil, true, size, nil
+	}
+	return nil, false, 0, fmt.Errorf("GET %s: %s", url, resp.Status)
+}
+
+// readHTTP fetches a remote log. With follow it polls the URL for new bytes
+// with range requests, falling back to refetching the whole file when the
+// server ignores ranges.
+func readHTTP(url string, follow bool) ([]string, <-chan []string, error) {
+	data, _, _, err := fetch(url, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !follow {
+		lines, err := readLines(bytes.NewReader(data))
+		return lines, nil, err
+	}
+	if format := compression(data); format != "" {
+		return nil, nil, fmt.Errorf("cannot follow a %s-compressed file", format)
+	}
+	// A last line still being written is held back until its newline
+	// arrives with a later fetch.
+	end := bytes.LastIndexByte(data, '\n') + 1
+	lines, err := readLines(bytes.NewReader(data[:end]))
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := make(chan []string, 16)
+	go pollHTTP(url, len(data), append([]byte(nil), data[end:]...), ch)
+	return lines, ch, nil
+}
+
+func pollHTTP(url string, offset int, partial []byte, out chan<- []string) {
+	misses := 0
+	for range time.Tick(httpPollInterval) {
+		body, ranged, size, err := fetch(url, offset)
+		if err != nil {
+			continue
+		}
+		if ranged && body == nil {
+			// Nothing past offset: the file has not grown, or it was
+			// truncated, which only a fetch of the whole file can tell
+			// when the server does not give its length.
+			if size < 0 {
+				if misses++; misses < httpRecheck {
+					continue
+				}
+			} else if size >= offset {
+				continue
+			}
+			misses = 0
+			if body, _, _, err = fetch(url, 0); err != nil {
+				continue
+			}
+			ranged = false
+		} else {
+			misses = 0
+		}
+		if !ranged {
+			if len(body) < offset {
+				// The file was replaced or truncated: start over.
+				offset, partial = 0, nil
+			}
+			body = body[offset:]
+		}
+		offset += len(body)
+		partial = append(partial, body...)
+		end := bytes.LastIndexByte(partial, '\n')
+		if end < 0 {
+			continue
+		}
+		text := strings.ReplaceAll(string(partial[:end]), "\r\n", "\n")
+		partial = append([]byte(nil), partial[end+1:]...)
+		out <- strings.Split(text, "\n")
+	}
+}
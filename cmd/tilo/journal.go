@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalLevels maps syslog priorities to the level names the level rules
+// detect.
+var journalLevels = []string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+
+// readJournal reads the journal of unit (all units when empty) through
+// journalctl. With follow, a second journalctl picks up after the last
+// entry read.
+func readJournal(unit string, follow bool) ([]string, <-chan []string, error) {
+	base := []string{"--output=json", "--no-pager"}
+	if unit != "" {
+		base = append(base, "--unit="+unit)
+	}
+	var stderr bytes.Buffer
+	read := exec.Command("journalctl", append(base, "--lines=all")...)
+	read.Stderr = &stderr
+	stdout, err := read.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := read.Start(); err != nil {
+		return nil, nil, fmt.Errorf("journalctl: %w", err)
+	}
+	var lines []string
+	cursor := ""
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line, c, ok := journalLine(scanner.Bytes())
+		if ok {
+			lines = append(lines, line)
+			cursor = c
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		_ = read.Process.Kill()
+		_ = read.Wait()
+		return nil, nil, fmt.Errorf("journalctl: %w", err)
+	}
+	if err := read.Wait(); err != nil {
+		return nil, nil, fmt.Errorf("journalctl: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if !follow {
+		return lines, nil, nil
+	}
+	args := append(base, "--follow")
+	if cursor != "" {
+		args = append(args, "--after-cursor="+cursor)
+	} else {
+		args = append(args, "--lines=0")
+	}
+	cmd := exec.Command("journalctl", args...)
+	stdout, err = cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("journalctl: %w", err)
+	}
+	keepChild(cmd)
+	ch := make(chan []string, 16)
+	go func() {
+		defer close(ch)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if line, _, ok := journalLine(scanner.Bytes()); ok {
+				ch <- []string{line}
+			}
+		}
+		_ = cmd.Wait()
+	}()
+	return lines, ch, nil
+}
+
+// journalLine formats one journalctl JSON entry as
+// "time host unit[pid]: LEVEL message" and returns its cursor.
+func journalLine(data []byte) (string, string, bool) {
+	var entry map[string]any
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return "", "", false
+	}
+	field := func(key string) string {
+		switch v := entry[key].(type) {
+		case string:
+			return v
+		case []any:
+			// Non-UTF-8 values come as byte arrays.
+			b := make([]byte, 0, len(v))
+			for _, x := range v {
+				if f, ok := x.(float64); ok {
+					b = append(b, byte(f))
+				}
+			}
+			return string(b)
+		}
+		return ""
+	}
+	var out strings.Builder
+	if usec, err := strconv.ParseInt(field("__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+		out.WriteString(time.UnixMicro(usec).Format("2006-01-02T15:04:05.000000Z07:00"))
+		out.WriteByte(' ')
+	}
+	if host := field("_HOSTNAME"); host != "" {
+		out.WriteString(host + " ")
+	}
+	ident := field("SYSLOG_IDENTIFIER")
+	if ident == "" {
+		ident = field("_COMM")
+	}
+	if ident != "" {
+		out.WriteString(ident)
+		if pid := field("_PID"); pid != "" {
+			out.WriteString("[" + pid + "]")
+		}
+		out.WriteString(": ")
+	}
+	if p, err := strconv.Atoi(field("PRIORITY")); err == nil && p >= 0 && p < len(journalLevels) {
+		out.WriteString(journalLevels[p] + " ")
+	}
+	// A message of several lines stays on one, with its newlines escaped.
+	message := strings.TrimRight(field("MESSAGE"), "\r\n")
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	out.WriteString(strings.ReplaceAll(message, "\n", `\n`))
+	return out.String(), field("__CURSOR"), true
+}
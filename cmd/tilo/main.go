@@ -7,13 +7,20 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/term"
 
 	"tilo/internal/color"
 	"tilo/internal/config"
+	"tilo/internal/fields"
+	"tilo/internal/geoip"
+	"tilo/internal/history"
+	"tilo/internal/level"
+	"tilo/internal/redact"
 	"tilo/internal/ui"
 )
 
@@ -21,49 +28,324 @@ func main() {
 	var configPath string
 	var plain bool
 	var follow bool
+	var include string
+	var exclude string
+	var minLevel string
+	var extract string
+	var merge bool
+	var side bool
+	var uniq bool
+	var redactSecrets bool
+	var exact bool
+	var replay bool
+	var journal bool
+	var atEnd bool
+	var tail int
+	var lineSpan string
+	var byteSpan string
+	var encoding string
+	var serial string
+	var baud int
+	var command string
+	var speed float64
+	var preset string
+	var colorWhen string
+	var ansiInput bool
+	var stripANSI bool
+	var profile bool
 	flag.StringVar(&configPath, "config", "", "path to config file")
-	flag.BoolVar(&plain, "plain", false, "disable color output")
+	flag.BoolVar(&plain, "plain", false, "disable color output (same as --color=never)")
+	flag.BoolVar(&ansiInput, "ansi", false, "keep the colors of input that already has ANSI escapes, with tilo's colors on top")
+	flag.BoolVar(&profile, "profile-rules", false, "time every color rule over the input and print the slowest instead of viewing")
+	flag.BoolVar(&stripANSI, "strip-ansi", false, "remove ANSI escape sequences from the input")
+	flag.StringVar(&colorWhen, "color", "auto", "when to color the output: auto (not when NO_COLOR is set or output goes to a pipe or file), always or never")
 	flag.BoolVar(&follow, "f", false, "follow file growth")
-	flag.Parse()
+	flag.StringVar(&include, "filter", "", "show only lines matching this regex")
+	flag.StringVar(&exclude, "exclude", "", "hide lines matching this regex")
+	flag.StringVar(&minLevel, "level", "", "show only lines at or above this level (trace, debug, info, warn, error, fatal)")
+	flag.StringVar(&extract, "extract", "", "print these JSON/logfmt fields (e.g. .request.path,.status) of each line instead of viewing")
+	flag.BoolVar(&merge, "merge", false, "interleave several files by timestamp, prefixing each line with its file")
+	flag.BoolVar(&uniq, "uniq", false, "collapse runs of identical lines into one line with a (xN) count")
+	flag.BoolVar(&redactSecrets, "redact", false, "mask tokens, passwords and keys in the output")
+	flag.BoolVar(&atEnd, "end", false, "start at the last line (also +G)")
+	flag.IntVar(&tail, "tail", 0, "load only the last N lines of each input")
+	flag.StringVar(&lineSpan, "lines", "", "load only lines START:END of each file (1-based, either end may be left out)")
+	flag.StringVar(&byteSpan, "bytes", "", "load only the lines within byte offsets START:END of each file (K, M and G suffixes allowed)")
+	flag.StringVar(&encoding, "encoding", "auto", "input encoding: auto, utf-8, utf-16le, utf-16be, latin1 or windows-1252")
+	flag.StringVar(&serial, "serial", "", "read a serial console from this device (e.g. /dev/ttyUSB0)")
+	flag.IntVar(&baud, "baud", 115200, "baud rate for --serial")
+	flag.StringVar(&command, "exec", "", "run this shell command and view its output as it runs (or give the command after --)")
+	flag.BoolVar(&journal, "journal", false, "read the systemd journal (of the unit given as argument, if any) through journalctl")
+	flag.StringVar(&preset, "preset", "", "rule preset for the log format: "+strings.Join(color.PresetNames(), ", ")+" (default: picked from the file name; none to turn off)")
+	flag.BoolVar(&replay, "replay", false, "play the file back at the pace of its timestamps, as if it were being written live")
+	flag.Float64Var(&speed, "speed", 1, "replay speed factor (e.g. 10 for ten times faster)")
+	flag.BoolVar(&side, "side", false, "tilo diff: show the files side by side")
+	flag.BoolVar(&exact, "exact", false, "tilo diff: compare lines exactly instead of ignoring timestamps and UUIDs")
+	args := make([]string, 0, len(os.Args))
+	var rest []string
+	for i, arg := range os.Args[1:] {
+		if arg == "--" {
+			rest = os.Args[i+2:]
+			break
+		}
+		// less-style "+G": open at the end.
+		if arg == "+G" {
+			atEnd = true
+			continue
+		}
+		args = append(args, arg)
+	}
+	diffMode := len(args) > 0 && args[0] == "diff"
+	if diffMode {
+		args = args[1:]
+	}
+	_ = flag.CommandLine.Parse(args)
+	// "tilo [flags] -- cmd args" views the output of cmd. After file names,
+	// or before something that is not a command, -- only ends the flags.
+	files := flag.Args()
+	var argv []string
+	if len(files) == 0 && len(rest) > 0 && isCommand(rest[0]) {
+		argv = rest
+	} else {
+		files = append(files, rest...)
+	}
+	stopChildrenOnSignal()
+	defer stopChildren()
 
-	lines, followCh, err := readInput(flag.Args(), follow)
+	switch colorWhen {
+	case "always":
+	case "never":
+		plain = true
+	case "auto":
+		// https://no-color.org
+		if os.Getenv("NO_COLOR") != "" {
+			plain = true
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --color %q (use auto, always or never)\n", colorWhen)
+		exit(1)
+	}
+	if replay && follow {
+		fmt.Fprintln(os.Stderr, "--replay cannot be combined with -f")
+		exit(1)
+	}
+	enc, err := parseEncoding(encoding)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		exit(1)
 	}
-	if len(lines) == 0 {
-		fmt.Fprintln(os.Stderr, "no input")
-		os.Exit(1)
+	inputEncoding = enc
+	if tail < 0 {
+		fmt.Fprintln(os.Stderr, "--tail must not be negative")
+		exit(1)
+	}
+	load := loadOptions{follow: follow, tail: tail}
+	if lineSpan != "" || byteSpan != "" {
+		if lineSpan != "" && byteSpan != "" || follow || tail > 0 {
+			fmt.Fprintln(os.Stderr, "--lines and --bytes cannot be combined with each other, -f or --tail")
+			exit(1)
+		}
+		if lineSpan != "" {
+			load.span, err = parseSpan(lineSpan, false)
+		} else {
+			load.span, err = parseSpan(byteSpan, true)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+	}
+	if speed <= 0 {
+		fmt.Fprintln(os.Stderr, "--speed must be positive")
+		exit(1)
+	}
+
+	filter, err := newLineFilter(include, exclude, minLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
 	}
 
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "config error:", err)
-		os.Exit(1)
+		exit(1)
 	}
 
-	defaults := color.BuildDefaultRules()
-	custom := make([]color.CustomRule, 0, len(cfg.CustomRules))
-	for _, rule := range cfg.CustomRules {
-		custom = append(custom, color.CustomRule{
-			Pattern: rule.Pattern,
-			Color:   rule.Color,
-			Style:   rule.Style,
-		})
+	var buffers []ui.Buffer
+	var labels []string
+	if diffMode {
+		var lines []string
+		lines, err = readDiff(files, side, exact)
+		buffers = []ui.Buffer{{Name: "diff", Lines: keepLast(lines, tail)}}
+	} else if command != "" || len(argv) > 0 {
+		if len(argv) == 0 {
+			argv = []string{"sh", "-c", command}
+		}
+		var followCh <-chan []string
+		followCh, err = runCommand(argv)
+		buffers = []ui.Buffer{{Name: strings.Join(argv, " "), Follow: followCh}}
+		follow = true
+	} else if serial != "" {
+		var followCh <-chan []string
+		followCh, err = readSerial(serial, baud)
+		buffers = []ui.Buffer{{Name: serial, Follow: followCh}}
+		follow = true
+	} else if journal {
+		unit := ""
+		if len(files) > 0 {
+			unit = files[0]
+		}
+		var lines []string
+		var followCh <-chan []string
+		lines, followCh, err = readJournal(unit, follow)
+		name := "journal"
+		if unit != "" {
+			name = "journal:" + unit
+		}
+		buffers = []ui.Buffer{{Name: name, Lines: keepLast(lines, tail), Follow: followCh}}
+	} else if merge {
+		labels = mergeLabels(files)
+		var lines []string
+		var followCh <-chan []string
+		lines, followCh, err = readMerged(files, labels, follow, cfg.TimeLayouts)
+		buffers = []ui.Buffer{{Name: "merge", Lines: keepLast(lines, tail), Follow: followCh}}
+	} else {
+		buffers, labels, err = readInput(files, load, cfg.TimeLayouts)
 	}
-	colorRules, err := color.BuildRules(defaults, cfg.Colors, cfg.DisableBuiltin, custom)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	if totalLines(buffers) == 0 && !following(buffers) {
+		fmt.Fprintln(os.Stderr, "no input")
+		exit(1)
+	}
+	if replay {
+		for i := range buffers {
+			buffers[i].Lines, buffers[i].Follow = replayLines(buffers[i].Lines, speed, cfg.TimeLayouts)
+		}
+		follow = true
+	}
+	ansiInput = ansiInput || cfg.ANSI
+	stripANSI = stripANSI || cfg.StripANSI
+	if ansiInput && stripANSI {
+		fmt.Fprintln(os.Stderr, "--ansi cannot be combined with --strip-ansi")
+		exit(1)
+	}
+	if stripANSI {
+		for i := range buffers {
+			stripLines(buffers[i].Lines)
+			if buffers[i].Follow != nil {
+				buffers[i].Follow = mapStream(buffers[i].Follow, stripLines)
+			}
+		}
+	}
+	if redactSecrets || cfg.Redact {
+		redactor, err := redact.New(cfg.RedactPatterns)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "config error:", err)
+			exit(1)
+		}
+		for i := range buffers {
+			redactor.Lines(buffers[i].Lines)
+			if buffers[i].Follow != nil {
+				buffers[i].Follow = mapStream(buffers[i].Follow, redactor.Lines)
+			}
+		}
+	}
+
+	// Rules for line prefixes added by tilo itself go first everywhere.
+	var prefixRules []color.Rule
+	if diffMode {
+		prefixRules = append(prefixRules, diffRules()...)
+	}
+	if labels != nil {
+		prefixRules = append(prefixRules, mergeRules(labels)...)
+		if !merge {
+			prefixRules = append(prefixRules, dirLabelRule())
+		}
+	}
+	ruleSource := ruleSet{preset: preset, paths: files, prefix: prefixRules}
+	names := make([]string, len(buffers))
+	for i, buf := range buffers {
+		names[i] = buf.Name
+	}
+	colorRules, bufferRules, err := ruleSource.build(cfg, names)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "config error:", err)
-		os.Exit(1)
+		exit(1)
+	}
+	for i := range buffers {
+		buffers[i].Rules = bufferRules[i]
+	}
+
+	filter.detector = level.NewDetector(colorRules)
+
+	if profile {
+		profileRules(buffers, colorRules)
+		return
 	}
 
-	if !term.IsTerminal(int(os.Stdout.Fd())) || !term.IsTerminal(int(os.Stdin.Fd())) {
-		printNonInteractive(lines, colorRules, plain)
-		if followCh != nil {
-			for batch := range followCh {
-				printNonInteractive(batch, colorRules, plain)
+	if extract != "" {
+		paths := fields.SplitPaths(extract)
+		for _, buf := range buffers {
+			printExtract(buf.Lines, paths, filter)
+		}
+		for batch := range followAll(buffers) {
+			printExtract(batch.lines, paths, filter)
+		}
+		return
+	}
+
+	// Hyperlinks are guessed only for a terminal; hyperlinks: true in the
+	// config keeps them when printing to a pager such as less -R.
+	if cfg.Hyperlinks != nil {
+		color.SetHyperlinks(*cfg.Hyperlinks)
+	} else {
+		color.SetHyperlinks(term.IsTerminal(int(os.Stdout.Fd())) && color.DetectHyperlinks())
+	}
+
+	interactive := term.IsTerminal(int(os.Stdout.Fd())) && ui.HasKeyboard()
+	switch cfg.Background {
+	case "light":
+		color.SetLight(true)
+	case "dark":
+	case "", "auto":
+		isLight, ok := color.DetectLight()
+		if !ok && interactive && !plain {
+			isLight, _ = ui.QueryBackground()
+		}
+		color.SetLight(isLight)
+	default:
+		fmt.Fprintf(os.Stderr, "config error: invalid background %q (light, dark or auto)\n", cfg.Background)
+		exit(1)
+	}
+
+	if !interactive {
+		if colorWhen == "auto" && !term.IsTerminal(int(os.Stdout.Fd())) {
+			plain = true
+		}
+		out := newPrinter(colorRules, plain, uniq, ansiInput)
+		out.maxColor = cfg.MaxColorLength
+		if out.maxColor == 0 {
+			out.maxColor = defaultMaxColorLength
+		}
+		rulesOf := func(buf ui.Buffer) []color.Rule {
+			if buf.Rules != nil {
+				return buf.Rules
 			}
+			return colorRules
+		}
+		for _, buf := range buffers {
+			out.rules = rulesOf(buf)
+			out.print(buf.Lines, filter)
 		}
+		for batch := range followAll(buffers) {
+			out.rules = rulesOf(buffers[batch.buffer])
+			out.print(batch.lines, filter)
+		}
+		out.flush()
 		return
 	}
 
@@ -72,52 +354,364 @@ func main() {
 	if cfg.LineNumbers != nil {
 		lineNumbers = *cfg.LineNumbers
 	}
-	if err := ui.Run(lines, colorRules, plain, statusAtTop, lineNumbers, follow, followCh); err != nil {
+	caseMode, err := ui.ParseCaseMode(cfg.SearchCase)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config error:", err)
+		exit(1)
+	}
+	hist := history.New()
+	if cfg.SearchHistory == nil || *cfg.SearchHistory {
+		hist, err = history.Open(history.DefaultPath())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "history error:", err)
+			hist = history.New()
+		}
+	}
+	var timeGap time.Duration
+	if cfg.TimeGap != "" {
+		timeGap, err = time.ParseDuration(cfg.TimeGap)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "config error: invalid time_gap:", err)
+			exit(1)
+		}
+	}
+	var maxMemory int64
+	if cfg.MaxMemory != "" {
+		maxMemory, err = parseSize(cfg.MaxMemory)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "config error: invalid max_memory:", err)
+			exit(1)
+		}
+	}
+	var recordStart *regexp.Regexp
+	if cfg.RecordStart != "" {
+		recordStart, err = regexp.Compile(cfg.RecordStart)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "config error: invalid record_start:", err)
+			exit(1)
+		}
+	}
+	var geo *geoip.DB
+	if len(cfg.GeoIP) > 0 {
+		geo, err = geoip.Open(cfg.GeoIP)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "geoip error:", err)
+			geo = nil
+		}
+	}
+	watches := make([]ui.Watch, 0, len(cfg.Watch))
+	for _, w := range cfg.Watch {
+		re, err := regexp.Compile(w.Pattern)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "config error: invalid watch pattern:", err)
+			exit(1)
+		}
+		watches = append(watches, ui.Watch{Pattern: w.Pattern, Regex: re, Notify: w.Notify, Command: w.Command})
+	}
+	opts := ui.Options{
+		Plain:       plain,
+		StatusAtTop: statusAtTop,
+		LineNumbers: lineNumbers,
+		Follow:      follow,
+		CaseMode:    caseMode,
+		History:     hist,
+		Filter:      filter.include,
+		Exclude:     filter.exclude,
+		MinLevel:    filter.minLevel,
+		TimeLayouts: cfg.TimeLayouts,
+		TimeGap:     timeGap,
+		RecordMode:  cfg.Records,
+		RecordStart: recordStart,
+		Dedupe:      uniq,
+		GeoIP:       geo,
+		Watches:     watches,
+		AtEnd:       atEnd,
+		MaxLines:    cfg.MaxLines,
+		MaxMemory:   maxMemory,
+		ANSI:        ansiInput,
+		Reload:      ruleSource.reloader(configPath),
+		Mouse:       cfg.Mouse == nil || *cfg.Mouse,
+		Scroll:      cfg.Scroll,
+		Tabs:        cfg.Tabs == nil || *cfg.Tabs,
+	}
+	if cfg.Path != "" {
+		opts.ConfigChanged = watchConfig(cfg.Path)
+	}
+	if err := ui.Run(buffers, colorRules, opts); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		exit(1)
 	}
 }
 
-func readInput(args []string, follow bool) ([]string, <-chan []string, error) {
-	if len(args) > 1 {
-		return nil, nil, errors.New("usage: tilo [path|-]")
+// buildColorRules builds the built-in rules with the config and customRules
+// applied, behind those of preset unless it is "" or "none".
+func buildColorRules(cfg config.Config, customRules []config.Rule, preset string) ([]color.Rule, error) {
+	defaults := color.BuildDefaultRules()
+	custom := make([]color.CustomRule, 0, len(customRules))
+	for _, rule := range customRules {
+		groups := make([]color.GroupColor, len(rule.Groups))
+		for i, g := range rule.Groups {
+			groups[i] = color.GroupColor{Color: g.Color, Background: g.Background, Style: g.Style}
+		}
+		stops := make([]color.Stop, len(rule.Gradient))
+		for i, s := range rule.Gradient {
+			stops[i] = color.Stop{Color: s.Color, HasBelow: s.Below != nil}
+			if s.Below != nil {
+				stops[i].Below = *s.Below
+			}
+		}
+		custom = append(custom, color.CustomRule{
+			Name:       rule.Name,
+			Pattern:    rule.Pattern,
+			Extend:     rule.Extend,
+			Color:      rule.Color,
+			Background: rule.Background,
+			Style:      rule.Style,
+			Group:      rule.Group,
+			Groups:     groups,
+			Hash:       rule.Hash,
+			Gradient:   stops,
+			Unit:       rule.Unit,
+			Priority:   rule.Priority,
+		})
 	}
+	rules, err := color.BuildRules(defaults, cfg.Colors, cfg.Backgrounds, cfg.DisableBuiltin, custom)
+	if err != nil || preset == "" || preset == "none" {
+		return rules, err
+	}
+	presetRules, ok := color.Preset(preset)
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", preset, strings.Join(color.PresetNames(), ", "))
+	}
+	rules = append(presetRules, rules...)
+	color.SortRules(rules)
+	return rules, nil
+}
 
+// readInput reads each path argument into its own buffer, or stdin when
+// there are none. A directory becomes one buffer with its files merged; the
+// labels of those files are returned for coloring.
+func readInput(args []string, load loadOptions, layouts []string) ([]ui.Buffer, []string, error) {
+	follow := load.follow
 	if len(args) == 0 {
 		if !term.IsTerminal(int(os.Stdin.Fd())) {
-			lines, err := readLines(os.Stdin)
-			return lines, nil, err
+			buf, err := readPath("-", load)
+			buf.Name = "stdin"
+			return []ui.Buffer{buf}, nil, err
 		}
 		return nil, nil, config.ErrNoInput
 	}
+	buffers := make([]ui.Buffer, 0, len(args))
+	var labels []string
+	for _, arg := range args {
+		var buf ui.Buffer
+		var err error
+		if info, statErr := os.Stat(arg); statErr == nil && info.IsDir() {
+			var dirLabels []string
+			buf.Lines, buf.Follow, dirLabels, err = readDir(arg, follow, layouts)
+			buf.Lines = keepLast(buf.Lines, load.tail)
+			// Non-nil even for an empty directory, whose new files
+			// still need the label rule.
+			labels = append(make([]string, 0, len(labels)+len(dirLabels)), labels...)
+			labels = append(labels, dirLabels...)
+		} else {
+			buf, err = readPath(arg, load)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		buf.Name = arg
+		if arg == "-" {
+			buf.Name = "stdin"
+		}
+		buffers = append(buffers, buf)
+	}
+	return buffers, labels, nil
+}
+
+// cutLoaded applies load.tail or load.span to input that had to be read in
+// full.
+func cutLoaded(buf *ui.Buffer, load loadOptions) error {
+	buf.Lines = keepLast(buf.Lines, load.tail)
+	return cutSpan(buf, load.span)
+}
+
+// loadOptions says how much of an input to read and whether to follow it.
+type loadOptions struct {
+	follow bool
+	tail   int
+	span   *span
+}
 
-	if args[0] == "-" {
+// readPath reads one input. With load.tail or load.span set, a plain file
+// is read only in part; other input is read in full and then cut.
+func readPath(path string, load loadOptions) (ui.Buffer, error) {
+	var buf ui.Buffer
+	var err error
+	follow := load.follow
+	if strings.HasPrefix(path, "ssh://") {
+		buf.Lines, buf.Follow, err = readSSH(path, follow)
+		buf.Lines = keepLast(buf.Lines, load.tail)
+		return buf, err
+	}
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		buf.Lines, buf.Follow, err = readHTTP(path, follow)
+		buf.Lines = keepLast(buf.Lines, load.tail)
+		return buf, err
+	}
+	if strings.HasPrefix(path, "s3://") || strings.HasPrefix(path, "gs://") {
 		if follow {
-			return nil, nil, errors.New("follow requires a file path")
+			return buf, errors.New("cannot follow an object store URL")
 		}
-		lines, err := readLines(os.Stdin)
-		return lines, nil, err
+		if buf.Lines, err = readObject(path); err != nil {
+			return buf, err
+		}
+		return buf, cutLoaded(&buf, load)
+	}
+	if path == "-" {
+		if follow {
+			// Lines show up as the pipe produces them.
+			buf.Follow = streamLines(os.Stdin)
+			return buf, nil
+		}
+		if buf.Lines, err = readLines(os.Stdin); err != nil {
+			return buf, err
+		}
+		return buf, cutLoaded(&buf, load)
+	}
+	if isFIFO(path) {
+		// A pipe has no end, so it is always followed.
+		buf.Follow, buf.Notes, err = readFIFO(path)
+		return buf, err
 	}
 
-	file, err := os.Open(args[0])
+	file, err := os.Open(path)
 	if err != nil {
-		return nil, nil, err
+		return buf, err
+	}
+	partial := fileCompression(file) == ""
+	switch {
+	case load.span != nil && partial:
+		defer file.Close()
+		buf.Lines, buf.LineBase, err = readSpan(file, load.span)
+		return buf, err
+	case load.tail > 0 && partial:
+		if err := seekTail(file, load.tail); err != nil {
+			_ = file.Close()
+			return buf, err
+		}
 	}
 	if !follow {
 		defer file.Close()
-		lines, err := readLines(file)
-		return lines, nil, err
+		if buf.Lines, err = readLines(file); err != nil {
+			return buf, err
+		}
+		return buf, cutLoaded(&buf, load)
+	}
+	if format := fileCompression(file); format != "" {
+		_ = file.Close()
+		return buf, fmt.Errorf("cannot follow a %s-compressed file", format)
 	}
-	lines, err := readLines(file)
+	buf.Lines, err = readLines(file)
 	if err != nil {
 		_ = file.Close()
-		return nil, nil, err
+		return buf, err
+	}
+	buf.Follow = tailFile(file)
+	return buf, nil
+}
+
+func readMerged(args []string, labels []string, follow bool, layouts []string) ([]string, <-chan []string, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.New("--merge requires file paths")
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			return nil, nil, errors.New("--merge cannot read stdin")
+		}
+	}
+	lines, err := mergeFiles(args, labels, layouts)
+	if err != nil || !follow {
+		return lines, nil, err
+	}
+	ch, err := followMerged(args, labels)
+	return lines, ch, err
+}
+
+// mapStream applies fn to every batch of lines from in.
+func mapStream(in <-chan []string, fn func([]string)) <-chan []string {
+	out := make(chan []string, 16)
+	go func() {
+		defer close(out)
+		for batch := range in {
+			fn(batch)
+			out <- batch
+		}
+	}()
+	return out
+}
+
+func stripLines(lines []string) {
+	for i, line := range lines {
+		lines[i] = color.StripANSI(line)
+	}
+}
+
+func totalLines(buffers []ui.Buffer) int {
+	n := 0
+	for _, buf := range buffers {
+		n += len(buf.Lines)
+	}
+	return n
+}
+
+func following(buffers []ui.Buffer) bool {
+	for _, buf := range buffers {
+		if buf.Follow != nil {
+			return true
+		}
+	}
+	return false
+}
+
+type followBatch struct {
+	buffer int
+	lines  []string
+}
+
+// followAll combines the lines arriving on every followed buffer, for
+// printing them as they come when stdout is not a terminal.
+func followAll(buffers []ui.Buffer) <-chan followBatch {
+	out := make(chan followBatch, 16)
+	var wg sync.WaitGroup
+	for i, buf := range buffers {
+		if buf.Follow == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, in <-chan []string) {
+			defer wg.Done()
+			for batch := range in {
+				out <- followBatch{buffer: i, lines: batch}
+			}
+		}(i, buf.Follow)
 	}
-	ch := tailFile(file)
-	return lines, ch, nil
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
 }
 
 func readLines(r io.Reader) ([]string, error) {
+	plain, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer plain.Close()
+	if r, err = decodeInput(plain); err != nil {
+		return nil, err
+	}
 	reader := bufio.NewReader(r)
 	var lines []string
 	for {
@@ -137,36 +731,138 @@ func readLines(r io.Reader) ([]string, error) {
 	return lines, nil
 }
 
-func printNonInteractive(lines []string, rules []color.Rule, plain bool) {
+type lineFilter struct {
+	include  *regexp.Regexp
+	exclude  *regexp.Regexp
+	minLevel level.Level
+	detector *level.Detector
+	last     level.Level
+}
+
+func newLineFilter(include, exclude, minLevel string) (*lineFilter, error) {
+	f := &lineFilter{}
+	lvl, err := level.Parse(minLevel)
+	if err != nil {
+		return f, fmt.Errorf("invalid --level: %w", err)
+	}
+	f.minLevel = lvl
+	if include != "" {
+		re, err := regexp.Compile(include)
+		if err != nil {
+			return f, fmt.Errorf("invalid --filter regex: %w", err)
+		}
+		f.include = re
+	}
+	if exclude != "" {
+		re, err := regexp.Compile(exclude)
+		if err != nil {
+			return f, fmt.Errorf("invalid --exclude regex: %w", err)
+		}
+		f.exclude = re
+	}
+	return f, nil
+}
+
+func (f *lineFilter) keep(line string) bool {
+	if f.minLevel != level.None && f.detector != nil {
+		lvl := f.detector.Detect(line)
+		if lvl == level.None {
+			lvl = f.last
+		}
+		f.last = lvl
+		if lvl < f.minLevel {
+			return false
+		}
+	}
+	if f.include != nil && !f.include.MatchString(line) {
+		return false
+	}
+	return f.exclude == nil || !f.exclude.MatchString(line)
+}
+
+// defaultMaxColorLength is the line length in bytes above which printed
+// lines are not matched against the color rules, as a single huge line
+// (minified JSON, a base64 blob) would otherwise stall the output while
+// every rule scans it. max_color_length changes it; a negative value
+// removes the limit.
+const defaultMaxColorLength = 10000
+
+// printer writes lines to stdout when it is not a terminal. With uniq set
+// it holds back each line until its run of repeats ends.
+type printer struct {
+	rules    []color.Rule
+	plain    bool
+	uniq     bool
+	ansi     bool
+	maxColor int
+	pending  string
+	repeats  int
+}
+
+func newPrinter(rules []color.Rule, plain bool, uniq bool, ansi bool) *printer {
+	return &printer{rules: rules, plain: plain, uniq: uniq, ansi: ansi}
+}
+
+func (p *printer) print(lines []string, filter *lineFilter) {
 	for _, line := range lines {
-		if !plain {
-			line = color.ApplyRules(line, rules)
+		text := line
+		if p.ansi {
+			text, _ = color.ParseANSI(line)
+		}
+		if !filter.keep(text) {
+			continue
 		}
-		fmt.Fprintln(os.Stdout, line)
+		if !p.uniq {
+			p.write(line, 1)
+			continue
+		}
+		if p.repeats > 0 && line == p.pending {
+			p.repeats++
+			continue
+		}
+		p.flush()
+		p.pending = line
+		p.repeats = 1
 	}
 }
 
-func tailFile(file *os.File) <-chan []string {
-	out := make(chan []string, 16)
-	reader := bufio.NewReader(file)
-	go func() {
-		defer close(out)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					time.Sleep(200 * time.Millisecond)
-					continue
-				}
-				return
-			}
-			if line == "" {
-				continue
-			}
-			line = strings.TrimSuffix(line, "\n")
-			line = strings.TrimSuffix(line, "\r")
-			out <- []string{line}
+func (p *printer) flush() {
+	if p.repeats > 0 {
+		p.write(p.pending, p.repeats)
+		p.repeats = 0
+	}
+}
+
+func (p *printer) write(line string, repeats int) {
+	long := p.maxColor > 0 && len(line) > p.maxColor
+	switch {
+	case p.ansi && p.plain:
+		line, _ = color.ParseANSI(line)
+	case long:
+		// Too long to match the rules against; input colors are kept.
+	case p.ansi:
+		text, base := color.ParseANSI(line)
+		line = color.ApplyRulesOver(text, p.rules, nil, base)
+	case !p.plain:
+		line = color.ApplyRules(line, p.rules)
+	}
+	if repeats > 1 {
+		line += fmt.Sprintf(" (x%d)", repeats)
+	}
+	fmt.Fprintln(os.Stdout, line)
+}
+
+// printExtract prints the given fields of each kept line, tab-separated,
+// skipping lines that lack any of them.
+func printExtract(lines []string, paths []string, filter *lineFilter) {
+	for _, line := range lines {
+		if !filter.keep(line) {
+			continue
 		}
-	}()
-	return out
+		values, ok := fields.Extract(line, paths)
+		if !ok {
+			continue
+		}
+		fmt.Fprintln(os.Stdout, strings.Join(values, "\t"))
+	}
 }
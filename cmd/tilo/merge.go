@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"tilo/internal/color"
+	"tilo/internal/timeparse"
+)
+
+var mergePalette = []string{"cyan", "magenta", "green", "yellow", "blue", "red"}
+
+// mergeLabels returns the prefix shown before the lines of each file: the
+// base name, or the full path when two files share a base name.
+func mergeLabels(paths []string) []string {
+	seen := map[string]int{}
+	for _, p := range paths {
+		seen[filepath.Base(p)]++
+	}
+	labels := make([]string, len(paths))
+	width := 0
+	for i, p := range paths {
+		labels[i] = filepath.Base(p)
+		if seen[labels[i]] > 1 {
+			labels[i] = p
+		}
+		if len(labels[i]) > width {
+			width = len(labels[i])
+		}
+	}
+	for i := range labels {
+		labels[i] = fmt.Sprintf("[%-*s] ", width, labels[i])
+	}
+	return labels
+}
+
+// mergeRules colors each file's prefix in its own color. They go before the
+// other rules so the prefix is never recolored.
+func mergeRules(labels []string) []color.Rule {
+	rules := make([]color.Rule, len(labels))
+	for i, label := range labels {
+		rules[i] = color.Rule{
+			Name:    fmt.Sprintf("file_%d", i+1),
+			Regex:   regexp.MustCompile("^" + regexp.QuoteMeta(label)),
+			Color:   mergePalette[i%len(mergePalette)],
+			Style:   "bold",
+			Enabled: true,
+		}
+	}
+	return rules
+}
+
+// dirLabelRule styles the prefix of files that show up in a followed
+// directory after startup, which have no color of their own.
+func dirLabelRule() color.Rule {
+	return color.Rule{
+		Name:    "file_new",
+		Regex:   regexp.MustCompile(`^\[[^\]]+\] `),
+		Color:   "white",
+		Style:   "bold",
+		Enabled: true,
+	}
+}
+
+type mergeEntry struct {
+	when  time.Time
+	file  int
+	lines []string
+}
+
+// mergeFiles reads every file and interleaves their lines in timestamp
+// order. Lines without a timestamp stay attached to the line before them,
+// so stack traces are not split up. Ties keep the order of the arguments.
+func mergeFiles(paths []string, labels []string, layouts []string) ([]string, error) {
+	parser := timeparse.NewParser(layouts)
+	var entries []mergeEntry
+	total := 0
+	for i, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		lines, err := readLines(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, err
+		}
+		total += len(lines)
+		var cur *mergeEntry
+		for _, line := range lines {
+			line = labels[i] + line
+			if t, ok := parser.Parse(line); ok || cur == nil {
+				entries = append(entries, mergeEntry{when: t, file: i})
+				cur = &entries[len(entries)-1]
+			}
+			cur.lines = append(cur.lines, line)
+		}
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		if entries[a].when.Equal(entries[b].when) {
+			return entries[a].file < entries[b].file
+		}
+		return entries[a].when.Before(entries[b].when)
+	})
+	out := make([]string, 0, total)
+	for _, e := range entries {
+		out = append(out, e.lines...)
+	}
+	return out, nil
+}
+
+// followMerged tails every file and prefixes their new lines, which arrive
+// roughly in time order.
+func followMerged(paths []string, labels []string) (<-chan []string, error) {
+	out := make(chan []string, 16)
+	for i, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Seek(0, 2); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		go tailLabeled(file, labels[i], out)
+	}
+	return out, nil
+}
+
+// tailLabeled tails file and sends its new lines to out, prefixed with
+// label.
+func tailLabeled(file *os.File, label string, out chan<- []string) {
+	for batch := range tailFile(file) {
+		for j := range batch {
+			batch[j] = label + batch[j]
+		}
+		out <- batch
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// objectCommand returns the CLI invocation that writes an object store URL
+// to stdout: the AWS CLI for s3://, gcloud (or gsutil) for gs://.
+func objectCommand(url string) ([]string, error) {
+	switch {
+	case strings.HasPrefix(url, "s3://"):
+		return []string{"aws", "s3", "cp", "--quiet", url, "-"}, nil
+	case strings.HasPrefix(url, "gs://"):
+		if _, err := exec.LookPath("gcloud"); err == nil {
+			return []string{"gcloud", "storage", "cat", url}, nil
+		}
+		return []string{"gsutil", "cat", url}, nil
+	}
+	return nil, errors.New("unsupported object URL " + url)
+}
+
+// readObject streams an object through the CLI, so it is decompressed and
+// split into lines as it downloads, without a temporary file.
+func readObject(url string) ([]string, error) {
+	argv, err := objectCommand(url)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	lines, readErr := readLines(stdout)
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("%s: %v %s", url, err, strings.TrimSpace(stderr.String()))
+	}
+	return lines, readErr
+}
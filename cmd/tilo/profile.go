@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"tilo/internal/color"
+	"tilo/internal/ui"
+)
+
+// slowShare is the share of the total matching time above which a rule is
+// flagged as slow.
+const slowShare = 0.2
+
+// profileRules times every rule over the loaded lines and prints them,
+// slowest first.
+func profileRules(buffers []ui.Buffer, rules []color.Rule) {
+	var lines []string
+	for _, buf := range buffers {
+		lines = append(lines, buf.Lines...)
+	}
+	costs := color.Profile(lines, rules)
+	var total time.Duration
+	for _, c := range costs {
+		total += c.Time
+	}
+	fmt.Printf("%d rules over %d lines: %s\n\n", len(costs), len(lines), total.Round(time.Microsecond))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tTIME\tSHARE\tPER LINE\tMATCHES\tPATTERN\t")
+	for _, c := range costs {
+		share := 0.0
+		if total > 0 {
+			share = float64(c.Time) / float64(total)
+		}
+		perLine := time.Duration(0)
+		if len(lines) > 0 {
+			perLine = c.Time / time.Duration(len(lines))
+		}
+		flag := ""
+		if share >= slowShare {
+			flag = "  <- slow"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.1f%%\t%s\t%d\t%s\t%s\n", c.Rule.Name, c.Time.Round(time.Microsecond), share*100, perLine, c.Matches, truncatePattern(c.Rule.Regex.String()), flag)
+	}
+	_ = w.Flush()
+}
+
+func truncatePattern(p string) string {
+	if len(p) > 50 {
+		return p[:47] + "..."
+	}
+	return p
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tilo/internal/color"
+	"tilo/internal/config"
+)
+
+// ruleSet builds the color rules from a config. It keeps what comes from
+// the command line, so the rules can be built again when the config
+// changes.
+type ruleSet struct {
+	preset string
+	paths  []string
+	prefix []color.Rule
+}
+
+// build returns the rules for input in general (level filters, profiling)
+// and those of each named buffer, which differ where rules_for matches.
+func (s ruleSet) build(cfg config.Config, names []string) ([]color.Rule, [][]color.Rule, error) {
+	preset := s.preset
+	if preset == "" {
+		preset = cfg.Preset
+	}
+	if preset == "" {
+		for _, path := range s.paths {
+			if preset = color.DetectPreset(path); preset != "" {
+				break
+			}
+		}
+	}
+	rules, err := buildColorRules(cfg, cfg.CustomRules, preset)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules = append(s.prefix[:len(s.prefix):len(s.prefix)], rules...)
+	perBuffer := make([][]color.Rule, len(names))
+	for i, name := range names {
+		scoped, ok := cfg.RulesFor(name)
+		if !ok {
+			perBuffer[i] = rules
+			continue
+		}
+		bufPreset := preset
+		if scoped.Preset != "" {
+			bufPreset = scoped.Preset
+		}
+		custom := append(cfg.CustomRules[:len(cfg.CustomRules):len(cfg.CustomRules)], scoped.Rules...)
+		scopedRules, err := buildColorRules(cfg, custom, bufPreset)
+		if err != nil {
+			return nil, nil, fmt.Errorf("rules_for %s: %w", name, err)
+		}
+		perBuffer[i] = append(s.prefix[:len(s.prefix):len(s.prefix)], scopedRules...)
+	}
+	return rules, perBuffer, nil
+}
+
+// reloader returns the function the viewer calls to rebuild the rules of
+// its buffers after the config file at path changed.
+func (s ruleSet) reloader(path string) func(names []string) ([][]color.Rule, error) {
+	return func(names []string) ([][]color.Rule, error) {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		_, perBuffer, err := s.build(cfg, names)
+		return perBuffer, err
+	}
+}
+
+// watchConfig reports changes to the config file at path. The directory is
+// watched rather than the file, since editors often save by writing a new
+// file and renaming it over the old one.
+func watchConfig(path string) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	go func() {
+		w := watchPath(filepath.Dir(path))
+		defer w.close()
+		last := configStamp(path)
+		for {
+			w.wait()
+			// Let the editor finish writing before the file is read.
+			time.Sleep(tailPollInterval)
+			stamp := configStamp(path)
+			if stamp == last {
+				continue
+			}
+			last = stamp
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changed
+}
+
+func configStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+
+	"tilo/internal/timeparse"
+)
+
+// replayLines feeds lines back at the pace of their timestamps, divided by
+// speed. The first line is returned right away and the rest arrive on the
+// channel; lines without a timestamp come together with the line before
+// them.
+func replayLines(lines []string, speed float64, layouts []string) ([]string, <-chan []string) {
+	if len(lines) <= 1 {
+		return lines, nil
+	}
+	parser := timeparse.NewParser(layouts)
+	base, _ := parser.Parse(lines[0])
+	out := make(chan []string, 16)
+	go func() {
+		defer close(out)
+		start := time.Now()
+		var batch []string
+		for _, line := range lines[1:] {
+			if t, ok := parser.Parse(line); ok {
+				if base.IsZero() {
+					base = t
+				}
+				due := start.Add(time.Duration(float64(t.Sub(base)) / speed))
+				if wait := time.Until(due); wait > 0 {
+					if len(batch) > 0 {
+						out <- batch
+						batch = nil
+					}
+					time.Sleep(wait)
+				}
+			}
+			batch = append(batch, line)
+		}
+		if len(batch) > 0 {
+			out <- batch
+		}
+	}()
+	return lines[:1], out
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// readSerial opens a serial device in raw mode at the given baud rate and
+// streams what it prints.
+func readSerial(device string, baud int) (<-chan []string, error) {
+	file, err := os.OpenFile(device, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(file.Fd())
+	if _, err := term.MakeRaw(fd); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s: %w", device, err)
+	}
+	if err := setBaud(fd, baud); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s: %w", device, err)
+	}
+	return streamLines(file), nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func setBaud(fd int, baud int) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TIOCGETA)
+	if err != nil {
+		return err
+	}
+	t.Cflag |= unix.CREAD | unix.CLOCAL
+	t.Ispeed = uint64(baud)
+	t.Ospeed = uint64(baud)
+	return unix.IoctlSetTermios(fd, unix.TIOCSETA, t)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+var baudRates = map[int]uint32{
+	1200: unix.B1200, 2400: unix.B2400, 4800: unix.B4800, 9600: unix.B9600,
+	19200: unix.B19200, 38400: unix.B38400, 57600: unix.B57600,
+	115200: unix.B115200, 230400: unix.B230400, 460800: unix.B460800,
+	921600: unix.B921600, 1000000: unix.B1000000, 1500000: unix.B1500000,
+	2000000: unix.B2000000, 3000000: unix.B3000000,
+}
+
+func setBaud(fd int, baud int) error {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	t.Cflag &^= unix.CBAUD
+	t.Cflag |= speed | unix.CREAD | unix.CLOCAL
+	t.Ispeed = speed
+	t.Ospeed = speed
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+func setBaud(fd int, baud int) error {
+	return errors.New("setting the baud rate is not supported on this system")
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"tilo/internal/ui"
+)
+
+// span is a slice of a file to load: lines from..to (1-based, inclusive)
+// or, with bytes set, byte offsets from..to (end exclusive). A zero to means
+// up to the end of the file.
+type span struct {
+	from, to int64
+	bytes    bool
+}
+
+// parseSpan parses "A:B", "A:" or ":B". Byte offsets may carry a K, M or G
+// suffix.
+func parseSpan(s string, bytes bool) (*span, error) {
+	a, b, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid range %q, want START:END", s)
+	}
+	sp := &span{bytes: bytes}
+	var err error
+	if a != "" {
+		if sp.from, err = parseSpanNumber(a, bytes); err != nil {
+			return nil, err
+		}
+	}
+	if b != "" {
+		if sp.to, err = parseSpanNumber(b, bytes); err != nil {
+			return nil, err
+		}
+	}
+	if !bytes && sp.from == 0 {
+		sp.from = 1
+	}
+	if sp.to != 0 && sp.to < sp.from {
+		return nil, fmt.Errorf("invalid range %q: end before start", s)
+	}
+	return sp, nil
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix, such as
+// "512M".
+func parseSize(s string) (int64, error) {
+	n, err := parseSpanNumber(strings.TrimSuffix(strings.ToUpper(s), "B"), true)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n, nil
+}
+
+func parseSpanNumber(s string, bytes bool) (int64, error) {
+	unit := int64(1)
+	if bytes {
+		switch strings.ToUpper(s[len(s)-1:]) {
+		case "K":
+			unit = 1 << 10
+		case "M":
+			unit = 1 << 20
+		case "G":
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid range bound %q", s)
+	}
+	return n * unit, nil
+}
+
+// readSpan reads the part of file covered by sp, without reading past it.
+// It also returns how many lines come before the first one returned, when
+// that is known.
+func readSpan(file *os.File, sp *span) ([]string, int, error) {
+	if sp.bytes {
+		lines, err := readByteSpan(file, sp)
+		return lines, 0, err
+	}
+	if err := seekLine(file, sp.from); err != nil {
+		return nil, 0, err
+	}
+	reader := bufio.NewReader(file)
+	var lines []string
+	for n := sp.from; sp.to == 0 || n <= sp.to; n++ {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			lines = append(lines, strings.TrimSuffix(line, "\r"))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return lines, int(sp.from - 1), nil
+}
+
+// cutSpan applies a line span to input that had to be read in full, such
+// as a pipe or a compressed file.
+func cutSpan(buf *ui.Buffer, sp *span) error {
+	if sp == nil {
+		return nil
+	}
+	if sp.bytes {
+		return errors.New("--bytes needs an uncompressed file")
+	}
+	n := int64(len(buf.Lines))
+	from, to := min(sp.from-1, n), n
+	if sp.to != 0 {
+		to = min(sp.to, n)
+	}
+	buf.Lines = buf.Lines[from:to]
+	buf.LineBase = int(from)
+	return nil
+}
+
+// seekLine moves file to the start of line n, counting newlines in large
+// chunks.
+func seekLine(file *os.File, n int64) error {
+	buf := make([]byte, 256*1024)
+	pos := int64(0)
+	for n > 1 {
+		size, err := file.ReadAt(buf, pos)
+		chunk := buf[:size]
+		for n > 1 {
+			i := bytes.IndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+			chunk = chunk[i+1:]
+			n--
+		}
+		pos += int64(size - len(chunk))
+		if n > 1 && err != nil {
+			// Fewer lines than asked for: nothing to show.
+			pos += int64(len(chunk))
+			break
+		}
+	}
+	_, err := file.Seek(pos, io.SeekStart)
+	return err
+}
+
+// readByteSpan reads the whole lines overlapping the byte range: a line cut
+// by the start is skipped and one cut by the end is read to its end.
+func readByteSpan(file *os.File, sp *span) ([]string, error) {
+	start := sp.from
+	if start > 0 {
+		prev := make([]byte, 1)
+		if _, err := file.ReadAt(prev, start-1); err != nil {
+			return nil, nil
+		}
+		if prev[0] != '\n' {
+			if _, err := file.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			skip, err := bufio.NewReader(file).ReadString('\n')
+			if err != nil {
+				return nil, nil
+			}
+			start += int64(len(skip))
+		}
+	}
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	var lines []string
+	for pos := start; sp.to == 0 || pos < sp.to; {
+		line, err := reader.ReadString('\n')
+		pos += int64(len(line))
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			lines = append(lines, strings.TrimSuffix(line, "\r"))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
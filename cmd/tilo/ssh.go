@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// sshTarget splits ssh://[user@]host[:port]/path into the ssh destination
+// arguments and the remote path.
+func sshTarget(raw string) ([]string, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Hostname() == "" || u.Path == "" || u.Path == "/" {
+		return nil, "", errors.New("usage: ssh://[user@]host[:port]/path/to/file")
+	}
+	dest := u.Hostname()
+	if u.User != nil {
+		dest = u.User.Username() + "@" + dest
+	}
+	// ssh would take a destination starting with - as an option, such as
+	// -oProxyCommand.
+	if strings.HasPrefix(dest, "-") {
+		return nil, "", fmt.Errorf("bad ssh destination %q", dest)
+	}
+	args := []string{"-T"}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	return append(args, "--", dest), u.Path, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// readSSH reads a remote file over ssh. With follow, a second connection
+// tails it from where the first read ended; it runs without a terminal, so
+// it needs key-based authentication (or a shared ControlMaster connection).
+func readSSH(raw string, follow bool) ([]string, <-chan []string, error) {
+	dest, path, err := sshTarget(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("ssh", append(dest, "cat -- "+shellQuote(path))...)
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssh %s: %v %s", raw, err, strings.TrimSpace(stderr.String()))
+	}
+	lines, err := readLines(bytes.NewReader(data))
+	if err != nil || !follow {
+		return lines, nil, err
+	}
+	if compression(data) != "" {
+		return nil, nil, fmt.Errorf("cannot follow a %s-compressed file", compression(data))
+	}
+	remote := "tail -c +" + strconv.Itoa(len(data)+1) + " -F -- " + shellQuote(path)
+	args := append([]string{"-o", "BatchMode=yes"}, dest...)
+	tail := exec.Command("ssh", append(args, remote)...)
+	stdout, err := tail.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tail.Start(); err != nil {
+		return nil, nil, fmt.Errorf("ssh %s: %w", raw, err)
+	}
+	keepChild(tail)
+	ch := make(chan []string, 16)
+	go func() {
+		defer close(ch)
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				line = strings.TrimSuffix(line, "\n")
+				ch <- []string{strings.TrimSuffix(line, "\r")}
+			}
+			if err != nil {
+				break
+			}
+		}
+		_ = tail.Wait()
+	}()
+	return lines, ch, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	tailPollInterval = 200 * time.Millisecond
+	// tailWakeInterval bounds the wait for events that never come, e.g.
+	// on network file systems.
+	tailWakeInterval = 2 * time.Second
+)
+
+// pathWatcher blocks until path changes. It uses fsnotify where the system
+// supports it and falls back to polling otherwise.
+type pathWatcher struct {
+	w *fsnotify.Watcher
+}
+
+func watchPath(path string) *pathWatcher {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return &pathWatcher{}
+	}
+	if err := w.Add(path); err != nil {
+		_ = w.Close()
+		return &pathWatcher{}
+	}
+	return &pathWatcher{w: w}
+}
+
+// wait returns after the next change, or after the poll interval when
+// events are not available.
+func (p *pathWatcher) wait() {
+	if p.w == nil {
+		time.Sleep(tailPollInterval)
+		return
+	}
+	select {
+	case <-p.w.Events:
+	case <-p.w.Errors:
+	case <-time.After(tailWakeInterval):
+	}
+}
+
+func (p *pathWatcher) close() {
+	if p.w != nil {
+		_ = p.w.Close()
+	}
+}
+
+// seekTail moves file to the start of its last n lines by reading
+// backwards from the end, so the rest of a big file is never read.
+func seekTail(file *os.File, n int) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	const chunk = 64 * 1024
+	end := info.Size()
+	buf := make([]byte, chunk)
+	newlines := 0
+	for pos := end; pos > 0; {
+		size := int64(chunk)
+		if pos < size {
+			size = pos
+		}
+		pos -= size
+		if _, err := file.ReadAt(buf[:size], pos); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		for i := size - 1; i >= 0; i-- {
+			// A newline ending the file does not start another line.
+			if buf[i] != '\n' || pos+i == end-1 {
+				continue
+			}
+			newlines++
+			if newlines == n {
+				_, err := file.Seek(pos+i+1, io.SeekStart)
+				return err
+			}
+		}
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	return err
+}
+
+// keepLast returns the last n lines, copied so the rest can be freed; n
+// of 0 keeps them all.
+func keepLast(lines []string, n int) []string {
+	if n <= 0 || len(lines) <= n {
+		return lines
+	}
+	return slices.Clone(lines[len(lines)-n:])
+}
+
+// streamLines reads r until it ends, such as a pipe that keeps producing
+// output. Lines are sent in batches of whatever has arrived so far.
+func streamLines(r io.Reader) <-chan []string {
+	out := make(chan []string, 16)
+	go func() {
+		defer close(out)
+		plain, err := decompress(r)
+		if err != nil {
+			return
+		}
+		defer plain.Close()
+		if r, err = decodeInput(plain); err != nil {
+			return
+		}
+		reader := bufio.NewReader(r)
+		var batch []string
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				line = strings.TrimSuffix(line, "\n")
+				batch = append(batch, strings.TrimSuffix(line, "\r"))
+			}
+			if len(batch) > 0 && (err != nil || reader.Buffered() == 0 || len(batch) >= 1024) {
+				out <- batch
+				batch = nil
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// tailFile follows file like tail -F: when the file at its path is
+// replaced (e.g. by logrotate) the new one is opened, and when it is
+// truncated reading starts over. Both are noted with a marker line.
+func tailFile(file *os.File) <-chan []string {
+	out := make(chan []string, 16)
+	path := file.Name()
+	go func() {
+		defer close(out)
+		reader := bufio.NewReader(file)
+		watcher := watchPath(path)
+		defer func() {
+			watcher.close()
+			_ = file.Close()
+		}()
+		pending := ""
+		offset := int64(0)
+		if pos, err := file.Seek(0, io.SeekCurrent); err == nil {
+			offset = pos
+		}
+		for {
+			line, err := reader.ReadString('\n')
+			offset += int64(len(line))
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					return
+				}
+				// Keep a partly written line until its end arrives.
+				pending += line
+				watcher.wait()
+				switch checkRotation(file, path, offset) {
+				case rotated:
+					next, err := os.Open(path)
+					if err != nil {
+						continue
+					}
+					_ = file.Close()
+					watcher.close()
+					file, reader, offset, pending = next, bufio.NewReader(next), 0, ""
+					watcher = watchPath(path)
+					out <- []string{rotationMarker(path, "was replaced, following the new file")}
+				case truncated:
+					if _, err := file.Seek(0, io.SeekStart); err != nil {
+						return
+					}
+					reader.Reset(file)
+					offset, pending = 0, ""
+					out <- []string{rotationMarker(path, "was truncated")}
+				}
+				continue
+			}
+			line = pending + line
+			pending = ""
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			out <- []string{line}
+		}
+	}()
+	return out
+}
+
+type rotation int
+
+const (
+	unchanged rotation = iota
+	rotated
+	truncated
+)
+
+// checkRotation compares the open file with what is at path now.
+func checkRotation(file *os.File, path string, offset int64) rotation {
+	current, err := os.Stat(path)
+	if err != nil {
+		// Moved away and not recreated yet.
+		return unchanged
+	}
+	opened, err := file.Stat()
+	if err != nil {
+		return unchanged
+	}
+	if !os.SameFile(opened, current) {
+		return rotated
+	}
+	if current.Size() < offset {
+		return truncated
+	}
+	return unchanged
+}
+
+func rotationMarker(path, what string) string {
+	return "-- tilo: " + path + " " + what + " --"
+}
@@ -0,0 +1,160 @@
+package color
+
+import (
+	"sort"
+	"strings"
+)
+
+// ANSISpan is a part of a line that was styled by SGR escapes in the input.
+// Start and End are byte offsets into the line with the escapes removed;
+// Code holds the SGR parameters in effect, such as "1;31".
+type ANSISpan struct {
+	Start int
+	End   int
+	Code  string
+}
+
+// ParseANSI removes the escape sequences from line and returns the plain
+// text together with the styling its SGR sequences described. Other escape
+// sequences (cursor movement, OSC titles and links) are dropped.
+func ParseANSI(line string) (string, []ANSISpan) {
+	if strings.IndexByte(line, '\x1b') < 0 {
+		return line, nil
+	}
+	var plain strings.Builder
+	var spans []ANSISpan
+	var state []string
+	start := 0
+	flush := func() {
+		if plain.Len() > start && len(state) > 0 {
+			spans = append(spans, ANSISpan{Start: start, End: plain.Len(), Code: strings.Join(state, ";")})
+		}
+		start = plain.Len()
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\x1b' {
+			plain.WriteByte(line[i])
+			continue
+		}
+		if i+1 >= len(line) {
+			break
+		}
+		switch line[i+1] {
+		case '[':
+			j := i + 2
+			for j < len(line) && (line[j] < 0x40 || line[j] > 0x7e) {
+				j++
+			}
+			if j >= len(line) {
+				i = len(line)
+				continue
+			}
+			if line[j] == 'm' {
+				flush()
+				state = applySGR(state, line[i+2:j])
+			}
+			i = j
+		case ']':
+			// OSC, ended by BEL or ST (ESC \).
+			j := i + 2
+			for j < len(line) && line[j] != '\a' && !(line[j] == '\x1b' && j+1 < len(line) && line[j+1] == '\\') {
+				j++
+			}
+			if j < len(line) && line[j] == '\x1b' {
+				j++
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	flush()
+	return plain.String(), spans
+}
+
+// StripANSI returns line without its escape sequences.
+func StripANSI(line string) string {
+	plain, _ := ParseANSI(line)
+	return plain
+}
+
+// applySGR updates the active SGR parameters with those of one sequence.
+// A reset clears them; anything else is added to them.
+func applySGR(state []string, params string) []string {
+	parts := strings.Split(params, ";")
+	for i := 0; i < len(parts); i++ {
+		p := parts[i]
+		if p == "" || p == "0" || p == "00" {
+			state = nil
+			continue
+		}
+		// Extended colors take their arguments along: 38;5;N and 38;2;R;G;B.
+		if (p == "38" || p == "48" || p == "58") && i+1 < len(parts) {
+			n := 2
+			if parts[i+1] == "2" {
+				n = 4
+			}
+			end := min(i+1+n, len(parts))
+			p = strings.Join(parts[i:end], ";")
+			i = end - 1
+		}
+		state = append(state, p)
+	}
+	return state
+}
+
+// SliceANSI returns the parts of spans within line[start:end], with offsets
+// relative to start.
+func SliceANSI(spans []ANSISpan, start, end int) []ANSISpan {
+	var out []ANSISpan
+	for _, sp := range spans {
+		if sp.End <= start || sp.Start >= end {
+			continue
+		}
+		out = append(out, ANSISpan{Start: max(sp.Start, start) - start, End: min(sp.End, end) - start, Code: sp.Code})
+	}
+	return out
+}
+
+// paintOver writes line with the input styling in base and the rule spans
+// on top of it. Both lists are sorted and free of overlaps.
+func paintOver(w *linkWriter, spans []Span, base []ANSISpan) {
+	line := w.line
+	bounds := []int{0, len(line)}
+	for _, sp := range spans {
+		bounds = append(bounds, sp.Start, sp.End)
+	}
+	for _, b := range base {
+		bounds = append(bounds, b.Start, b.End)
+	}
+	sort.Ints(bounds)
+	si, bi := 0, 0
+	for k := 0; k+1 < len(bounds); k++ {
+		a, b := bounds[k], bounds[k+1]
+		if a == b || a < 0 || b > len(line) {
+			continue
+		}
+		for si < len(spans) && spans[si].End <= a {
+			si++
+		}
+		for bi < len(base) && base[bi].End <= a {
+			bi++
+		}
+		var codes []string
+		if bi < len(base) && base[bi].Start <= a {
+			codes = append(codes, base[bi].Code)
+		}
+		if si < len(spans) && spans[si].Start <= a {
+			if code := spanCode(spans[si]); code != "" {
+				codes = append(codes, code)
+			}
+		}
+		if len(codes) == 0 {
+			w.text(a, b)
+			continue
+		}
+		w.out.WriteString("\x1b[" + strings.Join(codes, ";") + "m")
+		w.text(a, b)
+		w.out.WriteString(reset)
+	}
+}
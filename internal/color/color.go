@@ -7,12 +7,50 @@ import (
 	"strings"
 )
 
+// Rule colors the matches of Regex. Where rules overlap, the one with the
+// higher Priority wins, then the one listed first. With Group set only that
+// capture group is colored; Groups colors each group its own way instead,
+// Groups[0] being group 1. Hash rules color every distinct match with a
+// color of its own, picked by HashColor, and Gradient rules color a number
+// by the first stop it is below.
 type Rule struct {
-	Name    string
-	Regex   *regexp.Regexp
-	Color   string
-	Style   string
-	Enabled bool
+	Name       string
+	Regex      *regexp.Regexp
+	Color      string
+	Background string
+	Style      string
+	Group      int
+	Groups     []GroupColor
+	Hash       bool
+	Gradient   []Stop
+	Unit       string
+	Priority   int
+	Link       bool
+	Enabled    bool
+}
+
+func (rule Rule) span(line string, start, end int) Span {
+	sp := Span{Start: start, End: end, Color: rule.Color, Background: rule.Background, Style: rule.Style}
+	if start < 0 {
+		return sp
+	}
+	if rule.Hash {
+		sp.Color = HashColor(line[start:end])
+	}
+	if len(rule.Gradient) > 0 {
+		if c, ok := gradientColor(rule.Gradient, rule.Unit, line[start:end]); ok {
+			sp.Color = c
+		}
+	}
+	return sp
+}
+
+// GroupColor is how one capture group of a rule is colored. A zero
+// GroupColor leaves the group alone.
+type GroupColor struct {
+	Color      string
+	Background string
+	Style      string
 }
 
 var ansiColors = map[string]string{
@@ -28,105 +66,200 @@ var ansiColors = map[string]string{
 }
 
 var ansiStyles = map[string]string{
-	"bold":      "1",
-	"dim":       "2",
-	"underline": "4",
-	"reverse":   "7",
+	"bold":          "1",
+	"dim":           "2",
+	"italic":        "3",
+	"underline":     "4",
+	"reverse":       "7",
+	"strikethrough": "9",
+}
+
+// styleNames splits a style such as "bold,underline" into its parts.
+func styleNames(style string) []string {
+	return strings.FieldsFunc(strings.ToLower(style), func(r rune) bool {
+		return r == ',' || r == '+' || r == ' '
+	})
+}
+
+func styleList() string {
+	names := make([]string, 0, len(ansiStyles))
+	for name := range ansiStyles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ValidStyle reports whether every part of style is a known style.
+func ValidStyle(style string) bool {
+	for _, name := range styleNames(style) {
+		if _, ok := ansiStyles[name]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 const reset = "\x1b[0m"
 
 func Wrap(text, colorName, style string) string {
-	code := colorCode(colorName, style)
+	return WrapBackground(text, colorName, "", style)
+}
+
+// WrapBackground is Wrap with a background color as well.
+func WrapBackground(text, colorName, bg, style string) string {
+	code := spanCode(Span{Color: colorName, Background: bg, Style: style})
 	if code == "" {
 		return text
 	}
 	return "\x1b[" + code + "m" + text + reset
 }
 
+// spanCode returns the SGR parameters for the colors of sp.
+func spanCode(sp Span) string {
+	code := colorCode(sp.Color, sp.Style)
+	if bg := background(strings.ToLower(sp.Background)); bg != "" {
+		code = strings.TrimPrefix(code+";"+bg, ";")
+	}
+	return code
+}
+
 func colorCode(colorName, style string) string {
 	colorName = strings.ToLower(colorName)
-	style = strings.ToLower(style)
 	var parts []string
-	if style != "" {
-		if s, ok := ansiStyles[style]; ok {
+	for _, name := range styleNames(style) {
+		if s, ok := ansiStyles[name]; ok {
 			parts = append(parts, s)
 		}
 	}
 	if colorName != "" {
-		if c, ok := ansiColors[colorName]; ok {
+		if c := foreground(colorName); c != "" {
 			parts = append(parts, c)
 		}
 	}
 	return strings.Join(parts, ";")
 }
 
+type Span struct {
+	Start      int
+	End        int
+	Color      string
+	Background string
+	Style      string
+}
+
 func ApplyRules(line string, rules []Rule) string {
-	if len(rules) == 0 || line == "" {
+	return ApplyRulesWithSpans(line, rules, nil)
+}
+
+// ApplyRulesWithSpans colors line like ApplyRules, but paints the given
+// overlay spans first so they take precedence over any rule match.
+func ApplyRulesWithSpans(line string, rules []Rule, overlays []Span) string {
+	return ApplyRulesOver(line, rules, overlays, nil)
+}
+
+// ApplyRulesOver colors line like ApplyRulesWithSpans on top of the colors
+// the line came with, as returned by ParseANSI. Where a rule matches, its
+// colors replace those of the input but other attributes carry over.
+func ApplyRulesOver(line string, rules []Rule, overlays []Span, base []ANSISpan) string {
+	return ApplyRulesLinked(line, rules, overlays, base, FindLinks(line, rules))
+}
+
+// ApplyRulesLinked is ApplyRulesOver with the hyperlinks given rather than
+// found in line, for a line that is part of a longer one.
+func ApplyRulesLinked(line string, rules []Rule, overlays []Span, base []ANSISpan, links []Link) string {
+	if (len(rules) == 0 && len(overlays) == 0 && len(base) == 0) || line == "" {
 		return line
 	}
-	type span struct {
-		start int
-		end   int
-		color string
-		style string
+	spans := ruleSpans(line, rules, overlays)
+	var out strings.Builder
+	w := &linkWriter{out: &out, line: line, links: links}
+	if len(base) > 0 {
+		paintOver(w, spans, base)
+		w.close()
+		return out.String()
+	}
+	pos := 0
+	for _, sp := range spans {
+		if sp.Start < pos {
+			continue
+		}
+		w.text(pos, sp.Start)
+		code := spanCode(sp)
+		if code == "" {
+			w.text(sp.Start, sp.End)
+		} else {
+			out.WriteString("\x1b[" + code + "m")
+			w.text(sp.Start, sp.End)
+			out.WriteString(reset)
+		}
+		pos = sp.End
 	}
+	w.text(pos, len(line))
+	w.close()
+	return out.String()
+}
+
+// ruleSpans returns the spans of line claimed by overlays and rules, in
+// order.
+func ruleSpans(line string, rules []Rule, overlays []Span) []Span {
 	occupied := make([]bool, len(line))
-	var spans []span
+	var spans []Span
+	claim := func(sp Span) {
+		if sp.Start < 0 || sp.End > len(line) || sp.Start >= sp.End {
+			return
+		}
+		for i := sp.Start; i < sp.End; i++ {
+			if occupied[i] {
+				return
+			}
+		}
+		for i := sp.Start; i < sp.End; i++ {
+			occupied[i] = true
+		}
+		spans = append(spans, sp)
+	}
+	for _, sp := range overlays {
+		claim(sp)
+	}
 	for _, rule := range rules {
 		if !rule.Enabled || rule.Regex == nil {
 			continue
 		}
-		indices := rule.Regex.FindAllStringIndex(line, -1)
-		for _, idx := range indices {
-			start, end := idx[0], idx[1]
-			if start >= end {
-				continue
-			}
-			skip := false
-			for i := start; i < end; i++ {
-				if occupied[i] {
-					skip = true
-					break
+		if rule.Group > 0 || len(rule.Groups) > 0 {
+			for _, idx := range rule.Regex.FindAllStringSubmatchIndex(line, -1) {
+				if rule.Group > 0 && 2*rule.Group+1 < len(idx) {
+					claim(rule.span(line, idx[2*rule.Group], idx[2*rule.Group+1]))
+				}
+				for g, gc := range rule.Groups {
+					if gc != (GroupColor{}) && 2*g+3 < len(idx) {
+						claim(Span{Start: idx[2*g+2], End: idx[2*g+3], Color: gc.Color, Background: gc.Background, Style: gc.Style})
+					}
 				}
 			}
-			if skip {
-				continue
-			}
-			for i := start; i < end; i++ {
-				occupied[i] = true
-			}
-			spans = append(spans, span{
-				start: start,
-				end:   end,
-				color: rule.Color,
-				style: rule.Style,
-			})
+			continue
+		}
+		indices := rule.Regex.FindAllStringIndex(line, -1)
+		for _, idx := range indices {
+			claim(rule.span(line, idx[0], idx[1]))
 		}
-	}
-	if len(spans) == 0 {
-		return line
 	}
 	sort.Slice(spans, func(i, j int) bool {
-		if spans[i].start == spans[j].start {
-			return spans[i].end < spans[j].end
+		if spans[i].Start == spans[j].Start {
+			return spans[i].End < spans[j].End
 		}
-		return spans[i].start < spans[j].start
+		return spans[i].Start < spans[j].Start
 	})
-	var out strings.Builder
-	pos := 0
-	for _, sp := range spans {
-		if sp.start < pos {
-			continue
-		}
-		out.WriteString(line[pos:sp.start])
-		out.WriteString(Wrap(line[sp.start:sp.end], sp.color, sp.style))
-		pos = sp.end
-	}
-	out.WriteString(line[pos:])
-	return out.String()
+	return spans
 }
 
+// SizePattern matches byte sizes such as 512MiB, 1.2GB or 100 bytes, and
+// DurationPattern matches durations such as 350ms, 1.5s or 2m30s.
+var (
+	SizePattern     = regexp.MustCompile(`\b\d+(?:\.\d+)? ?(?:[KkMGTPE]i?B|[kmgtpe]i?b|B|bytes?)\b`)
+	DurationPattern = regexp.MustCompile(`\b\d+(?:\.\d+)?(?:ns|us|µs|ms|s|m|h|d)(?:\d+(?:\.\d+)?(?:ns|us|µs|ms|s|m|h))*\b`)
+)
+
 func BuildDefaultRules() []Rule {
 	return []Rule{
 		{
@@ -139,6 +272,36 @@ func BuildDefaultRules() []Rule {
 			Name:    "url",
 			Color:   "blue",
 			Regex:   regexp.MustCompile(`\bhttps?://[^\s\)\]\}\>\,\;\:]+`),
+			Link:    true,
+			Enabled: true,
+		},
+		{
+			Name:    "email",
+			Color:   "blue",
+			Style:   "underline",
+			Regex:   regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`),
+			Enabled: true,
+		},
+		{
+			Name:    "uuid",
+			Color:   "magenta",
+			Regex:   regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`),
+			Enabled: true,
+		},
+		{
+			// Short hashes look like ordinary words, so only those named as
+			// commits count.
+			Name:    "git_sha",
+			Color:   "yellow",
+			Regex:   regexp.MustCompile(`(?i)\b(?:commit|sha|rev(?:ision)?|HEAD is now at)[\s:=]+([0-9a-f]{7,40})\b`),
+			Group:   1,
+			Enabled: true,
+		},
+		{
+			// MD5, SHA-1, SHA-256 and SHA-512 digests.
+			Name:    "hash",
+			Color:   "gray",
+			Regex:   regexp.MustCompile(`\b(?:sha(?:1|256|512):|md5:)?(?:[0-9a-fA-F]{128}|[0-9a-fA-F]{64}|[0-9a-fA-F]{40}|[0-9a-fA-F]{32})\b`),
 			Enabled: true,
 		},
 		{
@@ -159,6 +322,18 @@ func BuildDefaultRules() []Rule {
 			Regex:   regexp.MustCompile(`\b(?:[0-9A-Fa-f]{2}:){5}[0-9A-Fa-f]{2}\b`),
 			Enabled: true,
 		},
+		{
+			Name:    "size",
+			Color:   "bright_magenta",
+			Regex:   SizePattern,
+			Enabled: true,
+		},
+		{
+			Name:    "duration",
+			Color:   "bright_cyan",
+			Regex:   DurationPattern,
+			Enabled: true,
+		},
 		{
 			Name:    "port",
 			Color:   "magenta",
@@ -169,6 +344,7 @@ func BuildDefaultRules() []Rule {
 			Name:    "path",
 			Color:   "green",
 			Regex:   regexp.MustCompile(`\B/(?:[^\s\)\]\}\>\,\;\:]+)`),
+			Link:    true,
 			Enabled: true,
 		},
 		{
@@ -229,7 +405,11 @@ func BuildDefaultRules() []Rule {
 	}
 }
 
-func BuildRules(defaults []Rule, overrides map[string]string, disable []string, custom []CustomRule) ([]Rule, error) {
+// BuildRules applies the config to the built-in rules: overrides and
+// backgrounds map rule names to colors, disable lists rules to turn off,
+// and custom rules are appended after them, or change the built-in rule of
+// the same name.
+func BuildRules(defaults []Rule, overrides, backgrounds map[string]string, disable []string, custom []CustomRule) ([]Rule, error) {
 	disabled := map[string]bool{}
 	for _, name := range disable {
 		disabled[strings.ToLower(name)] = true
@@ -239,12 +419,31 @@ func BuildRules(defaults []Rule, overrides map[string]string, disable []string,
 	for _, rule := range defaults {
 		rule.Enabled = !disabled[strings.ToLower(rule.Name)]
 		if colorOverride, ok := overrides[strings.ToLower(rule.Name)]; ok {
+			if !ValidColor(colorOverride) {
+				return nil, fmt.Errorf("invalid color %q for %s", colorOverride, rule.Name)
+			}
 			rule.Color = colorOverride
 		}
+		if bg, ok := backgrounds[strings.ToLower(rule.Name)]; ok {
+			if !ValidColor(bg) {
+				return nil, fmt.Errorf("invalid background %q for %s", bg, rule.Name)
+			}
+			rule.Background = bg
+		}
 		rules = append(rules, rule)
 	}
 
+	builtin := map[string]int{}
+	for i, rule := range rules {
+		builtin[strings.ToLower(rule.Name)] = i
+	}
 	for _, customRule := range custom {
+		if i, ok := builtin[strings.ToLower(customRule.Name)]; ok {
+			if err := customRule.apply(&rules[i]); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		r, err := customRule.toRule()
 		if err != nil {
 			return nil, err
@@ -252,52 +451,149 @@ func BuildRules(defaults []Rule, overrides map[string]string, disable []string,
 		rules = append(rules, r)
 	}
 
+	SortRules(rules)
 	return rules, nil
 }
 
+// SortRules orders rules by descending priority, keeping the order of rules
+// with the same priority.
+func SortRules(rules []Rule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+}
+
+// CustomRule is a rule from the config. Without a Name it is called
+// "custom". Naming a built-in rule changes that rule instead: Pattern
+// replaces its regex, or with Extend is matched in addition to it.
 type CustomRule struct {
-	Pattern string
-	Color   string
-	Style   string
+	Name       string
+	Pattern    string
+	Extend     bool
+	Color      string
+	Background string
+	Style      string
+	Group      int
+	Groups     []GroupColor
+	Hash       bool
+	Gradient   []Stop
+	Unit       string
+	Priority   int
 }
 
-func (r CustomRule) toRule() (Rule, error) {
+func (r CustomRule) label() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Pattern
+}
+
+func (r CustomRule) check() (*regexp.Regexp, error) {
+	if !ValidColor(r.Color) {
+		return nil, fmt.Errorf("invalid color %q in custom rule %q", r.Color, r.label())
+	}
+	if !ValidColor(r.Background) {
+		return nil, fmt.Errorf("invalid background %q in custom rule %q", r.Background, r.label())
+	}
+	for _, stop := range r.Gradient {
+		if !ValidColor(stop.Color) {
+			return nil, fmt.Errorf("invalid gradient color %q in custom rule %q", stop.Color, r.label())
+		}
+	}
+	if !ValidUnit(r.Unit) {
+		return nil, fmt.Errorf("invalid unit %q in custom rule %q", r.Unit, r.label())
+	}
+	if !ValidStyle(r.Style) {
+		return nil, fmt.Errorf("invalid style %q in custom rule %q (styles: %s)", r.Style, r.label(), styleList())
+	}
+	for _, gc := range r.Groups {
+		if !ValidColor(gc.Color) || !ValidColor(gc.Background) {
+			return nil, fmt.Errorf("invalid group color in custom rule %q", r.label())
+		}
+		if !ValidStyle(gc.Style) {
+			return nil, fmt.Errorf("invalid group style %q in custom rule %q (styles: %s)", gc.Style, r.label(), styleList())
+		}
+	}
+	if r.Pattern == "" {
+		return nil, nil
+	}
 	re, err := regexp.Compile(r.Pattern)
 	if err != nil {
-		return Rule{}, fmt.Errorf("invalid custom rule regex %q: %w", r.Pattern, err)
+		return nil, fmt.Errorf("invalid custom rule regex %q: %w", r.Pattern, err)
+	}
+	if r.Group > re.NumSubexp() || len(r.Groups) > re.NumSubexp() {
+		return nil, fmt.Errorf("custom rule %q colors more capture groups than %q has", r.label(), r.Pattern)
+	}
+	return re, nil
+}
+
+func (r CustomRule) toRule() (Rule, error) {
+	re, err := r.check()
+	if err != nil {
+		return Rule{}, err
+	}
+	if re == nil {
+		return Rule{}, fmt.Errorf("custom rule %q has no pattern", r.label())
+	}
+	name := r.Name
+	if name == "" {
+		name = "custom"
 	}
 	return Rule{
-		Name:    "custom",
-		Regex:   re,
-		Color:   r.Color,
-		Style:   r.Style,
-		Enabled: true,
+		Name:       name,
+		Regex:      re,
+		Color:      r.Color,
+		Background: r.Background,
+		Style:      r.Style,
+		Group:      r.Group,
+		Groups:     r.Groups,
+		Hash:       r.Hash,
+		Gradient:   r.Gradient,
+		Unit:       r.Unit,
+		Priority:   r.Priority,
+		Enabled:    true,
 	}, nil
 }
 
-func HighlightQuery(line, query string) string {
-	if query == "" {
-		return line
+// apply changes the built-in rule with the same name.
+func (r CustomRule) apply(rule *Rule) error {
+	re, err := r.check()
+	if err != nil {
+		return err
 	}
-	lowerLine := strings.ToLower(line)
-	lowerQuery := strings.ToLower(query)
-	idx := strings.Index(lowerLine, lowerQuery)
-	if idx == -1 {
-		return line
+	switch {
+	case re != nil && r.Extend:
+		rule.Regex = regexp.MustCompile("(?:" + rule.Regex.String() + ")|(?:" + r.Pattern + ")")
+	case re != nil:
+		rule.Regex = re
+		rule.Group = 0
+		rule.Groups = nil
+	case r.Extend:
+		return fmt.Errorf("custom rule %q extends %s without a pattern", r.label(), rule.Name)
 	}
-	var out strings.Builder
-	start := 0
-	for idx != -1 {
-		out.WriteString(line[start:idx])
-		match := line[idx : idx+len(query)]
-		out.WriteString(Wrap(match, "", "reverse"))
-		start = idx + len(query)
-		next := strings.Index(lowerLine[start:], lowerQuery)
-		if next == -1 {
-			break
+	if r.Color != "" {
+		rule.Color = r.Color
+	}
+	if r.Background != "" {
+		rule.Background = r.Background
+	}
+	if r.Style != "" {
+		rule.Style = r.Style
+	}
+	if r.Group > 0 || len(r.Groups) > 0 {
+		if r.Group > rule.Regex.NumSubexp() || len(r.Groups) > rule.Regex.NumSubexp() {
+			return fmt.Errorf("custom rule %q colors more capture groups than %s has", r.label(), rule.Name)
 		}
-		idx = start + next
+		rule.Group = r.Group
+		rule.Groups = r.Groups
 	}
-	out.WriteString(line[start:])
-	return out.String()
+	if r.Hash {
+		rule.Hash = true
+	}
+	if len(r.Gradient) > 0 {
+		rule.Gradient = r.Gradient
+		rule.Unit = r.Unit
+	}
+	rule.Priority = r.Priority
+	return nil
 }
@@ -0,0 +1,64 @@
+package color
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Stop is one step of a gradient: values below Below get Color. The last
+// stop usually has no bound and catches everything above.
+type Stop struct {
+	Below    float64
+	HasBelow bool
+	Color    string
+}
+
+var (
+	valueRe  = regexp.MustCompile(`[-+]?\d+(?:\.\d+)?(?:[a-zµμ]+(?:\d+(?:\.\d+)?[a-zµμ]+)*)?`)
+	numberRe = regexp.MustCompile(`^[-+]?\d+(?:\.\d+)?`)
+)
+
+// numericValue reads the first number in text. With a unit such as "ms",
+// durations like "1.5s" are converted to it, so they compare against the
+// same thresholds as bare numbers.
+func numericValue(text, unit string) (float64, bool) {
+	value := valueRe.FindString(text)
+	if value == "" {
+		return 0, false
+	}
+	number := numberRe.FindString(value)
+	if unit != "" && number != value {
+		d, err := time.ParseDuration(value)
+		u, uerr := time.ParseDuration("1" + unit)
+		if err == nil && uerr == nil {
+			return float64(d) / float64(u), true
+		}
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	return v, err == nil
+}
+
+// gradientColor picks the color of the first stop text's value is below.
+func gradientColor(stops []Stop, unit, text string) (string, bool) {
+	v, ok := numericValue(text, unit)
+	if !ok {
+		return "", false
+	}
+	for _, stop := range stops {
+		if !stop.HasBelow || v < stop.Below {
+			return stop.Color, true
+		}
+	}
+	return "", false
+}
+
+// ValidUnit reports whether unit is a duration unit a gradient can convert
+// to.
+func ValidUnit(unit string) bool {
+	if unit == "" {
+		return true
+	}
+	_, err := time.ParseDuration("1" + unit)
+	return err == nil
+}
@@ -0,0 +1,183 @@
+package color
+
+import (
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Link is a part of a line that links to Target when hyperlinks are on.
+// Start and End are byte offsets into the line.
+type Link struct {
+	Start  int
+	End    int
+	Target string
+}
+
+var hyperlinks = false
+
+// SetHyperlinks turns OSC 8 hyperlinks for the matches of link rules (URLs
+// and paths of existing files) on or off. They are off by default.
+func SetHyperlinks(on bool) {
+	hyperlinks = on
+}
+
+// DetectHyperlinks guesses from the environment whether the terminal
+// supports OSC 8 hyperlinks. Terminals without support mostly ignore them,
+// but some print the escapes, so only known ones count.
+func DetectHyperlinks() bool {
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty", "Tabby", "rio":
+		return true
+	}
+	for _, env := range []string{"KITTY_WINDOW_ID", "WT_SESSION", "KONSOLE_VERSION", "ALACRITTY_WINDOW_ID"} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	if vte, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && vte >= 5000 {
+		return true
+	}
+	term := os.Getenv("TERM")
+	for _, name := range []string{"kitty", "foot", "alacritty", "wezterm", "ghostty"} {
+		if strings.Contains(term, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindLinks returns the matches of the enabled link rules in line, in
+// order and without overlaps, or nil while hyperlinks are off.
+func FindLinks(line string, rules []Rule) []Link {
+	if !hyperlinks {
+		return nil
+	}
+	var links []Link
+	for _, rule := range rules {
+		if !rule.Link || !rule.Enabled || rule.Regex == nil {
+			continue
+		}
+		for _, idx := range rule.Regex.FindAllStringIndex(line, -1) {
+			if target := linkTarget(line[idx[0]:idx[1]]); target != "" {
+				links = append(links, Link{Start: idx[0], End: idx[1], Target: target})
+			}
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Start < links[j].Start })
+	kept := links[:0]
+	for _, l := range links {
+		if n := len(kept); n > 0 && l.Start < kept[n-1].End {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	return kept
+}
+
+// SliceLinks returns the parts of links within line[start:end], with
+// offsets relative to start. The targets stay whole.
+func SliceLinks(links []Link, start, end int) []Link {
+	var out []Link
+	for _, l := range links {
+		if l.End <= start || l.Start >= end {
+			continue
+		}
+		out = append(out, Link{Start: max(l.Start, start) - start, End: min(l.End, end) - start, Target: l.Target})
+	}
+	return out
+}
+
+// linkTarget returns where a matched URL or path links to, or "" when it
+// should not link: a path is only linked when the file exists.
+func linkTarget(text string) string {
+	for i := 0; i < len(text); i++ {
+		if text[i] < 0x20 || text[i] == 0x7f {
+			return ""
+		}
+	}
+	if strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://") {
+		return text
+	}
+	if !strings.HasPrefix(text, "/") || !fileExists(text) {
+		return ""
+	}
+	return (&url.URL{Scheme: "file", Host: hostname(), Path: text}).String()
+}
+
+var (
+	hostOnce sync.Once
+	host     string
+
+	statMu    sync.Mutex
+	statCache = map[string]bool{}
+)
+
+// statCacheLimit bounds the paths remembered by fileExists.
+const statCacheLimit = 10000
+
+func hostname() string {
+	hostOnce.Do(func() {
+		host, _ = os.Hostname()
+	})
+	return host
+}
+
+// fileExists stats path once and remembers the answer, since the same
+// paths are drawn over and over.
+func fileExists(path string) bool {
+	statMu.Lock()
+	defer statMu.Unlock()
+	if ok, seen := statCache[path]; seen {
+		return ok
+	}
+	_, err := os.Stat(path)
+	if len(statCache) < statCacheLimit {
+		statCache[path] = err == nil
+	}
+	return err == nil
+}
+
+// linkWriter writes parts of a line in order, opening and closing OSC 8
+// hyperlinks where links start and end.
+type linkWriter struct {
+	out   *strings.Builder
+	line  string
+	links []Link
+	i     int
+	open  bool
+}
+
+func (w *linkWriter) text(a, b int) {
+	for a < b {
+		for w.i < len(w.links) && w.links[w.i].End <= a {
+			w.close()
+			w.i++
+		}
+		next := b
+		if w.i < len(w.links) {
+			l := w.links[w.i]
+			if l.Start <= a {
+				if !w.open {
+					w.out.WriteString("\x1b]8;;" + l.Target + "\x1b\\")
+					w.open = true
+				}
+				next = min(b, l.End)
+			} else {
+				next = min(b, l.Start)
+			}
+		}
+		w.out.WriteString(w.line[a:next])
+		a = next
+	}
+}
+
+func (w *linkWriter) close() {
+	if w.open {
+		w.out.WriteString("\x1b]8;;\x1b\\")
+		w.open = false
+	}
+}
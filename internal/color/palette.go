@@ -0,0 +1,285 @@
+package color
+
+import (
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Depth is how many colors the terminal can show.
+type Depth int
+
+const (
+	Depth16 Depth = iota
+	Depth256
+	DepthTrue
+)
+
+var depth = detectDepth()
+
+// detectDepth guesses the color depth from COLORTERM and TERM.
+func detectDepth() Depth {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return DepthTrue
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return Depth256
+	}
+	return Depth16
+}
+
+// SetDepth overrides the detected color depth. Colors deeper than d are
+// downgraded to the closest one available.
+func SetDepth(d Depth) {
+	depth = d
+}
+
+var light = false
+
+// SetLight adapts the named colors to a light terminal background: those
+// that are hard to read on it are drawn in darker shades.
+func SetLight(on bool) {
+	light = on
+}
+
+// DetectLight guesses the terminal background from COLORFGBG, which some
+// terminals set to "foreground;background". ok is false without a hint.
+func DetectLight() (isLight bool, ok bool) {
+	fgbg := os.Getenv("COLORFGBG")
+	if fgbg == "" {
+		return false, false
+	}
+	bg, err := strconv.Atoi(fgbg[strings.LastIndexByte(fgbg, ';')+1:])
+	if err != nil {
+		return false, false
+	}
+	return bg == 7 || bg >= 9 && bg <= 15, true
+}
+
+// lightColor returns the color drawn instead of name on a light
+// background. Where the terminal has 256 colors, yellow, cyan and green
+// get darker shades; with 16 the bright variants fall back to the normal
+// ones, which terminal themes for light backgrounds keep readable.
+func lightColor(name string) string {
+	switch strings.NewReplacer("-", "", "_", "").Replace(name) {
+	case "white", "brightwhite":
+		return "black"
+	case "yellow", "brightyellow":
+		if depth >= Depth256 {
+			return "136"
+		}
+		return "yellow"
+	case "cyan", "brightcyan":
+		if depth >= Depth256 {
+			return "30"
+		}
+		return "cyan"
+	case "green", "brightgreen":
+		if depth >= Depth256 {
+			return "28"
+		}
+		return "green"
+	}
+	return name
+}
+
+// basic16 holds the RGB values of the 16 standard colors, in SGR order
+// (30-37, then 90-97), used to downgrade deeper colors.
+var basic16 = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// foreground returns the SGR parameters for a color name: one of the
+// ansiColors, a "bright_" variant, a 256-color index such as "208" or an
+// RGB value such as "#ff8800". Unknown names give "".
+func foreground(name string) string {
+	if light {
+		name = lightColor(name)
+	}
+	if c, ok := ansiColors[name]; ok {
+		return c
+	}
+	if base, ok := strings.CutPrefix(strings.NewReplacer("-", "", "_", "").Replace(name), "bright"); ok {
+		if c, ok := ansiColors[base]; ok && base != "gray" {
+			n, _ := strconv.Atoi(c)
+			return strconv.Itoa(n + 60)
+		}
+		return ""
+	}
+	if strings.HasPrefix(name, "#") {
+		r, g, b, ok := parseHex(name[1:])
+		if !ok {
+			return ""
+		}
+		switch depth {
+		case DepthTrue:
+			return "38;2;" + strconv.Itoa(r) + ";" + strconv.Itoa(g) + ";" + strconv.Itoa(b)
+		case Depth256:
+			return "38;5;" + strconv.Itoa(rgbTo256(r, g, b))
+		}
+		return basicCode(nearest16(r, g, b))
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n <= 255 {
+		if n < 16 {
+			return basicCode(n)
+		}
+		if depth >= Depth256 {
+			return "38;5;" + name
+		}
+		r, g, b := rgbOf256(n)
+		return basicCode(nearest16(r, g, b))
+	}
+	return ""
+}
+
+// background returns the SGR parameters that paint name as a background
+// color.
+func background(name string) string {
+	fg := foreground(name)
+	switch {
+	case fg == "":
+		return ""
+	case strings.HasPrefix(fg, "38;"):
+		return "48;" + fg[3:]
+	}
+	n, _ := strconv.Atoi(fg)
+	return strconv.Itoa(n + 10)
+}
+
+var hashPalette16 = []string{"red", "green", "yellow", "blue", "magenta", "cyan", "bright_red", "bright_green", "bright_yellow", "bright_blue", "bright_magenta", "bright_cyan"}
+
+// hashPalette256 holds the colors of the 256-color cube that are bright and
+// saturated enough to read on a dark background.
+var hashPalette256 = func() []string {
+	var out []string
+	for n := 16; n < 232; n++ {
+		r, g, b := (n-16)/36, (n-16)/6%6, (n-16)%6
+		hi, lo := max(r, g, b), min(r, g, b)
+		if hi >= 3 && hi-lo >= 2 {
+			out = append(out, strconv.Itoa(n))
+		}
+	}
+	return out
+}()
+
+// hashPaletteLight holds the colors of the 256-color cube that are dark and
+// saturated enough to read on a light background.
+var hashPaletteLight = func() []string {
+	var out []string
+	for n := 16; n < 232; n++ {
+		r, g, b := (n-16)/36, (n-16)/6%6, (n-16)%6
+		hi, lo := max(r, g, b), min(r, g, b)
+		if hi >= 2 && hi <= 3 && hi-lo >= 2 {
+			out = append(out, strconv.Itoa(n))
+		}
+	}
+	return out
+}()
+
+// HashColor picks a color for s from a hash of it, so the same value always
+// gets the same color.
+func HashColor(s string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	palette := hashPalette16
+	if depth >= Depth256 {
+		palette = hashPalette256
+		if light {
+			palette = hashPaletteLight
+		}
+	}
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// ValidColor reports whether name is a color foreground understands.
+func ValidColor(name string) bool {
+	return name == "" || foreground(strings.ToLower(name)) != ""
+}
+
+func basicCode(i int) string {
+	if i < 8 {
+		return strconv.Itoa(30 + i)
+	}
+	return strconv.Itoa(90 + i - 8)
+}
+
+func parseHex(s string) (int, int, int, bool) {
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff), true
+}
+
+// cubeLevels are the channel values of the 6x6x6 cube in the 256-color
+// palette.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+func rgbTo256(r, g, b int) int {
+	cube := func(v int) int {
+		best := 0
+		for i, l := range cubeLevels {
+			if abs(v-l) < abs(v-cubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	ci, cj, ck := cube(r), cube(g), cube(b)
+	cubeIdx := 16 + 36*ci + 6*cj + ck
+	cubeDist := distance(r, g, b, cubeLevels[ci], cubeLevels[cj], cubeLevels[ck])
+	// The grayscale ramp is closer for near-gray colors.
+	avg := (r + g + b) / 3
+	gi := min(max((avg-8+5)/10, 0), 23)
+	gv := 8 + 10*gi
+	if distance(r, g, b, gv, gv, gv) < cubeDist {
+		return 232 + gi
+	}
+	return cubeIdx
+}
+
+func rgbOf256(n int) (int, int, int) {
+	switch {
+	case n < 16:
+		c := basic16[n]
+		return c[0], c[1], c[2]
+	case n < 232:
+		n -= 16
+		return cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]
+	}
+	v := 8 + 10*(n-232)
+	return v, v, v
+}
+
+func nearest16(r, g, b int) int {
+	best, bestDist := 0, -1
+	for i, c := range basic16 {
+		if d := distance(r, g, b, c[0], c[1], c[2]); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+func distance(r1, g1, b1, r2, g2, b2 int) int {
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	return dr*dr + dg*dg + db*db
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
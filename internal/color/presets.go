@@ -0,0 +1,139 @@
+package color
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type presetRule struct {
+	name    string
+	pattern string
+	color   string
+	style   string
+}
+
+const (
+	accessTime  = `\[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2}(?: [+-]\d{4})?\]`
+	httpMethods = `\b(?:GET|POST|PUT|DELETE|PATCH|HEAD|OPTIONS|CONNECT|TRACE)\b`
+	userAgent   = `"[^"]*" "[^"]*"$`
+)
+
+// presets hold format-specific rules for common log types. They go before
+// the built-in rules, so they win where both match.
+var presets = map[string][]presetRule{
+	"nginx": {
+		{"time", accessTime + `|^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}`, "cyan", ""},
+		{"status_5xx", `" 5\d\d\b`, "red", "bold"},
+		{"status_4xx", `" 4\d\d\b`, "yellow", "bold"},
+		{"status_3xx", `" 3\d\d\b`, "cyan", ""},
+		{"status_2xx", `" 2\d\d\b`, "green", ""},
+		{"method", httpMethods, "magenta", "bold"},
+		{"agent", userAgent, "gray", ""},
+		{"error", `\[(?:emerg|alert|crit|error)\]`, "red", "bold"},
+		{"warn", `\[warn\]`, "yellow", "bold"},
+		{"notice", `\[(?:notice|info|debug)\]`, "blue", ""},
+		{"upstream", `upstream: "[^"]*"`, "cyan", ""},
+	},
+	"apache": {
+		{"time", accessTime + `|^\[\w{3} \w{3} \d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4}\]`, "cyan", ""},
+		{"status_5xx", `" 5\d\d\b`, "red", "bold"},
+		{"status_4xx", `" 4\d\d\b`, "yellow", "bold"},
+		{"status_3xx", `" 3\d\d\b`, "cyan", ""},
+		{"status_2xx", `" 2\d\d\b`, "green", ""},
+		{"method", httpMethods, "magenta", "bold"},
+		{"agent", userAgent, "gray", ""},
+		{"error", `\[(?:\w+:)?(?:emerg|alert|crit|error)\]`, "red", "bold"},
+		{"warn", `\[(?:\w+:)?warn\]`, "yellow", "bold"},
+		{"notice", `\[(?:\w+:)?(?:notice|info|debug|trace\d)\]`, "blue", ""},
+		{"client", `\[client [^\]]+\]`, "yellow", ""},
+		{"code", `\bAH\d{5}\b`, "magenta", ""},
+	},
+	"syslog": {
+		{"time", `^(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)\s+\d{1,2} \d{2}:\d{2}:\d{2}`, "cyan", ""},
+		{"kernel", `\bkernel:`, "magenta", "bold"},
+		{"program", `\b[\w./-]+\[\d+\]:`, "green", ""},
+		{"auth_fail", `(?i)\b(?:authentication failure|failed password|invalid user)\b`, "red", "bold"},
+		{"session", `\bsession (?:opened|closed)\b`, "blue", ""},
+	},
+	"java": {
+		{"caused_by", `^Caused by:`, "red", "bold"},
+		{"exception", `\b(?:[a-z_]\w*\.)*[A-Z]\w*(?:Exception|Error)\b`, "red", "bold"},
+		{"frame", `^\s+at [\w.$<>/]+`, "gray", ""},
+		{"source", `\([\w$]+\.java:\d+\)`, "blue", ""},
+		{"more", `^\s*\.\.\. \d+ (?:more|common frames omitted)`, "gray", ""},
+		{"thread", `\[[\w .:/#-]+-\d+\]`, "cyan", ""},
+		{"logger", `\b(?:[a-z_]\w*\.){2,}[A-Z]\w*\b`, "magenta", ""},
+	},
+	"golang": {
+		{"panic", `^(?:panic|fatal error):`, "red", "bold"},
+		{"goroutine", `^goroutine \d+ \[[^\]]+\]:`, "red", "bold"},
+		{"source", `[\w./-]+\.go:\d+`, "blue", ""},
+		{"offset", `\+0x[0-9a-f]+\b`, "gray", ""},
+		{"created_by", `^created by `, "gray", ""},
+		{"func", `^[\w./*()-]+\.[\w.*()-]+\(`, "magenta", ""},
+	},
+	"postgres": {
+		{"error", `\b(?:ERROR|FATAL|PANIC):`, "red", "bold"},
+		{"warn", `\bWARNING:`, "yellow", "bold"},
+		{"log", `\b(?:LOG|NOTICE|INFO|DEBUG\d?):`, "blue", ""},
+		{"detail", `\b(?:DETAIL|HINT|CONTEXT|STATEMENT|QUERY|LOCATION):`, "magenta", ""},
+		{"duration", `\bduration: [\d.]+ ms\b`, "yellow", ""},
+		{"user", `\b\w+@\w+\b`, "cyan", ""},
+		{"checkpoint", `\bcheckpoint (?:starting|complete)\b`, "green", ""},
+	},
+}
+
+// PresetNames lists the presets in alphabetical order.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Preset returns the rules of a preset, named "<preset>_<rule>".
+func Preset(name string) ([]Rule, bool) {
+	name = strings.ToLower(name)
+	defs, ok := presets[name]
+	if !ok {
+		return nil, false
+	}
+	rules := make([]Rule, len(defs))
+	for i, def := range defs {
+		rules[i] = Rule{
+			Name:    name + "_" + def.name,
+			Regex:   regexp.MustCompile(def.pattern),
+			Color:   def.color,
+			Style:   def.style,
+			Enabled: true,
+		}
+	}
+	return rules, true
+}
+
+// DetectPreset picks a preset from a file path, such as nginx for
+// /var/log/nginx/access.log, or returns "".
+func DetectPreset(path string) string {
+	path = strings.ToLower(filepath.ToSlash(path))
+	base := filepath.Base(path)
+	switch {
+	case strings.Contains(path, "nginx"):
+		return "nginx"
+	case strings.Contains(path, "apache") || strings.Contains(path, "httpd"):
+		return "apache"
+	case strings.Contains(path, "postgres") || strings.Contains(path, "pg_log"):
+		return "postgres"
+	case strings.Contains(path, "catalina") || strings.Contains(path, "tomcat") || strings.HasPrefix(base, "hs_err_pid"):
+		return "java"
+	}
+	for _, prefix := range []string{"syslog", "messages", "kern.log", "auth.log", "daemon.log", "secure"} {
+		if strings.HasPrefix(base, prefix) {
+			return "syslog"
+		}
+	}
+	return ""
+}
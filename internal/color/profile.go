@@ -0,0 +1,39 @@
+package color
+
+import (
+	"sort"
+	"time"
+)
+
+// RuleCost is the time a rule took to match a set of lines.
+type RuleCost struct {
+	Rule    Rule
+	Time    time.Duration
+	Matches int
+}
+
+// Profile runs every enabled rule over lines the way rendering does and
+// returns their costs, slowest first.
+func Profile(lines []string, rules []Rule) []RuleCost {
+	var costs []RuleCost
+	for _, rule := range rules {
+		if !rule.Enabled || rule.Regex == nil {
+			continue
+		}
+		cost := RuleCost{Rule: rule}
+		start := time.Now()
+		for _, line := range lines {
+			if rule.Group > 0 || len(rule.Groups) > 0 {
+				cost.Matches += len(rule.Regex.FindAllStringSubmatchIndex(line, -1))
+			} else {
+				cost.Matches += len(rule.Regex.FindAllStringIndex(line, -1))
+			}
+		}
+		cost.Time = time.Since(start)
+		costs = append(costs, cost)
+	}
+	sort.SliceStable(costs, func(i, j int) bool {
+		return costs[i].Time > costs[j].Time
+	})
+	return costs
+}
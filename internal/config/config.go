@@ -4,23 +4,160 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Rule struct {
+	Name       string  `yaml:"name"`
+	Pattern    string  `yaml:"pattern"`
+	Extend     bool    `yaml:"extend"`
+	Color      string  `yaml:"color"`
+	Background string  `yaml:"background"`
+	Style      string  `yaml:"style"`
+	Group      int     `yaml:"group"`
+	Groups     []Group `yaml:"groups"`
+	Hash       bool    `yaml:"hash"`
+	Gradient   []Stop  `yaml:"gradient"`
+	Unit       string  `yaml:"unit"`
+	Priority   int     `yaml:"priority"`
+}
+
+// Scoped holds the rules for inputs whose name matches a rules_for
+// pattern. It is written either as a list of rules or as a mapping with a
+// preset and rules.
+type Scoped struct {
+	Preset string `yaml:"preset"`
+	Rules  []Rule `yaml:"rules"`
+}
+
+func (s *Scoped) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		return node.Decode(&s.Rules)
+	}
+	type plain Scoped
+	return node.Decode((*plain)(s))
+}
+
+// RulesFor returns the rules_for entries matching an input name: a file
+// path, matched whole or by its base name, or a source such as "journal".
+// Rules of every match are combined in pattern order; the last preset wins.
+func (c Config) RulesFor(name string) (Scoped, bool) {
+	patterns := make([]string, 0, len(c.Scopes))
+	for pattern := range c.Scopes {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	var out Scoped
+	found := false
+	for _, pattern := range patterns {
+		if !matchInput(pattern, name) {
+			continue
+		}
+		found = true
+		scoped := c.Scopes[pattern]
+		out.Rules = append(out.Rules, scoped.Rules...)
+		if scoped.Preset != "" {
+			out.Preset = scoped.Preset
+		}
+	}
+	return out, found
+}
+
+// normalizeRules lowercases the color and style names of rules.
+func normalizeRules(rules []Rule) {
+	for i := range rules {
+		rules[i].Color = strings.ToLower(rules[i].Color)
+		rules[i].Background = strings.ToLower(rules[i].Background)
+		rules[i].Style = strings.ToLower(rules[i].Style)
+		for j := range rules[i].Gradient {
+			stop := &rules[i].Gradient[j]
+			stop.Color = strings.ToLower(stop.Color)
+		}
+		for j := range rules[i].Groups {
+			g := &rules[i].Groups[j]
+			g.Color = strings.ToLower(g.Color)
+			g.Background = strings.ToLower(g.Background)
+			g.Style = strings.ToLower(g.Style)
+		}
+	}
+}
+
+func matchInput(pattern, name string) bool {
+	if pattern == name || strings.HasPrefix(name, pattern+":") {
+		return true
+	}
+	if ok, _ := filepath.Match(pattern, name); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, filepath.Base(name))
+	return ok
+}
+
+// Stop is one step of a gradient rule: values below Below get Color. The
+// stop without Below takes the rest.
+type Stop struct {
+	Below *float64 `yaml:"below"`
+	Color string   `yaml:"color"`
+}
+
+// Group colors one capture group of a rule. It is written either as just a
+// color or as a mapping with color, background and style.
+type Group struct {
+	Color      string `yaml:"color"`
+	Background string `yaml:"background"`
+	Style      string `yaml:"style"`
+}
+
+func (g *Group) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		g.Color = node.Value
+		return nil
+	}
+	type plain Group
+	return node.Decode((*plain)(g))
+}
+
+type Watch struct {
 	Pattern string `yaml:"pattern"`
-	Color   string `yaml:"color"`
-	Style   string `yaml:"style"`
+	Notify  bool   `yaml:"notify"`
+	Command string `yaml:"command"`
 }
 
 type Config struct {
+	Preset         string            `yaml:"preset"`
 	Colors         map[string]string `yaml:"colors"`
+	Backgrounds    map[string]string `yaml:"backgrounds"`
 	DisableBuiltin []string          `yaml:"disable_builtin"`
 	CustomRules    []Rule            `yaml:"custom_rules"`
+	Scopes         map[string]Scoped `yaml:"rules_for"`
 	StatusBar      string            `yaml:"status_bar"`
 	LineNumbers    *bool             `yaml:"line_numbers"`
+	SearchCase     string            `yaml:"search_case"`
+	SearchHistory  *bool             `yaml:"search_history"`
+	TimeLayouts    []string          `yaml:"time_layouts"`
+	TimeGap        string            `yaml:"time_gap"`
+	Records        bool              `yaml:"records"`
+	RecordStart    string            `yaml:"record_start"`
+	Redact         bool              `yaml:"redact"`
+	ANSI           bool              `yaml:"ansi"`
+	StripANSI      bool              `yaml:"strip_ansi"`
+	RedactPatterns []string          `yaml:"redact_patterns"`
+	GeoIP          []string          `yaml:"geoip"`
+	Watch          []Watch           `yaml:"watch"`
+	MaxLines       int               `yaml:"max_lines"`
+	MaxMemory      string            `yaml:"max_memory"`
+	MaxColorLength int               `yaml:"max_color_length"`
+	Hyperlinks     *bool             `yaml:"hyperlinks"`
+	Background     string            `yaml:"background"`
+	Mouse          *bool             `yaml:"mouse"`
+	Scroll         int               `yaml:"scroll"`
+	Tabs           *bool             `yaml:"tabs"`
+
+	// Path is the file the config was read from, if any.
+	Path string `yaml:"-"`
 }
 
 func Load(path string) (Config, error) {
@@ -46,6 +183,7 @@ func Load(path string) (Config, error) {
 	}
 
 	normalize(&cfg)
+	cfg.Path = path
 	return cfg, nil
 }
 
@@ -56,14 +194,24 @@ func normalize(cfg *Config) {
 	for k, v := range cfg.Colors {
 		cfg.Colors[strings.ToLower(k)] = strings.ToLower(v)
 	}
+	backgrounds := map[string]string{}
+	for k, v := range cfg.Backgrounds {
+		backgrounds[strings.ToLower(k)] = strings.ToLower(v)
+	}
+	cfg.Backgrounds = backgrounds
 	for i := range cfg.DisableBuiltin {
 		cfg.DisableBuiltin[i] = strings.ToLower(cfg.DisableBuiltin[i])
 	}
-	for i := range cfg.CustomRules {
-		cfg.CustomRules[i].Color = strings.ToLower(cfg.CustomRules[i].Color)
-		cfg.CustomRules[i].Style = strings.ToLower(cfg.CustomRules[i].Style)
+	normalizeRules(cfg.CustomRules)
+	cfg.Preset = strings.ToLower(strings.TrimSpace(cfg.Preset))
+	cfg.Background = strings.ToLower(strings.TrimSpace(cfg.Background))
+	for pattern, scoped := range cfg.Scopes {
+		scoped.Preset = strings.ToLower(strings.TrimSpace(scoped.Preset))
+		normalizeRules(scoped.Rules)
+		cfg.Scopes[pattern] = scoped
 	}
 	cfg.StatusBar = strings.ToLower(strings.TrimSpace(cfg.StatusBar))
+	cfg.SearchCase = strings.ToLower(strings.TrimSpace(cfg.SearchCase))
 }
 
 func findDefaultConfig() (string, error) {
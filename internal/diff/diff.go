@@ -0,0 +1,159 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+	Change
+)
+
+// Line is one row of a diff. A is the line from the first input and B the
+// line from the second; Delete has only A, Insert only B.
+type Line struct {
+	Op Op
+	A  string
+	B  string
+}
+
+// volatile matches the parts of a line that differ between otherwise
+// identical runs: timestamps and UUIDs.
+var volatile = []*regexp.Regexp{
+	regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+	regexp.MustCompile(`\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}(?:\.\d+)?\b`),
+	regexp.MustCompile(`\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2}(?: [+-]\d{4})?`),
+	regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`),
+}
+
+// Key is the form lines are compared in when volatile parts are ignored.
+func Key(line string) string {
+	for _, re := range volatile {
+		line = re.ReplaceAllString(line, "")
+	}
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// Lines diffs a against b. With exact unset, timestamps and UUIDs are
+// ignored when comparing, so two runs of the same program line up. Runs of
+// deletions followed by insertions are paired up as changes.
+func Lines(a, b []string, exact bool) []Line {
+	ka, kb := a, b
+	if !exact {
+		ka = make([]string, len(a))
+		for i, line := range a {
+			ka[i] = Key(line)
+		}
+		kb = make([]string, len(b))
+		for i, line := range b {
+			kb[i] = Key(line)
+		}
+	}
+	return pairChanges(myers(a, b, ka, kb))
+}
+
+// myers computes a shortest edit script with Myers' algorithm.
+func myers(a, b, ka, kb []string) []Line {
+	n, m := len(ka), len(kb)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] keeps only v[-d-1..d+1] as it was before step d, all the
+	// backtracking reads, so memory grows with D² rather than (N+M)·D.
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && ka[x] == kb[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+	var out []Line
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v, base := trace[d], d+1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[base+k-1] < v[base+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[base+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			out = append(out, Line{Op: Equal, A: a[x], B: b[y]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			out = append(out, Line{Op: Insert, B: b[y]})
+		} else {
+			x--
+			out = append(out, Line{Op: Delete, A: a[x]})
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func pairChanges(lines []Line) []Line {
+	var out []Line
+	for i := 0; i < len(lines); {
+		if lines[i].Op != Delete {
+			out = append(out, lines[i])
+			i++
+			continue
+		}
+		start := i
+		for i < len(lines) && lines[i].Op == Delete {
+			i++
+		}
+		dels := lines[start:i]
+		start = i
+		for i < len(lines) && lines[i].Op == Insert {
+			i++
+		}
+		ins := lines[start:i]
+		for j := 0; j < len(dels) || j < len(ins); j++ {
+			switch {
+			case j < len(dels) && j < len(ins):
+				out = append(out, Line{Op: Change, A: dels[j].A, B: ins[j].B})
+			case j < len(dels):
+				out = append(out, dels[j])
+			default:
+				out = append(out, ins[j])
+			}
+		}
+	}
+	return out
+}
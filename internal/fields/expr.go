@@ -0,0 +1,284 @@
+package fields
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Expr is a parsed filter expression such as
+// `level=error && status>=500 || msg~timeout`.
+type Expr interface {
+	Match(fs []Field) bool
+}
+
+type andExpr struct{ left, right Expr }
+type orExpr struct{ left, right Expr }
+type notExpr struct{ inner Expr }
+
+// cmpExpr compares a field with a value. An empty op tests that the field
+// exists.
+type cmpExpr struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (e andExpr) Match(fs []Field) bool { return e.left.Match(fs) && e.right.Match(fs) }
+func (e orExpr) Match(fs []Field) bool  { return e.left.Match(fs) || e.right.Match(fs) }
+func (e notExpr) Match(fs []Field) bool { return !e.inner.Match(fs) }
+
+func (e cmpExpr) Match(fs []Field) bool {
+	got, ok := Get(fs, e.field)
+	if !ok {
+		return e.op == "!="
+	}
+	switch e.op {
+	case "":
+		return true
+	case "~":
+		return e.re.MatchString(got)
+	case "!~":
+		return !e.re.MatchString(got)
+	}
+	c := Compare(got, e.value)
+	switch e.op {
+	case "=", "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// Compare orders two field values: as numbers or durations when both parse
+// as such, otherwise as case-insensitive strings.
+func Compare(a, b string) int {
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			return compareFloat(x, y)
+		}
+	}
+	if x, err := time.ParseDuration(a); err == nil {
+		if y, err := time.ParseDuration(b); err == nil {
+			return compareFloat(float64(x), float64(y))
+		}
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// ParseExpr parses a filter expression. Comparisons are joined with && and
+// ||, negated with !, and grouped with parentheses. Operators are
+// = == != > >= < <= and ~ / !~ for regex matches.
+func ParseExpr(s string) (Expr, error) {
+	p := &exprParser{tokens: tokenize(s)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *exprParser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) and() (Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) unary() (Expr, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner}, nil
+	case "(":
+		p.next()
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return inner, nil
+	}
+	return p.comparison()
+}
+
+func (p *exprParser) comparison() (Expr, error) {
+	field := p.next()
+	if field == "" || isOperator(field) {
+		return nil, fmt.Errorf("expected field name, got %q", field)
+	}
+	if !isComparison(p.peek()) {
+		return cmpExpr{field: field}, nil
+	}
+	op := p.next()
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("missing value after %s%s", field, op)
+	}
+	value := unquote(p.next())
+	e := cmpExpr{field: field, op: op, value: value}
+	if op == "~" || op == "!~" {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		e.re = re
+	}
+	return e, nil
+}
+
+var operators = []string{"&&", "||", "==", "!=", ">=", "<=", "!~", "=", ">", "<", "~", "!", "(", ")"}
+
+func isOperator(t string) bool {
+	for _, op := range operators {
+		if t == op {
+			return true
+		}
+	}
+	return false
+}
+
+func isComparison(t string) bool {
+	switch t {
+	case "=", "==", "!=", ">", ">=", "<", "<=", "~", "!~":
+		return true
+	}
+	return false
+}
+
+func tokenize(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		if r, size := utf8.DecodeRuneInString(s[i:]); unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		if s[i] == '"' || s[i] == '\'' {
+			end := i + 1
+			for end < len(s) && s[end] != s[i] {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(s))
+			tokens = append(tokens, s[i:end])
+			i = end
+			continue
+		}
+		if op := operatorAt(s, i); op != "" {
+			tokens = append(tokens, op)
+			i += len(op)
+			continue
+		}
+		start := i
+		for i < len(s) && operatorAt(s, i) == "" {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+		tokens = append(tokens, s[start:i])
+	}
+	return tokens
+}
+
+func operatorAt(s string, i int) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s[i:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func unquote(t string) string {
+	if len(t) >= 2 && (t[0] == '"' || t[0] == '\'') && t[len(t)-1] == t[0] {
+		if t[0] == '"' {
+			if u, err := strconv.Unquote(t); err == nil {
+				return u
+			}
+		}
+		return t[1 : len(t)-1]
+	}
+	return t
+}
@@ -0,0 +1,179 @@
+package fields
+
+import (
+	"encoding/json"
+	"strings"
+
+	"tilo/internal/logfmt"
+)
+
+// Field is one named value from a structured log line. Nested JSON keys
+// are joined with dots.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// aliases lists the common spellings of the fields most logs share, so
+// "level" finds lvl or severity and "msg" finds message.
+var aliases = map[string][]string{
+	"time":  {"time", "ts", "timestamp", "@timestamp", "t", "datetime"},
+	"level": {"level", "lvl", "severity", "loglevel", "log.level"},
+	"msg":   {"msg", "message", "@message", "log"},
+}
+
+// JSONObject returns the JSON object a line ends with, allowing a plain
+// prefix such as a timestamp before it.
+func JSONObject(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	start := strings.IndexByte(trimmed, '{')
+	if start < 0 || !strings.HasSuffix(trimmed, "}") {
+		return "", false
+	}
+	obj := trimmed[start:]
+	if !json.Valid([]byte(obj)) {
+		return "", false
+	}
+	return obj, true
+}
+
+// Parse returns the fields of a JSON or logfmt line in the order they
+// appear, or nil if the line is not structured.
+func Parse(line string) []Field {
+	if obj, ok := JSONObject(line); ok {
+		return parseJSON(obj)
+	}
+	pairs := logfmt.Parse(line)
+	if len(pairs) < 2 {
+		return nil
+	}
+	out := make([]Field, len(pairs))
+	for i, p := range pairs {
+		out[i] = Field{Key: p.Key, Value: p.Value}
+	}
+	return out
+}
+
+// Get returns the value of name, trying its aliases when there is no
+// exact match.
+func Get(fs []Field, name string) (string, bool) {
+	if key, ok := Resolve(fs, name); ok {
+		for _, f := range fs {
+			if f.Key == key {
+				return f.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Resolve returns the key in fs that name refers to.
+func Resolve(fs []Field, name string) (string, bool) {
+	for _, f := range fs {
+		if f.Key == name {
+			return name, true
+		}
+	}
+	for _, alias := range aliases[strings.ToLower(name)] {
+		for _, f := range fs {
+			if strings.EqualFold(f.Key, alias) {
+				return f.Key, true
+			}
+		}
+	}
+	return "", false
+}
+
+func parseJSON(obj string) []Field {
+	dec := json.NewDecoder(strings.NewReader(obj))
+	dec.UseNumber()
+	var out []Field
+	if err := walkJSON(dec, "", &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// walkJSON reads one value from dec, flattening objects into dotted keys.
+// Arrays are kept as compact JSON.
+func walkJSON(dec *json.Decoder, prefix string, out *[]Field) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			raw := []json.RawMessage{}
+			for dec.More() {
+				var item json.RawMessage
+				if err := dec.Decode(&item); err != nil {
+					return err
+				}
+				raw = append(raw, item)
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			b, _ := json.Marshal(raw)
+			*out = append(*out, Field{Key: prefix, Value: string(b)})
+			return nil
+		}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if err := walkJSON(dec, key, out); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token()
+		return err
+	case string:
+		*out = append(*out, Field{Key: prefix, Value: t})
+	case json.Number:
+		*out = append(*out, Field{Key: prefix, Value: t.String()})
+	case bool:
+		if t {
+			*out = append(*out, Field{Key: prefix, Value: "true"})
+		} else {
+			*out = append(*out, Field{Key: prefix, Value: "false"})
+		}
+	case nil:
+		*out = append(*out, Field{Key: prefix, Value: "null"})
+	}
+	return nil
+}
+
+// Path turns a jq-style path such as .request.path into a flattened key.
+func Path(p string) string {
+	return strings.TrimPrefix(strings.TrimSpace(p), ".")
+}
+
+// Extract returns the values of paths in line, or false if the line lacks
+// any of them.
+func Extract(line string, paths []string) ([]string, bool) {
+	fs := Parse(line)
+	if fs == nil {
+		return nil, false
+	}
+	values := make([]string, len(paths))
+	for i, p := range paths {
+		value, ok := Get(fs, Path(p))
+		if !ok {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// SplitPaths splits a list of paths separated by commas or spaces.
+func SplitPaths(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,77 @@
+package fields
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalkJSON(t *testing.T) {
+	tests := []struct {
+		obj  string
+		want []Field
+	}{
+		{`{}`, nil},
+		{`{"a":"x","n":1.5,"t":true,"f":false,"z":null}`, []Field{
+			{"a", "x"}, {"n", "1.5"}, {"t", "true"}, {"f", "false"}, {"z", "null"},
+		}},
+		{`{"req":{"path":"/x","hdr":{"ua":"curl"}},"status":200}`, []Field{
+			{"req.path", "/x"}, {"req.hdr.ua", "curl"}, {"status", "200"},
+		}},
+		{`{"tags":["a", 1, {"b":2}],"after":"y"}`, []Field{
+			{"tags", `["a",1,{"b":2}]`}, {"after", "y"},
+		}},
+		{`{"big":12345678901234567890}`, []Field{{"big", "12345678901234567890"}}},
+	}
+	for _, tt := range tests {
+		dec := json.NewDecoder(strings.NewReader(tt.obj))
+		dec.UseNumber()
+		var got []Field
+		if err := walkJSON(dec, "", &got); err != nil {
+			t.Errorf("walkJSON(%s): %v", tt.obj, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("walkJSON(%s) = %v, want %v", tt.obj, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want []Field
+	}{
+		{"plain text", nil},
+		{"a=1", nil},
+		{"a=1 b=2", []Field{{"a", "1"}, {"b", "2"}}},
+		{`2026-02-01 {"level":"info","msg":"hi"}`, []Field{{"level", "info"}, {"msg", "hi"}}},
+		{`{"broken":`, nil},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	fs := []Field{{"ts", "1"}, {"severity", "warn"}, {"message", "hi"}}
+	tests := []struct {
+		name, want string
+		ok         bool
+	}{
+		{"ts", "1", true},
+		{"time", "1", true},
+		{"level", "warn", true},
+		{"msg", "hi", true},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Get(fs, tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Get(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
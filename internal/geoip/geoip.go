@@ -0,0 +1,103 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DB looks up IP addresses in one or more MaxMind databases, typically a
+// country or city database plus an ASN database.
+type DB struct {
+	dbs   []*mmdb
+	cache map[string]Info
+}
+
+// Info is what the databases know about an address.
+type Info struct {
+	Country string
+	City    string
+	ASN     uint
+	Org     string
+}
+
+func (i Info) Empty() bool {
+	return i.Country == "" && i.City == "" && i.ASN == 0 && i.Org == ""
+}
+
+func (i Info) String() string {
+	var parts []string
+	place := i.Country
+	if i.City != "" {
+		place = i.City + ", " + i.Country
+	}
+	if place != "" {
+		parts = append(parts, place)
+	}
+	if i.ASN != 0 {
+		as := fmt.Sprintf("AS%d", i.ASN)
+		if i.Org != "" {
+			as += " " + i.Org
+		}
+		parts = append(parts, as)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func Open(paths []string) (*DB, error) {
+	db := &DB{cache: map[string]Info{}}
+	for _, p := range paths {
+		m, err := openMMDB(p)
+		if err != nil {
+			return nil, err
+		}
+		db.dbs = append(db.dbs, m)
+	}
+	return db, nil
+}
+
+// Lookup merges what every database knows about ip. Results are cached.
+func (db *DB) Lookup(ip string) Info {
+	if db == nil {
+		return Info{}
+	}
+	if info, ok := db.cache[ip]; ok {
+		return info
+	}
+	var info Info
+	if parsed := net.ParseIP(ip); parsed != nil {
+		for _, m := range db.dbs {
+			rec, err := m.lookup(parsed)
+			if err != nil || rec == nil {
+				continue
+			}
+			if info.Country == "" {
+				info.Country = isoCode(rec, "country")
+			}
+			if info.Country == "" {
+				info.Country = isoCode(rec, "registered_country")
+			}
+			if info.City == "" {
+				if city, ok := rec["city"].(map[string]any); ok {
+					if names, ok := city["names"].(map[string]any); ok {
+						info.City, _ = names["en"].(string)
+					}
+				}
+			}
+			if n, ok := rec["autonomous_system_number"].(uint64); ok && info.ASN == 0 {
+				info.ASN = uint(n)
+				info.Org, _ = rec["autonomous_system_organization"].(string)
+			}
+		}
+	}
+	db.cache[ip] = info
+	return info
+}
+
+func isoCode(rec map[string]any, key string) string {
+	if c, ok := rec[key].(map[string]any); ok {
+		code, _ := c["iso_code"].(string)
+		return code
+	}
+	return ""
+}
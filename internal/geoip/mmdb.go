@@ -0,0 +1,260 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// A minimal reader for the MaxMind DB format used by GeoLite2/GeoIP2
+// databases: a binary search tree over IP bits whose leaves point into a
+// typed data section.
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+type mmdb struct {
+	buf        []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dbType     string
+	ipv4Start  uint
+}
+
+func openMMDB(path string) (*mmdb, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	at := bytes.LastIndex(buf, metadataMarker)
+	if at < 0 {
+		return nil, fmt.Errorf("%s: not a MaxMind database", path)
+	}
+	meta := buf[at+len(metadataMarker):]
+	value, _, err := decode(meta, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: metadata: %w", path, err)
+	}
+	m, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid metadata", path)
+	}
+	db := &mmdb{
+		nodeCount:  toUint(m["node_count"]),
+		recordSize: toUint(m["record_size"]),
+		ipVersion:  toUint(m["ip_version"]),
+	}
+	db.dbType, _ = m["database_type"].(string)
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%s: unsupported record size %d", path, db.recordSize)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+16 > uint(at) {
+		return nil, fmt.Errorf("%s: truncated search tree", path)
+	}
+	db.buf = buf[:treeSize]
+	db.data = buf[treeSize+16 : at]
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+func (db *mmdb) record(node uint, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		b := db.buf[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.buf[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(db.buf[node*8+bit*4:]))
+	}
+}
+
+// lookup returns the data record for ip, or nil if the database has none.
+func (db *mmdb) lookup(ip net.IP) (map[string]any, error) {
+	var bits []byte
+	node := uint(0)
+	if v4 := ip.To4(); v4 != nil {
+		bits = v4
+		if db.ipVersion == 6 {
+			node = db.ipv4Start
+		}
+	} else {
+		if db.ipVersion == 4 {
+			return nil, nil
+		}
+		bits = ip.To16()
+	}
+	for i := 0; i < len(bits)*8 && node < db.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-uint(i%8))) & 1
+		node = db.record(node, bit)
+	}
+	if node <= db.nodeCount {
+		return nil, nil
+	}
+	offset := node - db.nodeCount - 16
+	if offset >= uint(len(db.data)) {
+		return nil, errors.New("invalid data pointer")
+	}
+	value, _, err := decode(db.data, offset)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := value.(map[string]any)
+	return m, nil
+}
+
+// decode reads the value at offset in a data section and returns it with
+// the offset just past it.
+func decode(data []byte, offset uint) (any, uint, error) {
+	if offset >= uint(len(data)) {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+	ctrl := data[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+	if typ == 1 {
+		ptr, next, err := pointer(data, ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := decode(data, ptr)
+		return value, next, err
+	}
+	if typ == 0 {
+		if offset >= uint(len(data)) {
+			return nil, 0, errors.New("unexpected end of data")
+		}
+		typ = 7 + uint(data[offset])
+		offset++
+	}
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(data)) {
+			return nil, 0, errors.New("unexpected end of data")
+		}
+		extra := uint(0)
+		for _, b := range data[offset : offset+n] {
+			extra = extra<<8 | uint(b)
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + extra
+		case 30:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+	switch typ {
+	case 7:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := decode(data, offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, next, err := decode(data, next)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, _ := key.(string)
+			m[k] = value
+			offset = next
+		}
+		return m, offset, nil
+	case 11:
+		arr := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := decode(data, offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, value)
+			offset = next
+		}
+		return arr, offset, nil
+	case 14:
+		return size != 0, offset, nil
+	}
+	if offset+size > uint(len(data)) {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+	raw := data[offset : offset+size]
+	offset += size
+	switch typ {
+	case 2:
+		return string(raw), offset, nil
+	case 3:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), offset, nil
+	case 15:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), offset, nil
+	case 4, 10:
+		return raw, offset, nil
+	case 5, 6, 9:
+		n := uint64(0)
+		for _, b := range raw {
+			n = n<<8 | uint64(b)
+		}
+		return n, offset, nil
+	case 8:
+		n := uint32(0)
+		for _, b := range raw {
+			n = n<<8 | uint32(b)
+		}
+		return int64(int32(n)), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+}
+
+func pointer(data []byte, ctrl byte, offset uint) (uint, uint, error) {
+	ss := uint(ctrl>>3) & 0x3
+	n := ss + 1
+	if offset+n > uint(len(data)) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+	p := uint(0)
+	if ss < 3 {
+		p = uint(ctrl & 0x7)
+	}
+	for _, b := range data[offset : offset+n] {
+		p = p<<8 | uint(b)
+	}
+	switch ss {
+	case 1:
+		p += 2048
+	case 2:
+		p += 526336
+	}
+	return p, offset + n, nil
+}
+
+func toUint(v any) uint {
+	n, _ := v.(uint64)
+	return uint(n)
+}
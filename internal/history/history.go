@@ -0,0 +1,131 @@
+package history
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxEntries = 500
+
+type History struct {
+	path    string
+	entries []string
+	// lines is how many entries the file holds; past maxEntries it is
+	// written again with only the kept ones.
+	lines  int
+	failed bool
+}
+
+func New() *History {
+	return &History{}
+}
+
+func Open(path string) (*History, error) {
+	h := &History{path: path}
+	if path == "" {
+		return h, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	h.lines = len(h.entries)
+	if len(h.entries) > maxEntries {
+		h.entries = h.entries[len(h.entries)-maxEntries:]
+		if err := h.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// rewrite replaces the file with the kept entries.
+func (h *History) rewrite() error {
+	data := strings.Join(h.entries, "\n") + "\n"
+	if err := os.WriteFile(h.path, []byte(data), 0o600); err != nil {
+		return err
+	}
+	h.lines = len(h.entries)
+	return nil
+}
+
+func DefaultPath() string {
+	state := os.Getenv("XDG_STATE_HOME")
+	if state == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		state = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(state, "tilo", "history")
+}
+
+func (h *History) Entries() []string {
+	if h == nil {
+		return nil
+	}
+	return h.entries
+}
+
+// Add appends entry to the history and its file. Only the first error
+// writing the file is returned, so a read-only file is reported once.
+func (h *History) Add(entry string) error {
+	if h == nil {
+		return nil
+	}
+	entry = strings.TrimSpace(entry)
+	if entry == "" || strings.ContainsAny(entry, "\r\n") {
+		return nil
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == entry {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxEntries {
+		h.entries = h.entries[len(h.entries)-maxEntries:]
+	}
+	if h.path == "" || h.failed {
+		return nil
+	}
+	if err := h.write(entry); err != nil {
+		h.failed = true
+		return err
+	}
+	return nil
+}
+
+func (h *History) write(entry string) error {
+	if h.lines >= maxEntries {
+		return h.rewrite()
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(entry + "\n"); err != nil {
+		_ = file.Close()
+		return err
+	}
+	h.lines++
+	return file.Close()
+}
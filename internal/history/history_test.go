@@ -0,0 +1,93 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fileLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestOpenTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var data strings.Builder
+	for i := 0; i < maxEntries+20; i++ {
+		fmt.Fprintf(&data, "q%d\n\n", i)
+	}
+	if err := os.WriteFile(path, []byte(data.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := h.Entries()
+	if len(entries) != maxEntries || entries[0] != "q20" {
+		t.Fatalf("Open kept %d entries starting at %q, want %d starting at q20", len(entries), entries[0], maxEntries)
+	}
+	if lines := fileLines(t, path); len(lines) != maxEntries || lines[0] != "q20" {
+		t.Errorf("file has %d lines starting at %q, want %d starting at q20", len(lines), lines[0], maxEntries)
+	}
+}
+
+func TestAddCapsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history")
+	h, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxEntries+30; i++ {
+		if err := h.Add(fmt.Sprintf("q%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := fileLines(t, path)
+	if len(lines) > maxEntries {
+		t.Errorf("file has %d lines, want at most %d", len(lines), maxEntries)
+	}
+	if last := lines[len(lines)-1]; last != fmt.Sprintf("q%d", maxEntries+29) {
+		t.Errorf("last line = %q", last)
+	}
+	if n := len(h.Entries()); n != maxEntries {
+		t.Errorf("%d entries, want %d", n, maxEntries)
+	}
+}
+
+func TestAddSkips(t *testing.T) {
+	h := New()
+	for _, entry := range []string{"a", " a ", "", "  ", "b\nc", "b", "a"} {
+		if err := h.Add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(h.Entries(), ","); got != "a,b,a" {
+		t.Errorf("entries = %q, want a,b,a", got)
+	}
+}
+
+func TestAddReportsFirstError(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h := &History{path: filepath.Join(blocker, "history")}
+	if err := h.Add("a"); err == nil {
+		t.Fatal("Add under a file: no error")
+	}
+	if err := h.Add("b"); err != nil {
+		t.Errorf("second Add: %v, want no error", err)
+	}
+	if got := strings.Join(h.Entries(), ","); got != "a,b" {
+		t.Errorf("entries = %q, want a,b", got)
+	}
+}
@@ -0,0 +1,99 @@
+package level
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"tilo/internal/color"
+)
+
+type Level int
+
+const (
+	None Level = iota
+	Trace
+	Debug
+	Info
+	Warn
+	Error
+	Fatal
+)
+
+var names = []string{"NONE", "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+func (l Level) String() string {
+	if l < None || l > Fatal {
+		return names[None]
+	}
+	return names[l]
+}
+
+func Parse(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none", "all":
+		return None, nil
+	case "trace":
+		return Trace, nil
+	case "debug":
+		return Debug, nil
+	case "info":
+		return Info, nil
+	case "warn", "warning":
+		return Warn, nil
+	case "error", "err":
+		return Error, nil
+	case "fatal":
+		return Fatal, nil
+	}
+	return None, fmt.Errorf("unknown level %q", s)
+}
+
+var ruleLevels = map[string]Level{
+	"level_trace": Trace,
+	"level_debug": Debug,
+	"level_info":  Info,
+	"level_warn":  Warn,
+	"level_error": Error,
+}
+
+type matcher struct {
+	re    *regexp.Regexp
+	level Level
+}
+
+// Detector finds the severity of a line using the level_* color rules, so
+// any override of their patterns also changes what counts as a level.
+type Detector struct {
+	matchers []matcher
+}
+
+func NewDetector(rules []color.Rule) *Detector {
+	d := &Detector{}
+	for _, rule := range rules {
+		lvl, ok := ruleLevels[strings.ToLower(rule.Name)]
+		if !ok || rule.Regex == nil {
+			continue
+		}
+		d.matchers = append(d.matchers, matcher{re: rule.Regex, level: lvl})
+	}
+	return d
+}
+
+// Detect returns the level named earliest in line, or None.
+func (d *Detector) Detect(line string) Level {
+	found := None
+	pos := len(line) + 1
+	for _, m := range d.matchers {
+		loc := m.re.FindStringIndex(line)
+		if loc == nil || loc[0] >= pos {
+			continue
+		}
+		pos = loc[0]
+		found = m.level
+		if m.level == Error && strings.EqualFold(line[loc[0]:loc[1]], "fatal") {
+			found = Fatal
+		}
+	}
+	return found
+}
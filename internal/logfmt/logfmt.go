@@ -0,0 +1,102 @@
+package logfmt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Pair is one key=value field. Offsets are byte positions in the line:
+// the key spans [KeyStart, ValueStart-1) and the raw value [ValueStart, End).
+type Pair struct {
+	Key        string
+	Value      string
+	Quoted     bool
+	KeyStart   int
+	ValueStart int
+	End        int
+}
+
+// Parse returns the key=value pairs found in line. Text that is not part of
+// a pair (a leading timestamp, free-form words) is skipped.
+func Parse(line string) []Pair {
+	var pairs []Pair
+	i := 0
+	for i < len(line) {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(line) && isKeyByte(line[i], i == start) {
+			i++
+		}
+		if i == start || i >= len(line) || line[i] != '=' {
+			i = skipWord(line, i)
+			continue
+		}
+		pair := Pair{Key: line[start:i], KeyStart: start, ValueStart: i + 1}
+		i++
+		if i < len(line) && line[i] == '"' {
+			end := quotedEnd(line, i)
+			pair.Quoted = true
+			if v, err := strconv.Unquote(line[i:end]); err == nil {
+				pair.Value = v
+			} else {
+				pair.Value = strings.Trim(line[i:end], `"`)
+			}
+			i = end
+		} else {
+			end := skipWord(line, i)
+			pair.Value = line[i:end]
+			i = end
+		}
+		pair.End = i
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// Fields parses line into a map, returning nil unless it has at least two
+// pairs, so prose with a stray "=" is not treated as logfmt.
+func Fields(line string) map[string]string {
+	pairs := Parse(line)
+	if len(pairs) < 2 {
+		return nil
+	}
+	fields := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		fields[p.Key] = p.Value
+	}
+	return fields
+}
+
+func isKeyByte(b byte, first bool) bool {
+	switch {
+	case b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z':
+		return true
+	case b >= '0' && b <= '9' || b == '.' || b == '-' || b == '/':
+		return !first
+	}
+	return false
+}
+
+func skipWord(line string, i int) int {
+	for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+		i++
+	}
+	return i
+}
+
+// quotedEnd returns the offset just past the closing quote of the string
+// starting at line[i], or the end of the line if it is unterminated.
+func quotedEnd(line string, i int) int {
+	for j := i + 1; j < len(line); j++ {
+		switch line[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(line)
+}
@@ -0,0 +1,58 @@
+package logfmt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want [][2]string
+	}{
+		{"", nil},
+		{"no pairs here", nil},
+		{"a=1 b=two", [][2]string{{"a", "1"}, {"b", "two"}}},
+		{`2026-02-01T10:00:00Z level=info msg="hello world" n=3`, [][2]string{{"level", "info"}, {"msg", "hello world"}, {"n", "3"}}},
+		{`msg="say \"hi\"" x=`, [][2]string{{"msg", `say "hi"`}, {"x", ""}}},
+		{"key.with-dots_1=v =orphan 9bad=1", [][2]string{{"key.with-dots_1", "v"}}},
+		{"a=1\tb=2", [][2]string{{"a", "1"}, {"b", "2"}}},
+	}
+	for _, tt := range tests {
+		var got [][2]string
+		for _, p := range Parse(tt.line) {
+			got = append(got, [2]string{p.Key, p.Value})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseOffsets(t *testing.T) {
+	line := `ts=1 msg="a b" end=x`
+	want := []Pair{
+		{Key: "ts", Value: "1", KeyStart: 0, ValueStart: 3, End: 4},
+		{Key: "msg", Value: "a b", Quoted: true, KeyStart: 5, ValueStart: 9, End: 14},
+		{Key: "end", Value: "x", KeyStart: 15, ValueStart: 19, End: 20},
+	}
+	if got := Parse(line); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse(%q) = %+v, want %+v", line, got, want)
+	}
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		line string
+		want map[string]string
+	}{
+		{"a=1", nil},
+		{"just a=1 stray", nil},
+		{"a=1 b=2", map[string]string{"a": "1", "b": "2"}},
+	}
+	for _, tt := range tests {
+		if got := Fields(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Fields(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tilo/internal/fields"
+)
+
+// Query is a parsed statement such as
+// SELECT count(*), level WHERE status>=500 GROUP BY level ORDER BY 1 DESC LIMIT 10.
+type Query struct {
+	Columns []Column
+	Where   fields.Expr
+	GroupBy []string
+	OrderBy int
+	Desc    bool
+	Limit   int
+}
+
+// Column is a selected field, optionally wrapped in an aggregate
+// (count, sum, avg, min, max).
+type Column struct {
+	Func  string
+	Field string
+}
+
+func (c Column) String() string {
+	if c.Func == "" {
+		return c.Field
+	}
+	return c.Func + "(" + c.Field + ")"
+}
+
+// Result is the output of a query. Lines holds, for each row, the index of
+// the first input line that contributed to it.
+type Result struct {
+	Header []string
+	Rows   [][]string
+	Lines  []int
+}
+
+var columnRe = regexp.MustCompile(`(?i)^(count|sum|avg|min|max)\s*\(\s*([^)]*)\s*\)$`)
+
+// clause is where a clause keyword was found in a query: the keyword spans
+// s[start:end].
+type clause struct {
+	name       string
+	start, end int
+}
+
+// findClauses returns the clause keywords of s in order. Only whole words
+// outside quotes count, so a value such as /select or "limit" does not
+// start a clause.
+func findClauses(s string) []clause {
+	words := splitWords(s)
+	var out []clause
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch name := strings.ToLower(s[w[0]:w[1]]); name {
+		case "select", "where", "limit":
+			out = append(out, clause{name, w[0], w[1]})
+		case "group", "order":
+			if i+1 < len(words) && strings.EqualFold(s[words[i+1][0]:words[i+1][1]], "by") {
+				out = append(out, clause{name + " by", w[0], words[i+1][1]})
+				i++
+			}
+		}
+	}
+	return out
+}
+
+// splitWords returns the start and end of each run of s between spaces,
+// keeping quoted strings, with their backslash escapes, inside one word.
+func splitWords(s string) [][2]int {
+	var words [][2]int
+	start := -1
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ' ' || c == '\t' || c == '\n':
+			if start >= 0 {
+				words = append(words, [2]int{start, i})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, [2]int{start, len(s)})
+	}
+	return words
+}
+
+// Parse parses a query. Clauses may appear in any order; only SELECT is
+// required.
+func Parse(s string) (*Query, error) {
+	clauses := map[string]string{}
+	found := findClauses(s)
+	if len(found) == 0 || strings.TrimSpace(s[:found[0].start]) != "" {
+		return nil, fmt.Errorf("query must start with SELECT")
+	}
+	for i, c := range found {
+		end := len(s)
+		if i+1 < len(found) {
+			end = found[i+1].start
+		}
+		if _, dup := clauses[c.name]; dup {
+			return nil, fmt.Errorf("duplicate %s", strings.ToUpper(c.name))
+		}
+		clauses[c.name] = strings.TrimSpace(s[c.end:end])
+	}
+	q := &Query{OrderBy: -1}
+	sel, ok := clauses["select"]
+	if !ok || sel == "" {
+		return nil, fmt.Errorf("missing SELECT columns")
+	}
+	for _, part := range strings.Split(sel, ",") {
+		part = strings.TrimSpace(part)
+		if m := columnRe.FindStringSubmatch(part); m != nil {
+			field := strings.TrimSpace(m[2])
+			fn := strings.ToLower(m[1])
+			if field == "" || (field == "*" && fn != "count") {
+				return nil, fmt.Errorf("invalid column %q", part)
+			}
+			q.Columns = append(q.Columns, Column{Func: fn, Field: fields.Path(field)})
+			continue
+		}
+		if part == "" || strings.ContainsAny(part, "() ") {
+			return nil, fmt.Errorf("invalid column %q", part)
+		}
+		q.Columns = append(q.Columns, Column{Field: fields.Path(part)})
+	}
+	if where, ok := clauses["where"]; ok {
+		expr, err := fields.ParseExpr(where)
+		if err != nil {
+			return nil, fmt.Errorf("WHERE: %w", err)
+		}
+		q.Where = expr
+	}
+	if group, ok := clauses["group by"]; ok {
+		for _, g := range strings.Split(group, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				q.GroupBy = append(q.GroupBy, fields.Path(g))
+			}
+		}
+	}
+	if order, ok := clauses["order by"]; ok {
+		parts := strings.Fields(order)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("invalid ORDER BY %q", order)
+		}
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "desc":
+				q.Desc = true
+			case "asc":
+			default:
+				return nil, fmt.Errorf("invalid ORDER BY %q", order)
+			}
+		}
+		q.OrderBy = q.columnIndex(parts[0])
+		if q.OrderBy < 0 {
+			return nil, fmt.Errorf("ORDER BY %s is not a selected column", parts[0])
+		}
+	}
+	if limit, ok := clauses["limit"]; ok {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid LIMIT %q", limit)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// columnIndex finds a column by 1-based position or by name.
+func (q *Query) columnIndex(name string) int {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n >= 1 && n <= len(q.Columns) {
+			return n - 1
+		}
+		return -1
+	}
+	name = strings.ToLower(strings.ReplaceAll(fields.Path(name), " ", ""))
+	for i, c := range q.Columns {
+		if strings.ToLower(c.String()) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Query) aggregated() bool {
+	if len(q.GroupBy) > 0 {
+		return true
+	}
+	for _, c := range q.Columns {
+		if c.Func != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type group struct {
+	first  int
+	fs     []fields.Field
+	counts []int
+	nums   []int
+	sums   []float64
+	mins   []string
+	maxs   []string
+}
+
+// Run executes the query over n lines read with line(i), so callers can
+// run it over a filtered view.
+func (q *Query) Run(n int, line func(int) string) Result {
+	res := Result{}
+	for _, c := range q.Columns {
+		res.Header = append(res.Header, c.String())
+	}
+	var groups []*group
+	byKey := map[string]*group{}
+	for i := 0; i < n; i++ {
+		fs := fields.Parse(line(i))
+		if fs == nil || (q.Where != nil && !q.Where.Match(fs)) {
+			continue
+		}
+		if !q.aggregated() {
+			row := make([]string, len(q.Columns))
+			for c, col := range q.Columns {
+				row[c], _ = fields.Get(fs, col.Field)
+			}
+			res.Rows = append(res.Rows, row)
+			res.Lines = append(res.Lines, i)
+			continue
+		}
+		keyParts := make([]string, len(q.GroupBy))
+		for k, g := range q.GroupBy {
+			keyParts[k], _ = fields.Get(fs, g)
+		}
+		key := strings.Join(keyParts, "\x00")
+		g := byKey[key]
+		if g == nil {
+			g = &group{
+				first:  i,
+				fs:     fs,
+				counts: make([]int, len(q.Columns)),
+				nums:   make([]int, len(q.Columns)),
+				sums:   make([]float64, len(q.Columns)),
+				mins:   make([]string, len(q.Columns)),
+				maxs:   make([]string, len(q.Columns)),
+			}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.add(q.Columns, fs)
+	}
+	for _, g := range groups {
+		res.Rows = append(res.Rows, g.row(q.Columns))
+		res.Lines = append(res.Lines, g.first)
+	}
+	q.sort(&res)
+	if q.Limit > 0 && len(res.Rows) > q.Limit {
+		res.Rows = res.Rows[:q.Limit]
+		res.Lines = res.Lines[:q.Limit]
+	}
+	return res
+}
+
+func (g *group) add(cols []Column, fs []fields.Field) {
+	for c, col := range cols {
+		if col.Func == "" {
+			continue
+		}
+		if col.Field == "*" {
+			g.counts[c]++
+			continue
+		}
+		value, ok := fields.Get(fs, col.Field)
+		if !ok {
+			continue
+		}
+		g.counts[c]++
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			g.sums[c] += f
+			g.nums[c]++
+		}
+		if g.counts[c] == 1 || fields.Compare(value, g.mins[c]) < 0 {
+			g.mins[c] = value
+		}
+		if g.counts[c] == 1 || fields.Compare(value, g.maxs[c]) > 0 {
+			g.maxs[c] = value
+		}
+	}
+}
+
+func (g *group) row(cols []Column) []string {
+	row := make([]string, len(cols))
+	for c, col := range cols {
+		switch col.Func {
+		case "":
+			row[c], _ = fields.Get(g.fs, col.Field)
+		case "count":
+			row[c] = strconv.Itoa(g.counts[c])
+		case "sum":
+			row[c] = strconv.FormatFloat(g.sums[c], 'f', -1, 64)
+		case "avg":
+			if g.nums[c] > 0 {
+				row[c] = strconv.FormatFloat(g.sums[c]/float64(g.nums[c]), 'f', 2, 64)
+			}
+		case "min":
+			row[c] = g.mins[c]
+		case "max":
+			row[c] = g.maxs[c]
+		}
+	}
+	return row
+}
+
+func (q *Query) sort(res *Result) {
+	if q.OrderBy < 0 {
+		return
+	}
+	idx := make([]int, len(res.Rows))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		c := fields.Compare(res.Rows[idx[a]][q.OrderBy], res.Rows[idx[b]][q.OrderBy])
+		if q.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	rows := make([][]string, len(idx))
+	lines := make([]int, len(idx))
+	for i, j := range idx {
+		rows[i] = res.Rows[j]
+		lines[i] = res.Lines[j]
+	}
+	res.Rows, res.Lines = rows, lines
+}
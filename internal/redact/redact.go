@@ -0,0 +1,80 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Mask replaces every redacted value.
+const Mask = "****"
+
+// builtin patterns mask their first capture group, so the key that names a
+// secret stays readable.
+var builtin = []string{
+	`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`,
+	`(?i)\bauthorization["']?\s*[:=]\s*["']?basic\s+([A-Za-z0-9+/]+=*)`,
+	`(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|secret[_-]?key|client[_-]?secret|aws_secret_access_key)["']?\s*[:=]\s*["']?([^\s"',;&]+)`,
+	`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`,
+	`\b(eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+)`,
+	`://[^/\s:@]+:([^/\s@]+)@`,
+}
+
+// Redactor masks secrets in log lines.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New builds a redactor from the built-in patterns and extra user
+// patterns. A user pattern with a capture group masks only that group.
+func New(extra []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range builtin {
+		r.patterns = append(r.patterns, regexp.MustCompile(p))
+	}
+	for _, p := range extra {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Line returns line with every secret replaced by Mask.
+func (r *Redactor) Line(line string) string {
+	for _, re := range r.patterns {
+		line = maskMatches(re, line)
+	}
+	return line
+}
+
+// Lines redacts lines in place.
+func (r *Redactor) Lines(lines []string) {
+	for i, line := range lines {
+		lines[i] = r.Line(line)
+	}
+}
+
+func maskMatches(re *regexp.Regexp, line string) string {
+	matches := re.FindAllStringSubmatchIndex(line, -1)
+	if matches == nil {
+		return line
+	}
+	out := make([]byte, 0, len(line))
+	pos := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		if start < pos {
+			continue
+		}
+		out = append(out, line[pos:start]...)
+		out = append(out, Mask...)
+		pos = end
+	}
+	out = append(out, line[pos:]...)
+	return string(out)
+}
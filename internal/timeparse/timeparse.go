@@ -0,0 +1,180 @@
+package timeparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type format struct {
+	re      *regexp.Regexp
+	layouts []string
+	syslog  bool
+}
+
+var builtin = []format{
+	{
+		re: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+		layouts: []string{
+			"2006-01-02T15:04:05.999999999Z07:00",
+			"2006-01-02T15:04:05.999999999Z0700",
+			"2006-01-02T15:04:05.999999999",
+		},
+	},
+	{
+		// nginx/apache common log format: 01/Feb/2026:10:00:00 +0000
+		re:      regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}(?: [+-]\d{4})?`),
+		layouts: []string{"02/Jan/2006:15:04:05 -0700", "02/Jan/2006:15:04:05"},
+	},
+	{
+		re:      regexp.MustCompile(`\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}(?:\.\d+)?\b`),
+		layouts: []string{"Jan _2 15:04:05.999999999"},
+		syslog:  true,
+	},
+}
+
+var (
+	epochRe  = regexp.MustCompile(`^\s*(\d{10}(?:\.\d+)?|\d{13}|\d{16}|\d{19})\b`)
+	epochKey = regexp.MustCompile(`(?i)"?\b(?:ts|time|timestamp|@timestamp)"?\s*[:=]\s*"?(\d{10}(?:\.\d+)?|\d{13}|\d{16}|\d{19})\b`)
+)
+
+// Parser extracts the first timestamp from log lines. Custom layouts use Go
+// reference-time syntax and are tried before the built-in formats.
+type Parser struct {
+	layouts []string
+	now     time.Time
+}
+
+func NewParser(layouts []string) *Parser {
+	return &Parser{layouts: layouts, now: time.Now()}
+}
+
+func (p *Parser) Parse(line string) (time.Time, bool) {
+	if t, ok := p.parseCustom(line); ok {
+		return t, true
+	}
+	for _, f := range builtin {
+		m := f.re.FindString(line)
+		if m == "" {
+			continue
+		}
+		m = strings.Replace(m, ",", ".", 1)
+		if len(m) > 10 && m[10] == ' ' && f.re == builtin[0].re {
+			m = m[:10] + "T" + m[11:]
+		}
+		if f.syslog {
+			m = strings.Join(strings.Fields(m), " ")
+			if len(m) > 4 && m[4] != ' ' && m[5] == ' ' {
+				m = m[:4] + " " + m[4:]
+			}
+		}
+		for _, layout := range f.layouts {
+			t, err := time.Parse(layout, m)
+			if err != nil {
+				continue
+			}
+			if f.syslog {
+				t = t.AddDate(p.now.Year(), 0, 0)
+				if t.After(p.now.AddDate(0, 0, 1)) {
+					t = t.AddDate(-1, 0, 0)
+				}
+			}
+			return t, true
+		}
+	}
+	if m := epochKey.FindStringSubmatch(line); m != nil {
+		return ParseEpoch(m[1])
+	}
+	if m := epochRe.FindStringSubmatch(line); m != nil {
+		return ParseEpoch(m[1])
+	}
+	return time.Time{}, false
+}
+
+func (p *Parser) parseCustom(line string) (time.Time, bool) {
+	if len(p.layouts) == 0 {
+		return time.Time{}, false
+	}
+	limit := len(line)
+	if limit > 128 {
+		limit = 128
+	}
+	for start := 0; start < limit; start++ {
+		if start > 0 && line[start-1] != ' ' && line[start-1] != '[' && line[start-1] != '"' && line[start-1] != '=' {
+			continue
+		}
+		for _, layout := range p.layouts {
+			end := start + len(layout)
+			if end > len(line) {
+				continue
+			}
+			if t, err := time.Parse(layout, line[start:end]); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseEpoch interprets s as a Unix timestamp in seconds, milliseconds,
+// microseconds or nanoseconds depending on its number of digits.
+func ParseEpoch(s string) (time.Time, bool) {
+	if strings.Contains(s, ".") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)).UTC(), true
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch {
+	case len(s) <= 10:
+		return time.Unix(n, 0).UTC(), true
+	case len(s) <= 13:
+		return time.UnixMilli(n).UTC(), true
+	case len(s) <= 16:
+		return time.UnixMicro(n).UTC(), true
+	}
+	return time.Unix(0, n).UTC(), true
+}
+
+var boundLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var clockLayouts = []string{"15:04:05.999999999", "15:04"}
+
+// ParseBound parses a user-supplied point in time. Values without a date,
+// such as "10:05", are taken on the same day as ref and in its location.
+func ParseBound(s string, ref time.Time) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	loc := ref.Location()
+	for _, layout := range boundLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range clockLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			y, m, d := ref.Date()
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc), true
+		}
+	}
+	if t, ok := ParseEpoch(s); ok && len(s) >= 10 {
+		return t, true
+	}
+	return time.Time{}, false
+}
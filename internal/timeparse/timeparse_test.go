@@ -0,0 +1,82 @@
+package timeparse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		layouts []string
+		line    string
+		want    time.Time
+		ok      bool
+	}{
+		{nil, "2026-02-01T10:00:00Z INFO up", time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC), true},
+		{nil, "2026-02-01 10:00:00,250 INFO up", time.Date(2026, 2, 1, 10, 0, 0, 250e6, time.UTC), true},
+		{nil, "2026-02-01T10:00:00.5+02:00 x", time.Date(2026, 2, 1, 8, 0, 0, 500e6, time.UTC), true},
+		{nil, `1.2.3.4 - - [01/Feb/2026:10:00:00 +0000] "GET /"`, time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC), true},
+		{nil, "Feb  1 10:00:00 host sshd[1]: ok", time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC), true},
+		// A syslog date later than tomorrow is from last year.
+		{nil, "Dec 31 23:00:00 host x", time.Date(2025, 12, 31, 23, 0, 0, 0, time.UTC), true},
+		{nil, `{"ts":1767225600,"msg":"x"}`, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{nil, "1767225600123 started", time.Date(2026, 1, 1, 0, 0, 0, 123e6, time.UTC), true},
+		{[]string{"02.01.2006 15:04"}, "[01.02.2026 10:30] x", time.Date(2026, 2, 1, 10, 30, 0, 0, time.UTC), true},
+		{nil, "no time here", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		p := NewParser(tt.layouts)
+		p.now = now
+		got, ok := p.Parse(tt.line)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("Parse(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseEpoch(t *testing.T) {
+	want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s    string
+		want time.Time
+		ok   bool
+	}{
+		{"1767225600", want, true},
+		{"1767225600.25", want.Add(250 * time.Millisecond), true},
+		{"1767225600000", want, true},
+		{"1767225600000000", want, true},
+		{"1767225600000000000", want, true},
+		{"soon", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseEpoch(tt.s)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("ParseEpoch(%q) = %v, %v, want %v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseBound(t *testing.T) {
+	ref := time.Date(2026, 2, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s    string
+		want time.Time
+		ok   bool
+	}{
+		{"10:05", time.Date(2026, 2, 1, 10, 5, 0, 0, time.UTC), true},
+		{"10:05:30.5", time.Date(2026, 2, 1, 10, 5, 30, 500e6, time.UTC), true},
+		{"2026-01-31", time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), true},
+		{"2026-01-31 23:59", time.Date(2026, 1, 31, 23, 59, 0, 0, time.UTC), true},
+		{"2026-01-31T23:59:00+01:00", time.Date(2026, 1, 31, 22, 59, 0, 0, time.UTC), true},
+		{"1767225600", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"42", time.Time{}, false},
+		{" ", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseBound(tt.s, ref)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("ParseBound(%q) = %v, %v, want %v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ui
+
+import (
+	"strings"
+
+	"tilo/internal/color"
+)
+
+// parseANSI strips the escape sequences from Lines[start:] in ANSI mode,
+// keeping the colors they described to draw under tilo's own.
+func (v *Viewer) parseANSI(start int) {
+	if !v.ANSI {
+		return
+	}
+	for i := start; i < len(v.Lines); i++ {
+		if strings.IndexByte(v.Lines[i], '\x1b') < 0 {
+			continue
+		}
+		plain, spans := color.ParseANSI(v.Lines[i])
+		v.Lines[i] = plain
+		if len(spans) > 0 {
+			if v.ansiSpans == nil {
+				v.ansiSpans = map[int][]color.ANSISpan{}
+			}
+			v.ansiSpans[i] = spans
+		}
+	}
+}
+
+// inputColors returns the input colors of the part of line i that starts at
+// rune column startCol and reads text.
+func (v *Viewer) inputColors(text string, i int, startCol int) []color.ANSISpan {
+	spans := v.ansiSpans[v.lineIndex(i)]
+	if len(spans) == 0 {
+		return nil
+	}
+	offset := byteOffset(v.line(i), startCol)
+	return color.SliceANSI(spans, offset, offset+len(text))
+}
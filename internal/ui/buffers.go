@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tilo/internal/color"
+)
+
+// Buffer is one input shown in the viewer, such as a file argument. Lines
+// arriving on Follow are appended to it. LineBase is the number of input
+// lines before Lines[0] when only part of the input was loaded. Notes
+// carries the state of the source, such as "waiting for writer", which
+// stays in the status bar until an empty note clears it. Rules, when set,
+// replace the color rules passed to Run for this buffer.
+type Buffer struct {
+	Name     string
+	Lines    []string
+	LineBase int
+	Follow   <-chan []string
+	Notes    <-chan string
+	Rules    []color.Rule
+}
+
+// bufferList holds a viewer per buffer; only those in windows are drawn
+// and only the focused window receives keys, the others keep their own
+// cursor, filters and search.
+type bufferList struct {
+	viewers  []*Viewer
+	current  int
+	windows  []*Viewer
+	focus    int
+	vertical bool
+	opts     Options
+	async    chan func()
+	reload   func(names []string) ([][]color.Rule, error)
+	mouse    bool
+	tabs     bool
+	tabSpans []tabSpan
+}
+
+type bufferBatch struct {
+	buffer int
+	lines  []string
+	note   *string
+}
+
+// followBuffers forwards the lines and notes of every followed buffer to a
+// single channel, tagged with the buffer they belong to.
+func followBuffers(buffers []Buffer) <-chan bufferBatch {
+	out := make(chan bufferBatch, 16)
+	var wg sync.WaitGroup
+	for i, buf := range buffers {
+		if buf.Follow != nil {
+			wg.Add(1)
+			go func(i int, in <-chan []string) {
+				defer wg.Done()
+				for lines := range in {
+					out <- bufferBatch{buffer: i, lines: lines}
+				}
+			}(i, buf.Follow)
+		}
+		if buf.Notes != nil {
+			wg.Add(1)
+			go func(i int, in <-chan string) {
+				defer wg.Done()
+				for note := range in {
+					out <- bufferBatch{buffer: i, note: &note}
+				}
+			}(i, buf.Notes)
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+func (v *Viewer) switchBuffer(i int) {
+	list := v.buffers
+	if list == nil || i < 0 || i >= len(list.viewers) {
+		v.Status = "no such buffer"
+		return
+	}
+	list.current = i
+	list.windows[list.focus] = list.viewerFor(i, list.focus)
+	next := list.windows[list.focus]
+	next.Status = fmt.Sprintf("%s (%d lines)", next.Name, len(next.Lines))
+}
+
+func (v *Viewer) nextBuffer(dir int) {
+	list := v.buffers
+	if list == nil || len(list.viewers) < 2 {
+		v.Status = "only one buffer"
+		return
+	}
+	n := len(list.viewers)
+	v.switchBuffer(((list.current+dir)%n + n) % n)
+}
+
+// pickBuffer lists the buffers and switches to the chosen one.
+func (v *Viewer) pickBuffer(reader *bufio.Reader) {
+	list := v.buffers
+	if list == nil {
+		return
+	}
+	width := 0
+	for _, b := range list.viewers {
+		width = max(width, len(b.Name))
+	}
+	rows := make([]string, len(list.viewers))
+	for i, b := range list.viewers {
+		rows[i] = fmt.Sprintf(" %2d  %-*s  %d lines", i+1, width, b.Name, len(b.Lines))
+	}
+	if choice, ok := v.selectList(reader, "Buffers", rows, list.current); ok {
+		v.switchBuffer(choice)
+	}
+}
+
+// gotoBuffer switches to the buffer given by number or by (part of) its
+// name.
+func (v *Viewer) gotoBuffer(arg string) {
+	if i, ok := v.buffers.findBuffer(arg); ok {
+		v.switchBuffer(i)
+		return
+	}
+	v.Status = "no such buffer: " + arg
+}
+
+// findBuffer returns the buffer given by number or by (part of) its name.
+func (l *bufferList) findBuffer(arg string) (int, bool) {
+	arg = strings.TrimSpace(arg)
+	if n, err := strconv.Atoi(arg); err == nil {
+		return n - 1, n >= 1 && n <= len(l.viewers)
+	}
+	for i, b := range l.viewers {
+		if strings.Contains(b.Name, arg) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (v *Viewer) bufferStatus() string {
+	list := v.buffers
+	if list == nil || len(list.viewers) < 2 {
+		return ""
+	}
+	return fmt.Sprintf("[%d/%d %s]", list.current+1, len(list.viewers), v.Name)
+}
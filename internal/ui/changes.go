@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"unicode/utf8"
+
+	"tilo/internal/color"
+)
+
+func (v *Viewer) toggleChanges() {
+	v.ShowChanges = !v.ShowChanges
+	if v.ShowChanges {
+		v.Status = "highlighting changes from the previous line"
+	} else {
+		v.Status = "changes off"
+	}
+}
+
+// changeSpans marks where text, the part of view row lineIdx starting at
+// rune column startCol, differs from the same columns of the row above,
+// like watch -d. Lines of polled output then show only what moved.
+func (v *Viewer) changeSpans(text string, lineIdx int, startCol int) []color.Span {
+	if !v.ShowChanges || lineIdx <= 0 {
+		return nil
+	}
+	prev := []rune(v.line(lineIdx - 1))
+	var spans []color.Span
+	col := startCol
+	for off := 0; off < len(text); col++ {
+		r, size := utf8.DecodeRuneInString(text[off:])
+		if col >= len(prev) || prev[col] != r {
+			if n := len(spans); n > 0 && spans[n-1].End == off {
+				spans[n-1].End = off + size
+			} else {
+				spans = append(spans, color.Span{Start: off, End: off + size, Style: "reverse"})
+			}
+		}
+		off += size
+	}
+	return spans
+}
@@ -0,0 +1,177 @@
+package ui
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+func (v *Viewer) commandPrompt(reader *bufio.Reader) {
+	input, canceled := v.prompt(reader, ":", v.CommandHistory, nil)
+	if canceled {
+		return
+	}
+	defer v.historyStatus(v.CommandHistory.Add(input))
+	v.runCommand(reader, input)
+}
+
+func (v *Viewer) runCommand(reader *bufio.Reader, input string) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return
+	}
+	if n, err := strconv.Atoi(input); err == nil {
+		v.gotoLine(n)
+		return
+	}
+	if pct, ok := strings.CutSuffix(input, "%"); ok {
+		if n, err := strconv.Atoi(pct); err == nil {
+			v.gotoPercent(n)
+			return
+		}
+	}
+	name, arg := splitCommand(input)
+	switch strings.ToLower(name) {
+	case "select":
+		v.runQuery(reader, input)
+		return
+	}
+	switch name {
+	case "filter":
+		v.pushFilter(arg, false)
+	case "filter!":
+		v.pushFilter(arg, true)
+	case "where":
+		v.pushWhere(arg)
+	case "unfilter":
+		v.popFilter()
+	case "nofilter":
+		v.clearFilters()
+	case "level":
+		v.setMinLevelName(arg)
+	case "goto":
+		v.gotoTime(arg)
+	case "gap":
+		v.setGapThreshold(arg)
+	case "time":
+		v.setTimeRange(arg)
+	case "records":
+		v.toggleRecordMode()
+	case "context":
+		v.setFilterContext(arg)
+	case "hist":
+		v.showHistogram(reader)
+	case "freq":
+		v.showFrequencies(reader)
+	case "count":
+		v.countMatches(arg)
+	case "table":
+		v.setColumns(arg)
+	case "extract":
+		v.extract(arg)
+	case "sort":
+		v.setSort(arg, false)
+	case "sort!":
+		v.setSort(arg, true)
+	case "nosort":
+		v.clearSort()
+	case "bn", "bnext":
+		v.nextBuffer(1)
+	case "bp", "bprev":
+		v.nextBuffer(-1)
+	case "b", "buffer":
+		if arg == "" {
+			v.pickBuffer(reader)
+		} else {
+			v.gotoBuffer(arg)
+		}
+	case "ls", "buffers":
+		v.pickBuffer(reader)
+	case "inspect":
+		v.inspect(reader)
+	case "spikes":
+		v.toggleSpikes()
+	case "watch":
+		v.addWatch(arg)
+	case "stats":
+		v.showStats(arg)
+	case "http":
+		v.toggleHTTPStats()
+	case "uniq":
+		v.toggleDedupe()
+	case "dupes":
+		v.toggleDimDupes()
+	case "changes":
+		v.toggleChanges()
+	case "rainbow":
+		v.setRainbow(arg)
+	case "mouse":
+		v.Status = v.buffers.toggleMouse()
+	case "columns":
+		v.editColumns(reader)
+	case "reload":
+		v.Status = v.buffers.reloadRules()
+	case "copen":
+		v.focusQuickfix(reader)
+	case "cclose":
+		v.closeQuickfix()
+	case "cn", "cnext":
+		v.stepQuickfix(1)
+	case "cp", "cprev":
+		v.stepQuickfix(-1)
+	case "split", "sp":
+		v.split(arg, false)
+	case "vsplit", "vs":
+		v.split(arg, true)
+	case "close", "clo":
+		v.closeWindow()
+	case "only", "on":
+		v.onlyWindow()
+	case "tabs":
+		v.Status = v.buffers.toggleTabs()
+	case "marks":
+		v.showMarks(reader)
+	case "help", "h":
+		v.showHelp(reader)
+	default:
+		v.Status = "unknown command: " + name
+	}
+}
+
+func splitCommand(input string) (string, string) {
+	name, arg, _ := strings.Cut(input, " ")
+	return name, strings.TrimSpace(arg)
+}
+
+// gotoLine moves to line n of the input, or the next visible line after it
+// when a filter hides it.
+func (v *Viewer) gotoLine(n int) {
+	idx := max(n-v.LineBase-1, 0)
+	v.jumpTo(v.viewIndex(idx))
+}
+
+func (v *Viewer) jumpTo(row int) {
+	v.rememberJump()
+	v.Cursor = row
+	v.CursorCol = 0
+	v.GoalCol = 0
+	v.clampCursor()
+	if v.Follow {
+		v.FollowAuto = false
+	}
+	v.Status = ""
+}
+
+func (v *Viewer) gotoPercent(pct int) {
+	if pct < 0 {
+		pct = 0
+	}
+	if pct > 100 {
+		pct = 100
+	}
+	row := (pct*v.lineCount()+99)/100 - 1
+	if row < 0 {
+		row = 0
+	}
+	v.jumpTo(row)
+}
@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"tilo/internal/color"
+)
+
+var idPatterns = []*regexp.Regexp{
+	// key=value pairs whose key names an id, e.g. req_id=ab12, requestId=ab12
+	// or "trace_id":"ab12"; id must be the whole key or its last word, so
+	// valid= and paid= are not ids.
+	regexp.MustCompile(`\b(?:(?:[\w.-]*[_.-])?(?i:id|uuid|trace|span)|[a-z][a-zA-Z0-9]*(?:Id|ID))"?\s*[:=]\s*"?([^\s",;}\]]+)`),
+	regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`),
+	regexp.MustCompile(`(?i)\b[0-9a-f]{8,}\b`),
+	regexp.MustCompile(`[\w.-]*\d[\w.-]*`),
+}
+
+// idUnderCursor returns the id-like token at the cursor, preferring the
+// value of key=value pairs, then UUIDs, hex strings and other tokens that
+// contain digits.
+func (v *Viewer) idUnderCursor() string {
+	line := v.line(v.Cursor)
+	runes := []rune(line)
+	if v.CursorCol >= len(runes) {
+		return ""
+	}
+	pos := len(string(runes[:v.CursorCol]))
+	for _, re := range idPatterns {
+		for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+			if pos < m[0] || pos >= m[1] {
+				continue
+			}
+			if len(m) >= 4 && m[2] >= 0 {
+				return line[m[2]:m[3]]
+			}
+			return line[m[0]:m[1]]
+		}
+	}
+	return ""
+}
+
+func (v *Viewer) correlateID() {
+	id := v.idUnderCursor()
+	if id == "" {
+		if v.TraceID != "" {
+			v.TraceID = ""
+			v.Status = "id cleared"
+			return
+		}
+		v.Status = "no id under cursor"
+		return
+	}
+	v.TraceID = id
+	lines := 0
+	for i := range v.Lines {
+		if strings.Contains(v.Lines[i], id) {
+			lines++
+		}
+	}
+	v.Status = fmt.Sprintf("id %s: %d lines ([/] to jump)", id, lines)
+}
+
+func (v *Viewer) nextIDLine(dir int) {
+	if v.TraceID == "" {
+		v.Status = "no id selected (use *)"
+		return
+	}
+	for i := v.Cursor + dir; i >= 0 && i < v.lineCount(); i += dir {
+		line := v.line(i)
+		if idx := strings.Index(line, v.TraceID); idx >= 0 {
+			v.Cursor = i
+			v.CursorCol = utf8.RuneCountInString(line[:idx])
+			v.GoalCol = v.CursorCol
+			v.clampCursor()
+			if v.Follow {
+				v.FollowAuto = false
+			}
+			v.Status = "id " + v.TraceID
+			return
+		}
+	}
+	v.Status = "no more lines with id " + v.TraceID
+}
+
+func (v *Viewer) idSpans(text string) []color.Span {
+	if v.TraceID == "" {
+		return nil
+	}
+	var spans []color.Span
+	for start := 0; ; {
+		idx := strings.Index(text[start:], v.TraceID)
+		if idx < 0 {
+			break
+		}
+		s := start + idx
+		spans = append(spans, color.Span{Start: s, End: s + len(v.TraceID), Color: "cyan", Style: "reverse"})
+		start = s + len(v.TraceID)
+	}
+	return spans
+}
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+
+	"tilo/internal/color"
+)
+
+// dupeRules paint a duplicate line gray; searches and highlights still
+// show on top of it.
+var dupeRules = []color.Rule{{Name: "duplicate", Regex: regexp.MustCompile(`.+`), Color: "gray", Enabled: true}}
+
+func (v *Viewer) toggleDedupe() {
+	v.Dedupe = !v.Dedupe
+	v.rebuildView()
+	if v.Dedupe {
+		v.Status = "collapsing repeated lines"
+	} else {
+		v.Status = "showing repeated lines"
+	}
+}
+
+// dedupeView drops view rows from index from onwards that repeat the line
+// right before them, counting the repeats on the row that stays.
+func (v *Viewer) dedupeView(from int) {
+	kept := v.View[:from]
+	for _, idx := range v.View[from:] {
+		if n := len(kept); n > 0 {
+			prev := kept[n-1]
+			if v.Lines[idx] == v.Lines[prev] {
+				if v.Repeats == nil {
+					v.Repeats = map[int]int{}
+				}
+				if v.Repeats[prev] == 0 {
+					v.Repeats[prev] = 1
+				}
+				v.Repeats[prev]++
+				continue
+			}
+		}
+		kept = append(kept, idx)
+	}
+	v.View = kept
+}
+
+// repeatMarker is appended to a line that stands for a run of repeats.
+func (v *Viewer) repeatMarker(lineIdx int) string {
+	n := v.Repeats[v.lineIndex(lineIdx)]
+	if n < 2 {
+		return ""
+	}
+	return color.Wrap(fmt.Sprintf(" (x%d)", n), "gray", "bold")
+}
+
+func (v *Viewer) toggleDimDupes() {
+	v.DimDupes = !v.DimDupes
+	if v.DimDupes {
+		v.Status = "dimming duplicate lines"
+	} else {
+		v.Status = "showing duplicate lines"
+	}
+}
+
+// isDupe reports whether Lines[idx] is an exact copy of an earlier line.
+func (v *Viewer) isDupe(idx int) bool {
+	if idx < 0 || idx >= len(v.Lines) {
+		return false
+	}
+	if v.dupeSeen == nil {
+		v.dupeSeen = map[string]struct{}{}
+	}
+	for i := len(v.dupes); i <= idx; i++ {
+		_, seen := v.dupeSeen[v.Lines[i]]
+		v.dupes = append(v.dupes, seen)
+		v.dupeSeen[v.Lines[i]] = struct{}{}
+	}
+	return v.dupes[idx]
+}
@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+const dnsTimeout = 3 * time.Second
+
+// resolveIP looks up the hostname of the IP under the cursor in the
+// background and reports it in the status bar when it arrives. Answers,
+// including failures, are cached for the session.
+func (v *Viewer) resolveIP() {
+	ip := v.ipUnderCursor()
+	if ip == "" {
+		v.Status = "no IP under cursor"
+		return
+	}
+	if host, ok := v.hostnames[ip]; ok {
+		v.Status = ip + " → " + host
+		return
+	}
+	v.Status = "resolving " + ip + "…"
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+		defer cancel()
+		host := "no PTR record"
+		names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+		switch {
+		case ctx.Err() != nil:
+			host = "lookup timed out"
+		case err == nil && len(names) > 0:
+			host = strings.TrimSuffix(names[0], ".")
+		}
+		v.async <- func() {
+			if v.hostnames == nil {
+				v.hostnames = map[string]string{}
+			}
+			v.hostnames[ip] = host
+			v.Status = ip + " → " + host
+		}
+	}()
+}
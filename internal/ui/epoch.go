@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"regexp"
+	"time"
+
+	"tilo/internal/timeparse"
+)
+
+var numberRe = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// numberUnderCursor returns the number at the cursor, if any.
+func (v *Viewer) numberUnderCursor() string {
+	line := v.line(v.Cursor)
+	runes := []rune(line)
+	if v.CursorCol >= len(runes) {
+		return ""
+	}
+	pos := len(string(runes[:v.CursorCol]))
+	for _, m := range numberRe.FindAllStringIndex(line, -1) {
+		if pos >= m[0] && pos < m[1] {
+			return line[m[0]:m[1]]
+		}
+	}
+	return ""
+}
+
+// decodeEpoch shows the number under the cursor as a time, taking its unit
+// (s, ms, µs, ns) from the number of digits.
+func (v *Viewer) decodeEpoch() {
+	s := v.numberUnderCursor()
+	if s == "" {
+		v.Status = "no number under cursor"
+		return
+	}
+	t, ok := timeparse.ParseEpoch(s)
+	if !ok {
+		v.Status = s + ": not a timestamp"
+		return
+	}
+	v.Status = s + ": " + t.Format(time.RFC3339Nano) + " (" + t.Local().Format("2006-01-02 15:04:05 MST") + ")"
+}
@@ -0,0 +1,274 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"tilo/internal/fields"
+	"tilo/internal/level"
+)
+
+type Filter struct {
+	Pattern string
+	Regex   *regexp.Regexp
+	Where   fields.Expr
+	Invert  bool
+}
+
+func (f Filter) match(line string) bool {
+	if f.Where != nil {
+		return f.Where.Match(fields.Parse(line)) != f.Invert
+	}
+	return f.Regex.MatchString(line) != f.Invert
+}
+
+func (f Filter) String() string {
+	if f.Where != nil {
+		return "where " + f.Pattern
+	}
+	if f.Invert {
+		return "&!" + f.Pattern
+	}
+	return "&" + f.Pattern
+}
+
+func (v *Viewer) filterPrompt(reader *bufio.Reader) {
+	pattern, canceled := v.prompt(reader, "&", v.History, nil)
+	if canceled {
+		return
+	}
+	defer v.historyStatus(v.History.Add(pattern))
+	invert := false
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(pattern), "!"); ok {
+		pattern = rest
+		invert = true
+	}
+	v.pushFilter(pattern, invert)
+}
+
+// pushFilter narrows the current view further with another pattern.
+func (v *Viewer) pushFilter(pattern string, invert bool) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		v.Status = "empty filter"
+		return
+	}
+	re, err := v.compileQuery(pattern)
+	if err != nil {
+		v.Status = fmt.Sprintf("invalid regex: %v", err)
+		return
+	}
+	v.addFilter(Filter{Pattern: pattern, Regex: re, Invert: invert})
+	v.Status = ""
+	if v.lineCount() == 0 {
+		v.Status = "no matching lines"
+	}
+}
+
+// pushWhere narrows the view to structured lines whose fields match expr.
+func (v *Viewer) pushWhere(expr string) {
+	expr = strings.TrimSpace(expr)
+	where, err := fields.ParseExpr(expr)
+	if err != nil {
+		v.Status = fmt.Sprintf("invalid expression: %v", err)
+		return
+	}
+	v.addFilter(Filter{Pattern: expr, Where: where})
+	v.Status = ""
+	if v.lineCount() == 0 {
+		v.Status = "no matching lines"
+	}
+}
+
+func (v *Viewer) addFilter(f Filter) {
+	if v.FilterContext > 0 || v.RecordMode || v.SortKey != SortNone {
+		v.Filters = append(v.Filters, f)
+		v.rebuildView()
+		return
+	}
+	orig := v.lineIndex(v.Cursor)
+	var view []int
+	for i := 0; i < v.lineCount(); i++ {
+		idx := v.lineIndex(i)
+		if f.match(v.Lines[idx]) {
+			view = append(view, idx)
+		}
+	}
+	if view == nil {
+		view = []int{}
+	}
+	v.Filters = append(v.Filters, f)
+	v.View = view
+	v.resetView(orig)
+}
+
+func (v *Viewer) popFilter() {
+	if len(v.Filters) == 0 {
+		v.Status = "no filter"
+		return
+	}
+	v.Filters = v.Filters[:len(v.Filters)-1]
+	v.rebuildView()
+	v.Status = fmt.Sprintf("%d filters", len(v.Filters))
+}
+
+func (v *Viewer) clearFilters() {
+	if len(v.Filters) == 0 {
+		v.Status = "no filter"
+		return
+	}
+	v.Filters = nil
+	v.rebuildView()
+	v.Status = "filters cleared"
+}
+
+func (v *Viewer) filtering() bool {
+	return len(v.Filters) > 0 || v.MinLevel != level.None || v.TimeRange.active()
+}
+
+func (v *Viewer) filterMatch(idx int) bool {
+	if v.MinLevel != level.None && v.lineLevel(idx) < v.MinLevel {
+		return false
+	}
+	if v.TimeRange.active() && !v.TimeRange.contains(v.lineTime(idx)) {
+		return false
+	}
+	line := v.Lines[idx]
+	for _, f := range v.Filters {
+		if !f.match(line) {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *Viewer) rebuildView() {
+	orig := v.lineIndex(v.Cursor)
+	v.Repeats = nil
+	if !v.filtering() && len(v.Folded) == 0 && !v.Dedupe && v.SortKey == SortNone {
+		v.View = nil
+	} else {
+		v.View = []int{}
+		v.sortBase = nil
+		v.contextLeft = 0
+		v.extendView(0)
+	}
+	v.resetView(orig)
+}
+
+// resetView restores the cursor to line orig (or the next visible line)
+// after the view changed underneath it.
+func (v *Viewer) resetView(orig int) {
+	if orig >= 0 {
+		v.Cursor = v.viewIndex(orig)
+	}
+	v.Top = 0
+	v.TopSub = 0
+	v.SelectMode = SelectNone
+	v.SelectStart = nil
+	v.clampCursor()
+	v.applyGoalCol()
+	v.refreshMatches()
+}
+
+// extendView adds lines from index start onwards to a filtered view,
+// together with FilterContext lines of context around each match.
+func (v *Viewer) extendView(start int) {
+	if v.View == nil {
+		return
+	}
+	// A sorted view is extended through sortBase, the same view in input
+	// order, and the rows added there are merged in.
+	sorted := v.SortKey != SortNone
+	if sorted {
+		v.View, v.sortBase = v.sortBase, v.View
+	}
+	from := len(v.View)
+	if v.RecordMode {
+		v.extendRecordView(start)
+	} else {
+		v.extendMatchView(start)
+	}
+	if v.Dedupe {
+		v.dedupeView(from)
+	}
+	if sorted {
+		v.View, v.sortBase = v.sortBase, v.View
+		v.mergeSorted(v.sortBase[from:])
+	}
+}
+
+func (v *Viewer) extendMatchView(start int) {
+	last := -1
+	if len(v.View) > 0 {
+		last = v.View[len(v.View)-1]
+	}
+	for i := start; i < len(v.Lines); i++ {
+		if v.foldHidden(i) {
+			continue
+		}
+		if v.filterMatch(i) {
+			for j := max(i-v.FilterContext, last+1); j <= i; j++ {
+				if !v.foldHidden(j) {
+					v.View = append(v.View, j)
+				}
+			}
+			last = i
+			v.contextLeft = v.FilterContext
+		} else if v.contextLeft > 0 {
+			v.View = append(v.View, i)
+			last = i
+			v.contextLeft--
+		}
+	}
+}
+
+func (v *Viewer) setFilterContext(arg string) {
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n < 0 {
+		v.Status = "usage: context <lines>"
+		return
+	}
+	v.FilterContext = n
+	if len(v.Filters) > 0 {
+		v.rebuildView()
+	}
+	v.Status = fmt.Sprintf("context: %d lines", n)
+}
+
+// gapRows reports whether a separator row is drawn above view row i, which
+// happens when a filter shows context and row i does not follow the
+// previous one. Folds, :uniq and :sort leave gaps of their own, which get
+// no separator.
+func (v *Viewer) gapRows(i int) int {
+	if v.FilterContext == 0 || !v.filtering() || v.SortKey != SortNone || v.View == nil || i <= 0 || i >= len(v.View) {
+		return 0
+	}
+	if v.View[i] != v.View[i-1]+1 {
+		return 1
+	}
+	return 0
+}
+
+func (v *Viewer) filterStatus() string {
+	if !v.filtering() {
+		return ""
+	}
+	names := make([]string, 0, len(v.Filters)+1)
+	if v.MinLevel != level.None {
+		names = append(names, "level>="+v.MinLevel.String())
+	}
+	if v.TimeRange.active() {
+		names = append(names, "time "+v.TimeRange.String())
+	}
+	for _, f := range v.Filters {
+		names = append(names, f.String())
+	}
+	if v.RecordMode {
+		names = append(names, "(records)")
+	}
+	return fmt.Sprintf("filter %s %d/%d", strings.Join(names, " "), v.lineCount(), len(v.Lines))
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// charFind is an f/F/t/T motion, kept so ; and , can repeat it.
+type charFind struct {
+	r       rune
+	forward bool
+	till    bool
+}
+
+// findCharKey reads the character for an f/F/t/T motion and moves to it.
+func (v *Viewer) findCharKey(reader *bufio.Reader, forward, till bool, count int) {
+	r, _, err := reader.ReadRune()
+	if err != nil || r == 0x1b {
+		return
+	}
+	f := charFind{r: r, forward: forward, till: till}
+	v.lastFind = &f
+	v.findChar(f, count, false)
+}
+
+// repeatFind repeats the last f/F/t/T motion, in the other direction when
+// reverse is set (, rather than ;).
+func (v *Viewer) repeatFind(reverse bool, count int) {
+	if v.lastFind == nil {
+		return
+	}
+	f := *v.lastFind
+	if reverse {
+		f.forward = !f.forward
+	}
+	v.findChar(f, count, true)
+}
+
+// findChar moves the cursor to the count-th f.r on the cursor line, or
+// next to it for t and T. A repeated t skips the character right next to
+// the cursor, so it does not stay put.
+func (v *Viewer) findChar(f charFind, count int, repeat bool) {
+	line := []rune(v.line(v.Cursor))
+	count = max(count, 1)
+	skip := 1
+	if f.till && repeat {
+		skip = 2
+	}
+	target := -1
+	if f.forward {
+		for i := v.CursorCol + skip; i < len(line); i++ {
+			if line[i] == f.r {
+				if count--; count == 0 {
+					target = i
+					break
+				}
+			}
+		}
+		if f.till {
+			target--
+		}
+	} else {
+		for i := v.CursorCol - skip; i >= 0 && i < len(line); i-- {
+			if line[i] == f.r {
+				if count--; count == 0 {
+					target = i
+					break
+				}
+			}
+		}
+		if f.till && target >= 0 {
+			target++
+		}
+	}
+	if target < 0 {
+		v.Status = fmt.Sprintf("%q not found", f.r)
+		return
+	}
+	v.CursorCol = target
+	v.GoalCol = target
+	v.clampCursor()
+	v.Status = ""
+}
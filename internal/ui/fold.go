@@ -0,0 +1,189 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+
+	"tilo/internal/color"
+)
+
+func (v *Viewer) setFolds(folds map[int]int) {
+	v.Folded = folds
+	v.foldRanges = nil
+	for start, end := range folds {
+		v.foldRanges = append(v.foldRanges, posRange{start: start, end: end})
+	}
+	sort.Slice(v.foldRanges, func(i, j int) bool {
+		return v.foldRanges[i].start < v.foldRanges[j].start
+	})
+	merged := v.foldRanges[:0]
+	for _, r := range v.foldRanges {
+		if n := len(merged); n > 0 && r.start < merged[n-1].end {
+			if r.end > merged[n-1].end {
+				merged[n-1].end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	v.foldRanges = merged
+	v.rebuildView()
+}
+
+// foldHidden reports whether Lines[idx] is inside a fold. The first line of
+// a fold stays visible and stands in for the rest.
+func (v *Viewer) foldHidden(idx int) bool {
+	if len(v.foldRanges) == 0 {
+		return false
+	}
+	i := sort.Search(len(v.foldRanges), func(i int) bool {
+		return v.foldRanges[i].start >= idx
+	})
+	if i == 0 {
+		return false
+	}
+	r := v.foldRanges[i-1]
+	return idx > r.start && idx < r.end
+}
+
+func (v *Viewer) copyFolds() map[int]int {
+	folds := make(map[int]int, len(v.Folded)+1)
+	for start, end := range v.Folded {
+		folds[start] = end
+	}
+	return folds
+}
+
+// moveToFold puts the cursor on the first line of a fold after the view
+// has been rebuilt.
+func (v *Viewer) moveToFold(start int) {
+	v.Cursor = v.viewIndex(start)
+	v.CursorCol = 0
+	v.GoalCol = 0
+	v.Status = ""
+}
+
+func (v *Viewer) toggleFold() {
+	idx := v.lineIndex(v.Cursor)
+	if idx < 0 {
+		return
+	}
+	if _, ok := v.Folded[idx]; ok {
+		v.openFold()
+		return
+	}
+	start, end := v.recordBounds(idx)
+	if end-start < 2 {
+		v.Status = "nothing to fold"
+		return
+	}
+	folds := v.copyFolds()
+	folds[start] = end
+	v.setFolds(folds)
+	v.moveToFold(start)
+}
+
+func (v *Viewer) openFold() {
+	idx := v.lineIndex(v.Cursor)
+	if _, ok := v.Folded[idx]; !ok {
+		v.Status = "no fold here"
+		return
+	}
+	folds := v.copyFolds()
+	delete(folds, idx)
+	v.setFolds(folds)
+	v.moveToFold(idx)
+}
+
+func (v *Viewer) foldAll() {
+	folds := map[int]int{}
+	for i := 0; i < len(v.Lines); {
+		start, end := v.recordBounds(i)
+		if end-start > 1 {
+			folds[start] = end
+		}
+		i = end
+	}
+	v.setFolds(folds)
+	v.Status = fmt.Sprintf("%d folds", len(folds))
+}
+
+func (v *Viewer) unfoldAll() {
+	v.setFolds(nil)
+	v.Status = "folds opened"
+}
+
+func indentWidth(line string) (int, bool) {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width, true
+		}
+	}
+	return width, false
+}
+
+// indentBlock returns the end (exclusive) of the lines after idx that are
+// indented deeper than Lines[idx]. Blank lines belong to the block when
+// deeper lines follow them.
+func (v *Viewer) indentBlock(idx int) int {
+	base, ok := indentWidth(v.Lines[idx])
+	if !ok {
+		return idx + 1
+	}
+	end := idx + 1
+	for i := idx + 1; i < len(v.Lines); i++ {
+		width, ok := indentWidth(v.Lines[i])
+		if !ok {
+			continue
+		}
+		if width <= base {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// closeIndentFold folds the lines indented below the cursor line, or the
+// block enclosing the cursor when nothing deeper follows it.
+func (v *Viewer) closeIndentFold() {
+	idx := v.lineIndex(v.Cursor)
+	if idx < 0 {
+		return
+	}
+	start := idx
+	end := v.indentBlock(start)
+	if end-start < 2 {
+		width, _ := indentWidth(v.Lines[idx])
+		for start = idx - 1; start >= 0; start-- {
+			if w, ok := indentWidth(v.Lines[start]); ok && w < width {
+				break
+			}
+		}
+		if start < 0 {
+			v.Status = "nothing to fold"
+			return
+		}
+		end = v.indentBlock(start)
+	}
+	folds := v.copyFolds()
+	folds[start] = end
+	v.setFolds(folds)
+	v.moveToFold(start)
+}
+
+// foldMarker is appended to the first line of a fold.
+func (v *Viewer) foldMarker(lineIdx int) string {
+	idx := v.lineIndex(lineIdx)
+	end, ok := v.Folded[idx]
+	if idx < 0 || !ok {
+		return ""
+	}
+	return color.Wrap(fmt.Sprintf(" … %d lines", end-idx-1), "gray", "reverse")
+}
@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetFolds(t *testing.T) {
+	tests := []struct {
+		name   string
+		folds  map[int]int
+		ranges []posRange
+		view   []int
+	}{
+		{"none", nil, nil, nil},
+		{"one", map[int]int{2: 5}, []posRange{{2, 5}}, []int{0, 1, 2, 5, 6, 7, 8, 9}},
+		{"overlapping merge", map[int]int{0: 3, 2: 5, 7: 9}, []posRange{{0, 5}, {7, 9}}, []int{0, 5, 6, 7, 9}},
+		{"nested", map[int]int{1: 8, 3: 5}, []posRange{{1, 8}}, []int{0, 1, 8, 9}},
+		{"adjacent stay apart", map[int]int{1: 3, 3: 5}, []posRange{{1, 3}, {3, 5}}, []int{0, 1, 3, 5, 6, 7, 8, 9}},
+	}
+	for _, tt := range tests {
+		v := testViewer(numberedLines(10)...)
+		v.setFolds(tt.folds)
+		if !reflect.DeepEqual(v.foldRanges, tt.ranges) {
+			t.Errorf("%s: ranges = %v, want %v", tt.name, v.foldRanges, tt.ranges)
+		}
+		var view []int
+		if v.View != nil {
+			view = viewLines(v)
+		}
+		if !reflect.DeepEqual(view, tt.view) {
+			t.Errorf("%s: view = %v, want %v", tt.name, view, tt.view)
+		}
+	}
+}
+
+func TestToggleFold(t *testing.T) {
+	v := testViewer("start", "  at a", "  at b", "next", "  at c", "last")
+	v.Cursor = 1
+	v.toggleFold()
+	if want := map[int]int{0: 3}; !reflect.DeepEqual(v.Folded, want) {
+		t.Fatalf("folds = %v, want %v", v.Folded, want)
+	}
+	if got := viewLines(v); !reflect.DeepEqual(got, []int{0, 3, 4, 5}) {
+		t.Errorf("view = %v", got)
+	}
+	if v.lineIndex(v.Cursor) != 0 {
+		t.Errorf("cursor on line %d, want 0", v.lineIndex(v.Cursor))
+	}
+	v.Cursor = v.viewIndex(5)
+	v.toggleFold()
+	if v.Status != "nothing to fold" {
+		t.Errorf("fold of a one-line record: status %q", v.Status)
+	}
+	v.Cursor = v.viewIndex(0)
+	v.toggleFold()
+	if len(v.Folded) != 0 || v.View != nil {
+		t.Errorf("after reopening: folds %v, view %v", v.Folded, v.View)
+	}
+}
+
+func TestCloseIndentFold(t *testing.T) {
+	lines := []string{"a:", "  b:", "    c", "", "    d", "  e", "f"}
+	tests := []struct {
+		cursor int
+		want   map[int]int
+	}{
+		{0, map[int]int{0: 6}},
+		{1, map[int]int{1: 5}},
+		// Nothing is deeper than c, so the block around it folds.
+		{2, map[int]int{1: 5}},
+		{6, nil},
+	}
+	for _, tt := range tests {
+		v := testViewer(lines...)
+		v.Cursor = tt.cursor
+		v.closeIndentFold()
+		var got map[int]int
+		if len(v.Folded) > 0 {
+			got = v.Folded
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("closeIndentFold on line %d: folds = %v, want %v", tt.cursor, got, tt.want)
+		}
+	}
+}
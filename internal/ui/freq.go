@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type normalizer struct {
+	re          *regexp.Regexp
+	placeholder string
+	pattern     string
+}
+
+// Order matters: longer, more specific tokens are replaced before numbers.
+var normalizers = []normalizer{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`), "<ts>", `\S+(?: \S+)?`},
+	{regexp.MustCompile(`\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}\b`), "<ts>", `\w+\s+\d+\s+[\d:]+`},
+	{regexp.MustCompile(`\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2}(?: [+-]\d{4})?`), "<ts>", `[^\]]+`},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>", `[0-9a-fA-F-]{36}`},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?\b`), "<ip>", `[\d.:]+`},
+	{regexp.MustCompile(`(?i)\b(?:0x)?[0-9a-f]*\d[0-9a-f]*[a-f][0-9a-f]*\b|\b(?:0x)?[0-9a-f]*[a-f][0-9a-f]*\d[0-9a-f]*\b`), "<hex>", `[0-9a-fA-Fx]+`},
+	{regexp.MustCompile(`\d+(?:\.\d+)?`), "<n>", `\d+(?:\.\d+)?`},
+}
+
+// normalizeLine turns a log line into a message template by replacing
+// variable tokens such as timestamps, ids and numbers with placeholders.
+func normalizeLine(line string) string {
+	for _, n := range normalizers {
+		line = n.re.ReplaceAllString(line, n.placeholder)
+	}
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// templateRegex builds a search pattern that matches the lines a template
+// was made from.
+func templateRegex(template string) string {
+	// A placeholder such as <ts> can come from several normalizers, so it
+	// matches what any of them replaced.
+	placeholders := map[string]string{}
+	for _, n := range normalizers {
+		if pattern, ok := placeholders[n.placeholder]; ok {
+			placeholders[n.placeholder] = pattern + "|" + n.pattern
+		} else {
+			placeholders[n.placeholder] = n.pattern
+		}
+	}
+	var out strings.Builder
+	rest := template
+	for rest != "" {
+		i := strings.IndexByte(rest, '<')
+		if i < 0 {
+			out.WriteString(quoteFields(rest))
+			break
+		}
+		out.WriteString(quoteFields(rest[:i]))
+		j := strings.IndexByte(rest[i:], '>')
+		if j < 0 {
+			out.WriteString(quoteFields(rest[i:]))
+			break
+		}
+		token := rest[i : i+j+1]
+		if pattern, ok := placeholders[token]; ok {
+			out.WriteString("(?:" + pattern + ")")
+		} else {
+			out.WriteString(regexp.QuoteMeta(token))
+		}
+		rest = rest[i+j+1:]
+	}
+	return out.String()
+}
+
+func quoteFields(s string) string {
+	parts := strings.Split(s, " ")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return strings.Join(parts, `\s+`)
+}
+
+type templateCount struct {
+	template string
+	count    int
+}
+
+func (v *Viewer) messageFrequencies() []templateCount {
+	counts := map[string]int{}
+	for i := 0; i < v.lineCount(); i++ {
+		template := normalizeLine(v.line(i))
+		if template != "" {
+			counts[template]++
+		}
+	}
+	out := make([]templateCount, 0, len(counts))
+	for template, count := range counts {
+		out = append(out, templateCount{template: template, count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].count != out[j].count {
+			return out[i].count > out[j].count
+		}
+		return out[i].template < out[j].template
+	})
+	return out
+}
+
+func (v *Viewer) showFrequencies(reader *bufio.Reader) {
+	freqs := v.messageFrequencies()
+	if len(freqs) == 0 {
+		v.Status = "no lines"
+		return
+	}
+	countWidth := len(fmt.Sprint(freqs[0].count))
+	rows := make([]string, 0, len(freqs))
+	for _, f := range freqs {
+		rows = append(rows, fmt.Sprintf("%*d  %s", countWidth, f.count, f.template))
+	}
+	title := fmt.Sprintf("Message frequency (%d templates)", len(freqs))
+	choice, ok := v.selectList(reader, title, rows, 0)
+	if !ok {
+		return
+	}
+	// The template is searched as a regex, without changing the mode of
+	// later searches.
+	regex := v.Regex
+	v.Regex = true
+	v.setQuery(templateRegex(freqs[choice].template), 1)
+	v.Regex = regex
+}
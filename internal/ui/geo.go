@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"hash/fnv"
+	"regexp"
+
+	"tilo/internal/color"
+)
+
+var ipRe = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|\b(?:[0-9a-fA-F]{1,4}:){2,7}[0-9a-fA-F]{1,4}\b`)
+
+var countryPalette = []string{"cyan", "magenta", "green", "yellow", "blue", "red"}
+
+// ipUnderCursor returns the IP address at the cursor, if any.
+func (v *Viewer) ipUnderCursor() string {
+	line := v.line(v.Cursor)
+	runes := []rune(line)
+	if v.CursorCol >= len(runes) {
+		return ""
+	}
+	pos := len(string(runes[:v.CursorCol]))
+	for _, m := range ipRe.FindAllStringIndex(line, -1) {
+		if pos >= m[0] && pos < m[1] {
+			return line[m[0]:m[1]]
+		}
+	}
+	return ""
+}
+
+// geoStatus describes the IP under the cursor for the status bar.
+func (v *Viewer) geoStatus() string {
+	if v.GeoIP == nil {
+		return ""
+	}
+	ip := v.ipUnderCursor()
+	if ip == "" {
+		return ""
+	}
+	info := v.GeoIP.Lookup(ip)
+	if info.Empty() {
+		return ip + ": unknown"
+	}
+	return ip + ": " + info.String()
+}
+
+// geoSpans colors each IP by its country, so addresses from the same place
+// share a color.
+func (v *Viewer) geoSpans(text string) []color.Span {
+	if v.GeoIP == nil {
+		return nil
+	}
+	var spans []color.Span
+	for _, m := range ipRe.FindAllStringIndex(text, -1) {
+		info := v.GeoIP.Lookup(text[m[0]:m[1]])
+		if info.Country == "" {
+			continue
+		}
+		h := fnv.New32a()
+		h.Write([]byte(info.Country))
+		c := countryPalette[h.Sum32()%uint32(len(countryPalette))]
+		spans = append(spans, color.Span{Start: m[0], End: m[1], Color: c, Style: "underline"})
+	}
+	return spans
+}
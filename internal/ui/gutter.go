@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tilo/internal/color"
+)
+
+const deltaWidth = 9
+
+// gutter renders the columns shown left of view row lineIdx.
+func (v *Viewer) gutter(lineIdx int) string {
+	var out strings.Builder
+	if v.ShowSpikes {
+		out.WriteString(v.spikeColumn(lineIdx))
+	}
+	if v.LineNumbers {
+		fmt.Fprintf(&out, "%*d ", v.lineNumberWidth(), v.lineNumber(v.lineIndex(lineIdx)))
+	}
+	if v.ShowDelta {
+		out.WriteString(v.deltaColumn(lineIdx))
+	}
+	return out.String()
+}
+
+func (v *Viewer) gutterWidth() int {
+	width := 0
+	if v.ShowSpikes {
+		width++
+	}
+	if v.LineNumbers {
+		width += v.lineNumberWidth() + 1
+	}
+	if v.ShowDelta {
+		width += deltaWidth
+	}
+	return width
+}
+
+// deltaColumn shows the time elapsed since the previous timestamped line
+// in the view, colored by how long it is.
+func (v *Viewer) deltaColumn(lineIdx int) string {
+	blank := strings.Repeat(" ", deltaWidth)
+	idx := v.lineIndex(lineIdx)
+	if lineIdx <= 0 || !v.hasOwnTime(idx) {
+		return blank
+	}
+	prev := v.lineTime(v.lineIndex(lineIdx - 1))
+	cur := v.lineTime(idx)
+	if prev.IsZero() {
+		return blank
+	}
+	d := cur.Sub(prev)
+	text := fmt.Sprintf("%*s ", deltaWidth-1, formatDelta(d))
+	switch {
+	case d < 0:
+		return color.Wrap(text, "magenta", "")
+	case d < 100*time.Millisecond:
+		return color.Wrap(text, "gray", "")
+	case d < time.Second:
+		return color.Wrap(text, "green", "")
+	case d < v.gapThreshold():
+		return color.Wrap(text, "yellow", "")
+	}
+	return color.Wrap(text, "red", "bold")
+}
+
+func formatDelta(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	switch {
+	case d < 100*time.Second:
+		return fmt.Sprintf("%s%.3fs", sign, d.Seconds())
+	case d < 100*time.Hour:
+		return sign + d.Round(time.Second).String()
+	}
+	return fmt.Sprintf("%s%dd", sign, int(d.Hours()/24))
+}
@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"bufio"
+
+	"tilo/internal/color"
+)
+
+type helpSection struct {
+	title string
+	keys  [][2]string
+}
+
+// helpSections lists the bindings shown by the help overlay.
+var helpSections = []helpSection{
+	{"navigation", [][2]string{
+		{"j / k", "down / up (<N>j, <N>k move N lines)"},
+		{"h / l", "left / right"},
+		{"w / b / e", "next word / previous word / end of word"},
+		{"0 / $ / I / A", "line start / line end"},
+		{"f / F / t / T <c>", "to the next / previous <c>, or just before it"},
+		{"; / ,", "repeat the last f/F/t/T / the other way"},
+		{"gg / G", "top / bottom (<N>gg: line N)"},
+		{"ge", "end of the previous word"},
+		{"<N>G, :<N>", "jump to line N"},
+		{"<N>%, :<N>%", "jump to N percent of the file"},
+		{"} / {", "next / previous pause longer than the gap (:gap)"},
+		{":goto <time>", "first line at or after a time"},
+		{"Ctrl-F / Ctrl-B", "page down / up (also PgDn / PgUp)"},
+		{"Ctrl-E / Ctrl-Y", "scroll one line, keeping the cursor"},
+		{"zz / zt / zb", "cursor line to the middle / top / bottom"},
+		{"Ctrl-D / Ctrl-U", "half a page down / up (<N>Ctrl-D sets the step)"},
+		{"m<a-z>", "set a mark"},
+		{"'<a-z> / `<a-z>", "jump to a mark's line / position"},
+		{"''", "back to before the last jump"},
+		{":marks", "list the marks"},
+		{"a (with -f)", "follow again and jump to the end (was F)"},
+	}},
+	{"search", [][2]string{
+		{"/ / ?", "search forward / backward"},
+		{"n / N", "next / previous match"},
+		{"r", "toggle regex search"},
+		{"c", "cycle ignore case / smartcase / case-sensitive"},
+		{"Q, :copen", "panel listing the matching lines"},
+		{":cn / :cp", "next / previous panel entry"},
+		{":count [pattern]", "count matches without moving"},
+		{":freq", "most frequent message templates"},
+	}},
+	{"correlation and highlights", [][2]string{
+		{"*", "highlight the request/trace id under the cursor"},
+		{"] / [", "next / previous line with that id"},
+		{"R", "resolve the IP under the cursor"},
+		{"@", "decode the number under the cursor as a Unix time"},
+		{"+", "add a highlight pattern"},
+		{"=", "list and remove highlights"},
+	}},
+	{"filters", [][2]string{
+		{"&, &!", "show only / hide lines matching a pattern"},
+		{"u / U", "remove the last / all filters"},
+		{"> / <", "raise / lower the minimum level"},
+		{":filter, :filter!", "same as & and &!"},
+		{":where <expr>", "filter JSON/logfmt lines by field"},
+		{":level <name>", "show lines at or above a level"},
+		{":time <from>..<to>", "show lines in a time window"},
+		{":context <N>", "show N lines around each match"},
+	}},
+	{"selection", [][2]string{
+		{"v / V / Ctrl-V", "select characters / lines / a block"},
+		{"y", "copy the selection"},
+		{"yy", "copy the cursor line (<N>yy: N lines)"},
+		{"Y", "copy the record under the cursor"},
+		{"Esc", "end the selection"},
+	}},
+	{"records and folds", [][2]string{
+		{") / (", "next / previous record"},
+		{"za", "fold / unfold the record"},
+		{"zc / zo", "fold / open the indented block"},
+		{"zM / zR", "fold all records / open all folds"},
+		{":records", "toggle record mode"},
+	}},
+	{"view", [][2]string{
+		{"L", "line numbers"},
+		{"W", "line wrapping"},
+		{"d", "time since the previous line"},
+		{"J", "expand the JSON on the cursor line"},
+		{"C, :table", "table of JSON/logfmt fields"},
+		{"D, :uniq", "collapse repeated lines"},
+		{"H, :hist", "histogram of log volume"},
+		{"S", "line counts per level"},
+		{"E / B", "next / previous error spike"},
+		{"i, :inspect", "break the cursor line down into fields"},
+		{":sort, :nosort", "reorder the view"},
+		{":select ...", "SQL-like query over the fields"},
+		{":rainbow, :changes, :dupes", "field colors, changed characters, dimmed copies"},
+		{":stats, :http", "duration and access-log statistics"},
+	}},
+	{"buffers", [][2]string{
+		{"Ctrl-N / Ctrl-P", "next / previous file (:bn / :bp)"},
+		{"gt / gT, <N>gt", "next / previous file, file N"},
+		{":tabs", "hide or show the tab bar"},
+		{"Tab, :ls", "pick a file"},
+		{":b <N|name>", "switch to a file"},
+	}},
+	{"windows", [][2]string{
+		{":split, Ctrl-W s", "split the screen, windows stacked"},
+		{":vsplit, Ctrl-W v", "split the screen, windows side by side"},
+		{"Ctrl-W w / Ctrl-W W", "next / previous window"},
+		{":close, Ctrl-W c", "close the window"},
+		{":only, Ctrl-W o", "close the other windows"},
+	}},
+	{"other", [][2]string{
+		{":", "command prompt"},
+		{":reload", "read the config again"},
+		{":mouse", "turn mouse support off and on"},
+		{"F1, :help", "this help"},
+		{"q", "quit"},
+	}},
+}
+
+func helpLines(width int) []string {
+	lines := []string{overlayTitle("Keys"), ""}
+	for i, section := range helpSections {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, color.Wrap(section.title, "", "bold"))
+		for _, key := range section.keys {
+			lines = append(lines, wrapField(key[0], key[1], width)...)
+		}
+	}
+	return lines
+}
+
+// showHelp lists the key bindings until it is closed.
+func (v *Viewer) showHelp(reader *bufio.Reader) {
+	width, _ := v.size()
+	lines := helpLines(width)
+	top := 0
+	for {
+		maxTop := max(len(lines)-v.overlayHeight(), 0)
+		top = min(max(top, 0), maxTop)
+		v.drawOverlay(lines, top, "[j/k scroll] [g/G top/bottom] [Esc/q close]")
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 'j':
+			top++
+		case 'k':
+			top--
+		case ' ':
+			top += v.overlayHeight()
+		case 'g':
+			top = 0
+		case 'G':
+			top = maxTop
+		case 0x1b:
+			// F1 closes the help it opened; its bytes must not leak
+			// through as keys.
+			readF1(reader)
+			return
+		case 'q':
+			return
+		}
+	}
+}
+
+// readF1 consumes the rest of an F1 key (ESC O P or ESC [ 1 1 ~) when it
+// follows an Esc that was just read.
+func readF1(reader *bufio.Reader) bool {
+	n := reader.Buffered()
+	if n < 2 {
+		return false
+	}
+	seq, _ := reader.Peek(min(n, 4))
+	switch {
+	case string(seq[:2]) == "OP":
+		_, _ = reader.Discard(2)
+		return true
+	case len(seq) == 4 && string(seq) == "[11~":
+		_, _ = reader.Discard(4)
+		return true
+	}
+	return false
+}
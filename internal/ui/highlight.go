@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"tilo/internal/color"
+	"tilo/internal/history"
+)
+
+var highlightPalette = []string{"yellow", "green", "cyan", "magenta", "blue", "red"}
+
+type Highlight struct {
+	Pattern string
+	Regex   *regexp.Regexp
+	Color   string
+}
+
+func (v *Viewer) addHighlight(reader *bufio.Reader, hist *history.History) {
+	pattern, canceled := v.prompt(reader, "highlight: ", hist, nil)
+	pattern = strings.TrimSpace(pattern)
+	if canceled || pattern == "" {
+		return
+	}
+	defer v.historyStatus(hist.Add(pattern))
+	re, err := v.compileQuery(pattern)
+	if err != nil {
+		v.Status = fmt.Sprintf("invalid regex: %v", err)
+		return
+	}
+	used := map[string]bool{}
+	for _, h := range v.Highlights {
+		used[h.Color] = true
+	}
+	colorName := highlightPalette[len(v.Highlights)%len(highlightPalette)]
+	for _, c := range highlightPalette {
+		if !used[c] {
+			colorName = c
+			break
+		}
+	}
+	v.Highlights = append(v.Highlights, Highlight{Pattern: pattern, Regex: re, Color: colorName})
+	v.Status = fmt.Sprintf("highlight %d added", len(v.Highlights))
+}
+
+func (v *Viewer) removeHighlight(idx int) {
+	if idx < 0 || idx >= len(v.Highlights) {
+		return
+	}
+	v.Highlights = append(v.Highlights[:idx], v.Highlights[idx+1:]...)
+}
+
+// manageHighlights shows the active highlights and lets the user remove them
+// by number until the list is closed.
+func (v *Viewer) manageHighlights(reader *bufio.Reader) {
+	for {
+		lines := []string{overlayTitle("Highlights"), ""}
+		if len(v.Highlights) == 0 {
+			lines = append(lines, "  no highlights (add one with +)")
+		}
+		for i, h := range v.Highlights {
+			lines = append(lines, fmt.Sprintf("  %d  %s", i+1, color.Wrap(h.Pattern, h.Color, "reverse")))
+		}
+		v.drawOverlay(lines, 0, "[1-9 remove] [x clear all] [Esc/q close]")
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch {
+		case b >= '1' && b <= '9':
+			v.removeHighlight(int(b - '1'))
+		case b == 'x':
+			v.Highlights = nil
+		default:
+			v.Status = fmt.Sprintf("%d highlights", len(v.Highlights))
+			return
+		}
+	}
+}
+
+func (v *Viewer) highlightSpans(text string) []color.Span {
+	var spans []color.Span
+	for _, h := range v.Highlights {
+		for _, idx := range h.Regex.FindAllStringIndex(text, -1) {
+			if idx[0] == idx[1] {
+				continue
+			}
+			spans = append(spans, color.Span{Start: idx[0], End: idx[1], Color: h.Color, Style: "reverse"})
+		}
+	}
+	return spans
+}
@@ -0,0 +1,171 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"tilo/internal/color"
+	"tilo/internal/level"
+)
+
+type histBucket struct {
+	start  time.Time
+	count  int
+	errors int
+}
+
+var histSteps = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+const maxHistBuckets = 2000
+
+// buildHistogram counts the visible lines per time bucket. It starts with
+// one-minute buckets and widens them until the chart stays manageable.
+func (v *Viewer) buildHistogram() ([]histBucket, time.Duration) {
+	var first, last time.Time
+	for i := 0; i < v.lineCount(); i++ {
+		t := v.lineTime(v.lineIndex(i))
+		if t.IsZero() {
+			continue
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	if first.IsZero() {
+		return nil, 0
+	}
+	step := histSteps[len(histSteps)-1]
+	for _, s := range histSteps {
+		if int(last.Sub(first.Truncate(s))/s)+1 <= maxHistBuckets {
+			step = s
+			break
+		}
+	}
+	origin := first.Truncate(step)
+	buckets := make([]histBucket, int(last.Sub(origin)/step)+1)
+	for i := range buckets {
+		buckets[i].start = origin.Add(time.Duration(i) * step)
+	}
+	for i := 0; i < v.lineCount(); i++ {
+		idx := v.lineIndex(i)
+		t := v.lineTime(idx)
+		if t.IsZero() {
+			continue
+		}
+		b := int(t.Sub(origin) / step)
+		if b < 0 || b >= len(buckets) {
+			continue
+		}
+		buckets[b].count++
+		if v.lineLevel(idx) >= level.Error {
+			buckets[b].errors++
+		}
+	}
+	return buckets, step
+}
+
+func (v *Viewer) showHistogram(reader *bufio.Reader) {
+	buckets, step := v.buildHistogram()
+	if len(buckets) == 0 {
+		v.Status = "no timestamps"
+		return
+	}
+	peak := 1
+	for _, b := range buckets {
+		if b.count > peak {
+			peak = b.count
+		}
+	}
+	layout := "15:04"
+	if buckets[len(buckets)-1].start.Sub(buckets[0].start) >= 24*time.Hour || step >= 24*time.Hour {
+		layout = "01-02 15:04"
+	}
+	selected := sort.Search(len(buckets), func(i int) bool {
+		return !buckets[i].start.Add(step).Before(v.lineTime(v.lineIndex(v.Cursor)))
+	})
+	if selected >= len(buckets) {
+		selected = len(buckets) - 1
+	}
+	top := 0
+	for {
+		width, _ := v.size()
+		labelWidth := len(layout) + 2
+		countWidth := len(fmt.Sprint(peak))
+		barWidth := width - labelWidth - countWidth - 14
+		if barWidth < 1 {
+			barWidth = 1
+		}
+		height := v.overlayHeight() - 2
+		if height < 1 {
+			height = 1
+		}
+		if selected < top {
+			top = selected
+		}
+		if selected >= top+height {
+			top = selected - height + 1
+		}
+		lines := []string{overlayTitle(fmt.Sprintf("Histogram (%s per bar, errors in red)", step)), ""}
+		for i, b := range buckets {
+			bar := b.count * barWidth / peak
+			if b.count > 0 && bar == 0 {
+				bar = 1
+			}
+			errBar := 0
+			if b.errors > 0 {
+				errBar = b.errors * bar / b.count
+				if errBar == 0 {
+					errBar = 1
+				}
+			}
+			label := b.start.Format(layout)
+			if i == selected {
+				label = reverseOn + label + reverseOff
+			}
+			row := fmt.Sprintf("%s  %*d ", label, countWidth, b.count) +
+				color.Wrap(strings.Repeat("█", errBar), "red", "") +
+				strings.Repeat("█", bar-errBar)
+			if b.errors > 0 {
+				row += fmt.Sprintf(" %s", color.Wrap(fmt.Sprintf("%d err", b.errors), "red", ""))
+			}
+			lines = append(lines, row)
+		}
+		v.drawOverlay(lines, top, "[j/k move] [g/G first/last] [Enter jump] [Esc/q close]")
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 'j':
+			if selected < len(buckets)-1 {
+				selected++
+			}
+		case 'k':
+			if selected > 0 {
+				selected--
+			}
+		case 'g':
+			selected = 0
+		case 'G':
+			selected = len(buckets) - 1
+		case '\r', '\n':
+			v.gotoTimeValue(buckets[selected].start)
+			return
+		case 'q', 0x1b:
+			return
+		}
+	}
+}
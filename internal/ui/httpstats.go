@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tilo/internal/color"
+)
+
+// accessLogRe matches the common and combined access log formats used by
+// nginx and apache: client, identity, user, [time], "request", status.
+var accessLogRe = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "(?:[A-Z]+ )?(\S+)[^"]*" (\d{3}) `)
+
+const httpTopN = 5
+
+// httpStats accumulates access-log counters over Lines. It is updated
+// incrementally, so follow mode only parses the new lines.
+type httpStats struct {
+	scanned  int
+	requests int
+	classes  [6]int
+	paths    map[string]int
+	clients  map[string]int
+	first    time.Time
+	last     time.Time
+}
+
+func (v *Viewer) toggleHTTPStats() {
+	v.ShowHTTP = !v.ShowHTTP
+	if v.ShowHTTP && v.httpStats == nil {
+		v.httpStats = &httpStats{paths: map[string]int{}, clients: map[string]int{}}
+	}
+}
+
+func (s *httpStats) update(lines []string) {
+	for ; s.scanned < len(lines); s.scanned++ {
+		m := accessLogRe.FindStringSubmatch(lines[s.scanned])
+		if m == nil {
+			continue
+		}
+		s.requests++
+		status, _ := strconv.Atoi(m[4])
+		if class := status / 100; class >= 1 && class <= 5 {
+			s.classes[class]++
+		}
+		path, _, _ := strings.Cut(m[3], "?")
+		s.paths[path]++
+		s.clients[m[1]]++
+		if t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[2]); err == nil {
+			if s.first.IsZero() || t.Before(s.first) {
+				s.first = t
+			}
+			if t.After(s.last) {
+				s.last = t
+			}
+		}
+	}
+}
+
+// rate returns requests per second between the first and last request.
+func (s *httpStats) rate() float64 {
+	span := s.last.Sub(s.first).Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(s.requests) / span
+}
+
+func topCounts(counts map[string]int, n int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, counts[k])
+	}
+	return strings.Join(parts, "  ")
+}
+
+var statusColors = [6]string{"", "gray", "green", "cyan", "yellow", "red"}
+
+// httpSummary returns the rows of the HTTP panel.
+func (v *Viewer) httpSummary() []string {
+	s := v.httpStats
+	s.update(v.Lines)
+	if s.requests == 0 {
+		return []string{"http  no access-log lines"}
+	}
+	parts := []string{fmt.Sprintf("http  %d requests", s.requests)}
+	for class := 1; class <= 5; class++ {
+		if s.classes[class] == 0 {
+			continue
+		}
+		name := color.Wrap(fmt.Sprintf("%dxx", class), statusColors[class], "bold")
+		parts = append(parts, fmt.Sprintf("%s %d", name, s.classes[class]))
+	}
+	if rate := s.rate(); rate > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f req/s", rate))
+	}
+	return []string{
+		strings.Join(parts, "  "),
+		"paths  " + topCounts(s.paths, httpTopN),
+		"clients  " + topCounts(s.clients, httpTopN),
+	}
+}
@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+
+	"tilo/internal/color"
+	"tilo/internal/fields"
+	"tilo/internal/level"
+)
+
+var urlRe = regexp.MustCompile(`\bhttps?://[^\s\)\]\}\>\,\;\:"']+`)
+
+// inspectLines breaks the cursor line down into its timestamp, level,
+// structured fields and the addresses found in it.
+func (v *Viewer) inspectLines(width int) []string {
+	idx := v.lineIndex(v.Cursor)
+	line := v.Lines[idx]
+	lines := []string{overlayTitle(fmt.Sprintf("Line %d", v.lineNumber(idx))), ""}
+	add := func(key, value string) {
+		lines = append(lines, wrapField(key, value, width)...)
+	}
+	if v.hasOwnTime(idx) {
+		add("time", v.lineTime(idx).Format(time.RFC3339Nano))
+	}
+	if lvl := v.lineLevel(idx); lvl != level.None {
+		add("level", lvl.String())
+	}
+	if fs := fields.Parse(line); len(fs) > 0 {
+		lines = append(lines, "", color.Wrap("fields", "", "bold"))
+		for _, f := range fs {
+			add(f.Key, f.Value)
+		}
+	}
+	if ips := ipRe.FindAllString(line, -1); len(ips) > 0 {
+		lines = append(lines, "", color.Wrap("addresses", "", "bold"))
+		for _, ip := range ips {
+			info := ""
+			if v.GeoIP != nil {
+				info = v.GeoIP.Lookup(ip).String()
+			}
+			if host, ok := v.hostnames[ip]; ok && host != "" {
+				info = host + " " + info
+			}
+			add(ip, info)
+		}
+	}
+	if urls := urlRe.FindAllString(line, -1); len(urls) > 0 {
+		lines = append(lines, "", color.Wrap("urls", "", "bold"))
+		for _, u := range urls {
+			add("", u)
+		}
+	}
+	lines = append(lines, "", color.Wrap("raw", "", "bold"))
+	add("", line)
+	return lines
+}
+
+// wrapField renders "key  value", continuing long values on indented rows.
+func wrapField(key, value string, width int) []string {
+	const keyWidth = 20
+	prefix := "  "
+	if key != "" {
+		prefix = "  " + color.Wrap(padRight(key, keyWidth), "cyan", "") + " "
+	}
+	indent := 2
+	if key != "" {
+		indent = keyWidth + 3
+	}
+	runes := []rune(value)
+	room := max(width-indent, 10)
+	var rows []string
+	for first := true; first || len(runes) > 0; first = false {
+		n := min(room, len(runes))
+		if first {
+			rows = append(rows, prefix+string(runes[:n]))
+		} else {
+			rows = append(rows, padRight("", indent)+string(runes[:n]))
+		}
+		runes = runes[n:]
+	}
+	return rows
+}
+
+// inspect shows the cursor line parsed into fields until it is closed.
+func (v *Viewer) inspect(reader *bufio.Reader) {
+	if v.lineIndex(v.Cursor) < 0 {
+		v.Status = "no line"
+		return
+	}
+	width, _ := v.size()
+	lines := v.inspectLines(width)
+	top := 0
+	for {
+		maxTop := max(len(lines)-v.overlayHeight(), 0)
+		top = min(max(top, 0), maxTop)
+		v.drawOverlay(lines, top, "[j/k scroll] [y copy fields] [Esc/q close]")
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 'j':
+			top++
+		case 'k':
+			top--
+		case 'g':
+			top = 0
+		case 'G':
+			top = maxTop
+		case 'y':
+			v.copyFields()
+			return
+		case 'q', 0x1b, 'i':
+			return
+		}
+	}
+}
+
+// copyFields copies the cursor line's fields as key=value lines.
+func (v *Viewer) copyFields() {
+	fs := fields.Parse(v.line(v.Cursor))
+	if len(fs) == 0 {
+		v.Status = "no fields"
+		return
+	}
+	var text strings.Builder
+	for _, f := range fs {
+		text.WriteString(f.Key + "=" + f.Value + "\n")
+	}
+	if err := clipboard.WriteAll(text.String()); err != nil {
+		v.Status = "clipboard failed"
+		return
+	}
+	v.Status = fmt.Sprintf("copied %d fields", len(fs))
+}
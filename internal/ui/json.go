@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"tilo/internal/color"
+	"tilo/internal/fields"
+)
+
+var jsonTokenRe = regexp.MustCompile(`"(?:[^"\\]|\\.)*"(\s*:)?|-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?|\btrue\b|\bfalse\b|\bnull\b`)
+
+func prettyJSON(obj string) []string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(obj), "", "  "); err != nil {
+		return nil
+	}
+	return strings.Split(buf.String(), "\n")
+}
+
+// toggleJSON expands the JSON object on the cursor line into
+// pretty-printed rows drawn below it, or collapses them again.
+func (v *Viewer) toggleJSON() {
+	idx := v.lineIndex(v.Cursor)
+	if idx < 0 {
+		return
+	}
+	if _, ok := v.Expanded[idx]; ok {
+		delete(v.Expanded, idx)
+		v.Status = ""
+		return
+	}
+	obj, ok := fields.JSONObject(v.Lines[idx])
+	if !ok {
+		v.Status = "no JSON object on this line"
+		return
+	}
+	if v.Expanded == nil {
+		v.Expanded = map[int][]string{}
+	}
+	v.Expanded[idx] = prettyJSON(obj)
+	v.Status = ""
+}
+
+// expandRows returns the number of pretty-printed rows drawn below view
+// row i.
+func (v *Viewer) expandRows(i int) int {
+	if len(v.Expanded) == 0 {
+		return 0
+	}
+	return len(v.Expanded[v.lineIndex(i)])
+}
+
+func (v *Viewer) renderExpandRow(i int, row int) string {
+	text := v.Expanded[v.lineIndex(i)][row]
+	gutter := strings.Repeat(" ", v.gutterWidth())
+	if v.Plain {
+		return gutter + text
+	}
+	return gutter + colorJSON(text)
+}
+
+func colorJSON(text string) string {
+	return jsonTokenRe.ReplaceAllStringFunc(text, func(tok string) string {
+		switch {
+		case strings.HasPrefix(tok, `"`):
+			if end := strings.LastIndexByte(tok, '"'); end < len(tok)-1 {
+				return color.Wrap(tok[:end+1], "cyan", "") + tok[end+1:]
+			}
+			return color.Wrap(tok, "green", "")
+		case tok == "true" || tok == "false" || tok == "null":
+			return color.Wrap(tok, "magenta", "")
+		default:
+			return color.Wrap(tok, "yellow", "")
+		}
+	})
+}
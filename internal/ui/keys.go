@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+// keyTimeout is how long a key that starts a binding waits for the next
+// one before it acts on its own.
+const keyTimeout = time.Second
+
+// chords are the bindings of more than one key. Their count is the one
+// typed before the first key.
+var chords = map[string]func(v *Viewer, count int){
+	"gg": func(v *Viewer, count int) {
+		if count > 0 {
+			v.gotoLine(count)
+		} else {
+			v.rememberJump()
+			v.cursorTop()
+		}
+	},
+	"ge": func(v *Viewer, count int) {
+		for i := 0; i < max(count, 1); i++ {
+			v.moveWordEndBackward()
+		}
+	},
+	"za": func(v *Viewer, _ int) { v.toggleFold() },
+	"zc": func(v *Viewer, _ int) { v.closeIndentFold() },
+	"zo": func(v *Viewer, _ int) { v.openFold() },
+	"zM": func(v *Viewer, _ int) { v.foldAll() },
+	"zR": func(v *Viewer, _ int) { v.unfoldAll() },
+	"zz": func(v *Viewer, _ int) { v.placeCursorRow(max(len(v.rows), 1) / 2) },
+	"zt": func(v *Viewer, _ int) { v.placeCursorRow(0) },
+	"zb": func(v *Viewer, _ int) { v.placeCursorRow(max(len(v.rows), 1) - 1) },
+	"yy": func(v *Viewer, count int) { v.copyLines(count) },
+	"gt": func(v *Viewer, count int) {
+		if count > 0 {
+			v.switchBuffer(count - 1)
+		} else {
+			v.nextBuffer(1)
+		}
+	},
+	"gT": func(v *Viewer, count int) { v.nextBuffer(-max(count, 1)) },
+	// Ctrl-W commands work on the windows of a split.
+	"\x17s": func(v *Viewer, _ int) { v.split("", false) },
+	"\x17v": func(v *Viewer, _ int) { v.split("", true) },
+	"\x17w": func(v *Viewer, _ int) { v.nextWindow(1) },
+	"\x17W": func(v *Viewer, _ int) { v.nextWindow(-1) },
+	"\x17j": func(v *Viewer, _ int) { v.nextWindow(1) },
+	"\x17l": func(v *Viewer, _ int) { v.nextWindow(1) },
+	"\x17k": func(v *Viewer, _ int) { v.nextWindow(-1) },
+	"\x17h": func(v *Viewer, _ int) { v.nextWindow(-1) },
+	"\x17c": func(v *Viewer, _ int) { v.closeWindow() },
+	"\x17q": func(v *Viewer, _ int) { v.closeWindow() },
+	"\x17o": func(v *Viewer, _ int) { v.onlyWindow() },
+}
+
+// argChords take the key after them as an argument, like the name of the
+// mark in ma.
+var argChords = map[string]func(v *Viewer, count int, key byte){
+	"m": func(v *Viewer, _ int, key byte) { v.setMark(key) },
+	"'": func(v *Viewer, _ int, key byte) { v.jumpToMark(key, false) },
+	"`": func(v *Viewer, _ int, key byte) { v.jumpToMark(key, true) },
+}
+
+// alone is what a key that starts a chord does when nothing follows it in
+// time. A lone g goes to the top, as it did before gg existed.
+var alone = map[string]func(v *Viewer, count int){
+	"g": func(v *Viewer, _ int) { v.cursorTop() },
+	"y": func(v *Viewer, _ int) { v.copySelection() },
+}
+
+// chordPrefix reports whether keys are the start of a longer chord.
+func chordPrefix(keys string) bool {
+	if _, ok := argChords[keys]; ok {
+		return true
+	}
+	for chord := range chords {
+		if len(chord) > len(keys) && strings.HasPrefix(chord, keys) {
+			return true
+		}
+	}
+	return false
+}
+
+// startChord holds key b as the start of a chord. y only starts one while
+// nothing is selected, so it still copies a selection at once.
+func (v *Viewer) startChord(b byte, count int) bool {
+	if !chordPrefix(string(b)) || (b == 'y' && v.SelectMode != SelectNone) {
+		return false
+	}
+	v.Pending = string(b)
+	v.pendingCount = count
+	v.pendingAt = time.Now()
+	return true
+}
+
+// continueChord adds key b to the pending keys and runs the chord they
+// complete. It returns false when b breaks the chord and should be handled
+// as a key of its own; other keys that match nothing are dropped, as in
+// vim.
+func (v *Viewer) continueChord(b byte) bool {
+	keys := v.Pending + string(b)
+	v.Pending = ""
+	if run, ok := argChords[keys[:len(keys)-1]]; ok && b != 0x1b {
+		run(v, v.pendingCount, b)
+		return true
+	}
+	if run, ok := chords[keys]; ok {
+		run(v, v.pendingCount)
+		return true
+	}
+	if chordPrefix(keys) {
+		v.Pending = keys
+		v.pendingAt = time.Now()
+		return true
+	}
+	// Esc cancels; arrow keys and other sequences start with it too.
+	return b != 0x1b
+}
+
+// expireChord runs the pending key on its own once keyTimeout has passed.
+func (v *Viewer) expireChord() bool {
+	if v.Pending == "" || time.Since(v.pendingAt) < keyTimeout {
+		return false
+	}
+	if run, ok := alone[v.Pending]; ok {
+		run(v, v.pendingCount)
+	}
+	v.Pending = ""
+	return true
+}
+
+// copyLines copies count lines from the cursor on, like yy.
+func (v *Viewer) copyLines(count int) {
+	end := min(v.Cursor+max(count, 1), v.lineCount())
+	if v.Cursor >= end {
+		v.Status = "no line"
+		return
+	}
+	var out []string
+	for i := v.Cursor; i < end; i++ {
+		out = append(out, v.line(i))
+	}
+	if err := clipboard.WriteAll(strings.Join(out, "\n")); err != nil {
+		v.Status = "clipboard failed"
+		return
+	}
+	v.Status = fmt.Sprintf("copied %d lines", len(out))
+}
@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"fmt"
+
+	"tilo/internal/level"
+)
+
+// lineLevel returns the cached level of Lines[idx]. Lines without a level
+// of their own, such as stack trace continuations, inherit the level of the
+// line before them.
+func (v *Viewer) lineLevel(idx int) level.Level {
+	if idx < 0 || idx >= len(v.Lines) {
+		return level.None
+	}
+	if v.levelDetector == nil {
+		v.levelDetector = level.NewDetector(v.Rules)
+	}
+	for i := len(v.levels); i <= idx; i++ {
+		lvl := v.levelDetector.Detect(v.Lines[i])
+		if lvl == level.None && i > 0 {
+			lvl = v.levels[i-1]
+		}
+		v.levels = append(v.levels, lvl)
+	}
+	return v.levels[idx]
+}
+
+// isErrorLine reports whether Lines[idx] has an error or fatal level of
+// its own, so a stack trace counts as one error rather than many.
+func (v *Viewer) isErrorLine(idx int) bool {
+	if v.lineLevel(idx) < level.Error {
+		return false
+	}
+	return v.levelDetector.Detect(v.Lines[idx]) >= level.Error
+}
+
+func (v *Viewer) setMinLevel(lvl level.Level) {
+	if lvl < level.None {
+		lvl = level.None
+	}
+	if lvl > level.Fatal {
+		lvl = level.Fatal
+	}
+	if lvl == v.MinLevel {
+		return
+	}
+	v.MinLevel = lvl
+	v.rebuildView()
+	if lvl == level.None {
+		v.Status = "all levels"
+	} else {
+		v.Status = fmt.Sprintf("level >= %s", lvl)
+	}
+}
+
+func (v *Viewer) setMinLevelName(name string) {
+	lvl, err := level.Parse(name)
+	if err != nil {
+		v.Status = err.Error()
+		return
+	}
+	v.setMinLevel(lvl)
+	if v.Status == "" {
+		v.Status = fmt.Sprintf("level >= %s", lvl)
+	}
+}
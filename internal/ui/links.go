@@ -0,0 +1,21 @@
+package ui
+
+import "tilo/internal/color"
+
+// linkWindow is how far around the visible part of a line links are looked
+// for, so a URL cut off at the edge of the screen still links to all of it.
+const linkWindow = 2048
+
+// links returns the hyperlinks within text, the part of view row lineIdx
+// starting at rune column startCol.
+func (v *Viewer) links(text string, lineIdx int, startCol int) []color.Link {
+	line := v.line(lineIdx)
+	start := byteOffset(line, startCol)
+	from := max(start-linkWindow, 0)
+	to := min(start+len(text)+linkWindow, len(line))
+	if from >= to {
+		return nil
+	}
+	links := color.FindLinks(line[from:to], v.Rules)
+	return color.SliceLinks(links, start-from, start-from+len(text))
+}
@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"strconv"
+	"strings"
+
+	"tilo/internal/color"
+	"tilo/internal/logfmt"
+)
+
+// logfmtSpans colors key=value fields: keys are dimmed and values are
+// colored by type. Bare words are left to the rules so that, for example,
+// level=error still shows as an error.
+func logfmtSpans(text string) []color.Span {
+	pairs := logfmt.Parse(text)
+	if len(pairs) < 2 {
+		return nil
+	}
+	var spans []color.Span
+	for _, p := range pairs {
+		spans = append(spans, color.Span{Start: p.KeyStart, End: p.ValueStart, Color: "gray"})
+		if c := valueColor(p); c != "" && p.End > p.ValueStart {
+			spans = append(spans, color.Span{Start: p.ValueStart, End: p.End, Color: c})
+		}
+	}
+	return spans
+}
+
+func valueColor(p logfmt.Pair) string {
+	if p.Quoted {
+		return "green"
+	}
+	switch strings.ToLower(p.Value) {
+	case "true", "false":
+		return "magenta"
+	case "null", "nil", "":
+		return "gray"
+	}
+	if _, err := strconv.ParseFloat(p.Value, 64); err == nil {
+		return "yellow"
+	}
+	return ""
+}
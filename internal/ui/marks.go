@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+)
+
+// Marks are kept by input line, so filters and sorting do not move them.
+// The ' mark is the position before the last jump.
+
+func isMarkName(name byte) bool {
+	return name >= 'a' && name <= 'z'
+}
+
+func (v *Viewer) setMark(name byte) {
+	if !isMarkName(name) {
+		v.Status = "marks are a-z"
+		return
+	}
+	idx := v.lineIndex(v.Cursor)
+	if idx < 0 {
+		v.Status = "no line"
+		return
+	}
+	if v.marks == nil {
+		v.marks = map[byte]Position{}
+	}
+	v.marks[name] = Position{Line: idx, Col: v.CursorCol}
+	v.Status = fmt.Sprintf("mark %c set", name)
+}
+
+// rememberJump sets the ' mark to the cursor position before a jump.
+func (v *Viewer) rememberJump() {
+	idx := v.lineIndex(v.Cursor)
+	if idx < 0 {
+		return
+	}
+	if v.marks == nil {
+		v.marks = map[byte]Position{}
+	}
+	v.marks['\''] = Position{Line: idx, Col: v.CursorCol}
+}
+
+// jumpToMark moves to the line of a mark, or to its exact position with
+// exact (` rather than '). A line hidden by a filter jumps to the next
+// visible one.
+func (v *Viewer) jumpToMark(name byte, exact bool) {
+	pos, ok := v.marks[name]
+	if !ok {
+		v.Status = fmt.Sprintf("mark %c not set", name)
+		return
+	}
+	v.jumpTo(v.viewIndex(pos.Line))
+	if exact && v.lineIndex(v.Cursor) == pos.Line {
+		v.CursorCol = pos.Col
+		v.GoalCol = pos.Col
+		v.clampCursor()
+	}
+}
+
+// dropMarks forgets the marks on the first n lines and moves the others
+// along with the lines.
+func (v *Viewer) dropMarks(n int) {
+	for name, pos := range v.marks {
+		if pos.Line < n {
+			delete(v.marks, name)
+			continue
+		}
+		pos.Line -= n
+		v.marks[name] = pos
+	}
+}
+
+// showMarks lists the marks; Enter jumps to the selected one.
+func (v *Viewer) showMarks(reader *bufio.Reader) {
+	var names []byte
+	for name := range v.marks {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		v.Status = "no marks"
+		return
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	rows := make([]string, len(names))
+	for i, name := range names {
+		pos := v.marks[name]
+		rows[i] = fmt.Sprintf(" %c  %6d  %s", name, v.lineNumber(pos.Line), v.Lines[pos.Line])
+	}
+	if choice, ok := v.selectList(reader, "Marks", rows, 0); ok {
+		v.jumpToMark(names[choice], true)
+	}
+}
@@ -0,0 +1,237 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	// Button presses, drags and SGR-encoded coordinates.
+	mouseOn  = "\x1b[?1000h\x1b[?1002h\x1b[?1006h"
+	mouseOff = "\x1b[?1006l\x1b[?1002l\x1b[?1000l"
+
+	// wheelLines is how far one wheel notch scrolls.
+	wheelLines = 3
+)
+
+type mouseEvent struct {
+	button  int
+	x, y    int
+	release bool
+}
+
+const (
+	mouseLeft   = 0
+	mouseMotion = 32
+	mouseWheel  = 64
+)
+
+// mouseReader takes SGR mouse reports (ESC [ < b ; x ; y M or m) out of the
+// keyboard input and queues them as events, so prompts and overlays never
+// see them as keys.
+type mouseReader struct {
+	r       io.Reader
+	mu      sync.Mutex
+	events  []mouseEvent
+	partial []byte
+}
+
+func (m *mouseReader) Read(p []byte) (int, error) {
+	for {
+		n, err := m.r.Read(p)
+		if n > 0 {
+			n = m.filter(p, n)
+		}
+		// A read that was all mouse reports goes on reading, so a blocking
+		// reader does not take it for the end of input.
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// filter removes the mouse reports from p[:n] and returns the length of
+// what is left. A report cut off at the end is kept for the next read.
+func (m *mouseReader) filter(p []byte, n int) int {
+	data := append(m.partial, p[:n]...)
+	m.partial = nil
+	out := p[:0]
+	for len(data) > 0 {
+		i := bytes.Index(data, []byte("\x1b[<"))
+		if i < 0 {
+			out = append(out, data...)
+			break
+		}
+		out = append(out, data[:i]...)
+		data = data[i:]
+		end := bytes.IndexAny(data, "Mm")
+		if end < 0 {
+			m.partial = append([]byte(nil), data...)
+			break
+		}
+		if ev, ok := parseMouse(data[3:end], data[end] == 'm'); ok {
+			m.mu.Lock()
+			m.events = append(m.events, ev)
+			m.mu.Unlock()
+		}
+		data = data[end+1:]
+	}
+	return len(out)
+}
+
+func parseMouse(params []byte, release bool) (mouseEvent, bool) {
+	parts := bytes.Split(params, []byte(";"))
+	if len(parts) != 3 {
+		return mouseEvent{}, false
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(string(part))
+		if err != nil {
+			return mouseEvent{}, false
+		}
+		nums[i] = n
+	}
+	return mouseEvent{button: nums[0], x: nums[1], y: nums[2], release: release}, true
+}
+
+// next returns the oldest queued event.
+func (m *mouseReader) next() (mouseEvent, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.events) == 0 {
+		return mouseEvent{}, false
+	}
+	ev := m.events[0]
+	m.events = m.events[1:]
+	return ev, true
+}
+
+// drop forgets queued events, e.g. clicks made while an overlay was open.
+func (m *mouseReader) drop() {
+	m.mu.Lock()
+	m.events = nil
+	m.mu.Unlock()
+}
+
+// setMouse turns mouse reporting on or off in the terminal.
+func setMouse(on bool) {
+	if on {
+		fmt.Fprint(os.Stdout, mouseOn)
+	} else {
+		fmt.Fprint(os.Stdout, mouseOff)
+	}
+}
+
+func (l *bufferList) toggleMouse() string {
+	l.mouse = !l.mouse
+	if l.mouse {
+		return "mouse on"
+	}
+	return "mouse off: the terminal selects text again"
+}
+
+// screenRow is what a row of the content area showed at the last draw:
+// part of view row line from rune column col on, or nothing when line < 0.
+type screenRow struct {
+	line int
+	col  int
+}
+
+// handleMouse applies an event: the wheel scrolls, a click moves the
+// cursor, dragging selects, and a click on the position at the right end
+// of the status bar jumps to the end.
+func (v *Viewer) handleMouse(ev mouseEvent) {
+	if ev.release {
+		v.mouseDown = nil
+		return
+	}
+	button := ev.button &^ (4 | 8 | 16) // shift, meta and control
+	switch {
+	case button == mouseWheel:
+		v.scroll(-wheelLines)
+		return
+	case button == mouseWheel+1:
+		v.scroll(wheelLines)
+		return
+	case button == mouseLeft:
+		if ev.y-1 == v.statusRow {
+			if ev.x-v.pane.x > v.screenWidth-len(v.positionIndicator()) {
+				v.cursorBottom()
+				v.FollowAuto = v.Follow
+			}
+			return
+		}
+		pos, ok := v.screenPosition(ev.x, ev.y)
+		if !ok {
+			return
+		}
+		if v.SelectMode != SelectNone {
+			v.clearSelection()
+		}
+		v.mouseDown = &pos
+		v.moveTo(pos)
+	case button == mouseLeft+mouseMotion && v.mouseDown != nil:
+		r := ev.y - 1 - v.rowsTop
+		if r < 0 {
+			v.scroll(-1)
+		} else if r >= len(v.rows) {
+			v.scroll(1)
+		}
+		pos, ok := v.screenPosition(ev.x, min(max(ev.y, v.rowsTop+1), v.rowsTop+len(v.rows)))
+		if !ok {
+			return
+		}
+		if v.SelectMode == SelectNone {
+			start := *v.mouseDown
+			v.SelectMode = SelectChar
+			v.SelectStart = &start
+		}
+		v.moveTo(pos)
+		v.Status = "visual"
+	}
+}
+
+// screenPosition returns the view row and rune column shown at the
+// 1-based screen cell x, y.
+func (v *Viewer) screenPosition(x, y int) (Position, bool) {
+	r := y - 1 - v.rowsTop
+	if r < 0 || r >= len(v.rows) || v.rows[r].line < 0 {
+		return Position{}, false
+	}
+	row := v.rows[r]
+	return Position{Line: row.line, Col: row.col + max(x-1-v.pane.x-v.gutterWidth(), 0)}, true
+}
+
+func (v *Viewer) moveTo(pos Position) {
+	v.Cursor = pos.Line
+	v.CursorCol = pos.Col
+	v.GoalCol = pos.Col
+	v.clampCursor()
+	if v.Follow {
+		v.FollowAuto = false
+	}
+}
+
+// scroll moves the view by delta lines, taking the cursor along where it
+// would leave the screen.
+func (v *Viewer) scroll(delta int) {
+	height := max(len(v.rows), 1)
+	v.Top = min(max(v.Top+delta, 0), max(v.lineCount()-height, 0))
+	v.TopSub = 0
+	if v.Cursor < v.Top {
+		v.Cursor = v.Top
+	}
+	if v.Cursor > v.Top+height-1 {
+		v.Cursor = v.Top + height - 1
+	}
+	v.clampCursor()
+	v.applyGoalCol()
+	if v.Follow {
+		v.FollowAuto = false
+	}
+}
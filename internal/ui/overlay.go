@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// drawOverlay paints lines over the viewer's window, starting at line
+// offset top, and shows footer in place of the status bar.
+func (v *Viewer) drawOverlay(lines []string, top int, footer string) {
+	width, height := v.size()
+	contentHeight := height - 1
+	if contentHeight < 1 {
+		contentHeight = 1
+	}
+	if top > len(lines)-contentHeight {
+		top = len(lines) - contentHeight
+	}
+	if top < 0 {
+		top = 0
+	}
+	fmt.Fprint(os.Stdout, hideCursor)
+	screenLine := 0
+	emit := func(text string) {
+		fmt.Fprintf(os.Stdout, "\x1b[%d;%dH%s", v.pane.y+screenLine+1, v.pane.x+1, text)
+		screenLine++
+	}
+	status := statusBG + statusFG + padRight(footer, width) + resetStyle
+	if v.StatusAtTop {
+		emit(status)
+	}
+	for row := 0; row < contentHeight; row++ {
+		text := ""
+		if top+row < len(lines) {
+			text = lines[top+row]
+		}
+		emit(padRight(truncateANSI(text, width), width))
+	}
+	if !v.StatusAtTop {
+		emit(status)
+	}
+}
+
+func (v *Viewer) overlayHeight() int {
+	_, height := v.size()
+	if height < 2 {
+		return 1
+	}
+	return height - 1
+}
+
+func overlayTitle(title string) string {
+	return reverseOn + " " + strings.TrimSpace(title) + " " + reverseOff
+}
+
+// selectList shows rows under a title and lets the user pick one with j/k
+// and Enter. It returns the chosen index, or false if the list was closed.
+func (v *Viewer) selectList(reader *bufio.Reader, title string, rows []string, selected int) (int, bool) {
+	if len(rows) == 0 {
+		return 0, false
+	}
+	if selected < 0 || selected >= len(rows) {
+		selected = 0
+	}
+	top := 0
+	for {
+		height := v.overlayHeight() - 2
+		if height < 1 {
+			height = 1
+		}
+		if selected < top {
+			top = selected
+		}
+		if selected >= top+height {
+			top = selected - height + 1
+		}
+		lines := []string{overlayTitle(title), ""}
+		for i, row := range rows {
+			if i == selected {
+				row = reverseOn + ">" + reverseOff + row
+			} else {
+				row = " " + row
+			}
+			lines = append(lines, row)
+		}
+		v.drawOverlay(lines, top, fmt.Sprintf("[j/k move] [g/G first/last] [Enter select] [Esc/q close] %d/%d", selected+1, len(rows)))
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, false
+		}
+		switch b {
+		case 'j':
+			if selected < len(rows)-1 {
+				selected++
+			}
+		case 'k':
+			if selected > 0 {
+				selected--
+			}
+		case 'g':
+			selected = 0
+		case 'G':
+			selected = len(rows) - 1
+		case '\r', '\n':
+			return selected, true
+		case 'q', 0x1b:
+			return 0, false
+		}
+	}
+}
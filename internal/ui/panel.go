@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"tilo/internal/color"
+	"tilo/internal/level"
+)
+
+// panelLines returns the rows drawn between the content and the status bar.
+func (v *Viewer) panelLines() []string {
+	var lines []string
+	if v.ShowLevels {
+		lines = append(lines, v.levelSummary())
+	}
+	if v.ShowHTTP {
+		lines = append(lines, v.httpSummary()...)
+	}
+	if v.quickfix.open {
+		lines = append(lines, v.quickfixLines()...)
+	}
+	return lines
+}
+
+func (v *Viewer) panelRows() int {
+	return len(v.panelLines())
+}
+
+var levelColors = map[level.Level]string{
+	level.Fatal: "red",
+	level.Error: "red",
+	level.Warn:  "yellow",
+	level.Info:  "blue",
+	level.Debug: "magenta",
+	level.Trace: "gray",
+}
+
+// levelCounts counts lines per level, over all lines and over the view.
+// The counts are kept between redraws, so only lines added since are
+// counted; a view that was rebuilt rather than extended is counted again.
+func (v *Viewer) levelCounts() (total, visible [level.Fatal + 1]int) {
+	for ; v.levelsCounted < len(v.Lines); v.levelsCounted++ {
+		v.levelTotals[v.lineLevel(v.levelsCounted)]++
+	}
+	if v.View == nil {
+		return v.levelTotals, v.levelTotals
+	}
+	counted := v.viewCounted
+	if len(counted) > len(v.View) || len(counted) > 0 && &counted[0] != &v.View[0] {
+		counted = nil
+	}
+	if len(counted) == 0 {
+		v.viewLevels = [level.Fatal + 1]int{}
+	}
+	for _, idx := range v.View[len(counted):] {
+		v.viewLevels[v.lineLevel(idx)]++
+	}
+	v.viewCounted = v.View
+	return v.levelTotals, v.viewLevels
+}
+
+// dropLevelCounts takes the first n lines, about to be dropped, out of the
+// level counts.
+func (v *Viewer) dropLevelCounts(n int) {
+	for i := 0; i < min(n, v.levelsCounted); i++ {
+		v.levelTotals[v.lineLevel(i)]--
+	}
+	v.levelsCounted = max(v.levelsCounted-n, 0)
+	v.viewCounted = nil
+}
+
+func (v *Viewer) resetLevelCounts() {
+	v.levelTotals = [level.Fatal + 1]int{}
+	v.levelsCounted = 0
+	v.viewCounted = nil
+}
+
+// levelSummary counts lines per level, showing visible/total counts while a
+// filter is active.
+func (v *Viewer) levelSummary() string {
+	total, visible := v.levelCounts()
+	parts := []string{"levels"}
+	for lvl := level.Fatal; lvl > level.None; lvl-- {
+		name := color.Wrap(lvl.String(), levelColors[lvl], "bold")
+		if v.View != nil {
+			parts = append(parts, fmt.Sprintf("%s %d/%d", name, visible[lvl], total[lvl]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %d", name, total[lvl]))
+		}
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"tilo/internal/query"
+)
+
+// runQuery executes a SELECT statement over the lines in the view and
+// shows the result in a list. Enter jumps to the first line behind a row.
+func (v *Viewer) runQuery(reader *bufio.Reader, input string) {
+	q, err := query.Parse(input)
+	if err != nil {
+		v.Status = fmt.Sprintf("query error: %v", err)
+		return
+	}
+	res := q.Run(v.lineCount(), v.line)
+	if len(res.Rows) == 0 {
+		v.Status = "query: no rows"
+		return
+	}
+	widths := make([]int, len(res.Header))
+	for c, h := range res.Header {
+		widths[c] = utf8.RuneCountInString(h)
+	}
+	for _, row := range res.Rows {
+		for c, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[c] {
+				widths[c] = n
+			}
+		}
+	}
+	for c := range widths {
+		if widths[c] > 40 && c < len(widths)-1 {
+			widths[c] = 40
+		}
+	}
+	format := func(row []string) string {
+		cells := make([]string, len(row))
+		for c, cell := range row {
+			if c == len(row)-1 {
+				cells[c] = cell
+			} else {
+				cells[c] = fitCell(cell, widths[c])
+			}
+		}
+		return strings.Join(cells, " │ ")
+	}
+	rows := make([]string, len(res.Rows))
+	for i, row := range res.Rows {
+		rows[i] = format(row)
+	}
+	title := fmt.Sprintf("%s  (%d rows)", format(res.Header), len(res.Rows))
+	choice, ok := v.selectList(reader, title, rows, 0)
+	if !ok {
+		return
+	}
+	v.jumpTo(res.Lines[choice])
+}
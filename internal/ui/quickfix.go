@@ -0,0 +1,211 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+
+	"tilo/internal/color"
+)
+
+const (
+	// quickfixRows is the most entries the panel shows at once.
+	quickfixRows = 8
+	// quickfixContext is how many characters are kept before the match when
+	// a long line has to be cut.
+	quickfixContext = 20
+)
+
+// quickfix is a panel listing the lines of the current search, or the rows
+// of the filtered view when nothing is searched. It keeps its own selection
+// and scroll position; the main view only moves when an entry is chosen.
+type quickfix struct {
+	open    bool
+	focused bool
+	sel     int
+	top     int
+	// lines are the match rows, one per line, built from matches.
+	lines   []Position
+	matches []Position
+}
+
+// quickfixEntries returns the number of entries and a function giving the
+// view row and column of each.
+func (v *Viewer) quickfixEntries() (int, func(i int) Position) {
+	if len(v.Matches) > 0 {
+		qf := &v.quickfix
+		if len(qf.matches) != len(v.Matches) || &qf.matches[0] != &v.Matches[0] {
+			qf.matches = v.Matches
+			qf.lines = qf.lines[:0]
+			for _, m := range v.Matches {
+				if n := len(qf.lines); n == 0 || qf.lines[n-1].Line != m.Line {
+					qf.lines = append(qf.lines, m)
+				}
+			}
+		}
+		return len(qf.lines), func(i int) Position { return qf.lines[i] }
+	}
+	if v.View != nil {
+		return v.lineCount(), func(i int) Position { return Position{Line: i} }
+	}
+	return 0, nil
+}
+
+func (v *Viewer) quickfixTitle(n int) string {
+	switch {
+	case len(v.Matches) > 0:
+		return fmt.Sprintf("/%s: %d lines", v.Query, n)
+	case v.View != nil:
+		return fmt.Sprintf("filtered: %d lines", n)
+	}
+	return "no search or filter"
+}
+
+// quickfixLines renders the panel: a title and the entries around the
+// selection.
+func (v *Viewer) quickfixLines() []string {
+	qf := &v.quickfix
+	n, entry := v.quickfixEntries()
+	_, height := v.size()
+	rows := min(quickfixRows, n, max(height/3-1, 1))
+	qf.sel = min(max(qf.sel, 0), max(n-1, 0))
+	if qf.sel < qf.top {
+		qf.top = qf.sel
+	}
+	if qf.sel >= qf.top+rows {
+		qf.top = qf.sel - rows + 1
+	}
+	qf.top = min(max(qf.top, 0), max(n-rows, 0))
+	lines := []string{overlayTitle(v.quickfixTitle(n))}
+	numWidth := v.lineNumberWidth()
+	for i := qf.top; i < qf.top+rows; i++ {
+		pos := entry(i)
+		runes := []rune(v.line(pos.Line))
+		start := max(pos.Col-quickfixContext, 0)
+		text := string(runes[start:])
+		if start > 0 {
+			text = "…" + text
+		}
+		if v.QueryRe != nil {
+			text = v.QueryRe.ReplaceAllStringFunc(text, func(m string) string {
+				return color.Wrap(m, "", "underline")
+			})
+		}
+		row := fmt.Sprintf("%*d: %s", numWidth, v.lineNumber(v.lineIndex(pos.Line)), text)
+		switch {
+		case i == qf.sel && qf.focused:
+			row = reverseOn + " " + row + reverseOff
+		case i == qf.sel:
+			row = color.Wrap(">", "", "bold") + row
+		default:
+			row = " " + row
+		}
+		lines = append(lines, row)
+	}
+	return lines
+}
+
+// selectQuickfixNear selects the entry at or after the cursor.
+func (v *Viewer) selectQuickfixNear() {
+	n, entry := v.quickfixEntries()
+	v.quickfix.sel = 0
+	for i := 0; i < n; i++ {
+		if entry(i).Line >= v.Cursor {
+			v.quickfix.sel = i
+			return
+		}
+	}
+}
+
+// jumpToQuickfix moves the main view to the selected entry.
+func (v *Viewer) jumpToQuickfix() {
+	n, entry := v.quickfixEntries()
+	if n == 0 {
+		v.Status = "no entries"
+		return
+	}
+	pos := entry(v.quickfix.sel)
+	v.jumpTo(pos.Line)
+	v.CursorCol = pos.Col
+	v.GoalCol = pos.Col
+	v.clampCursor()
+	if len(v.Matches) > 0 {
+		v.MatchIndex = v.closestMatchIndex(1)
+	}
+}
+
+// stepQuickfix selects the next (or previous) entry and jumps to it, like
+// :cnext and :cprev.
+func (v *Viewer) stepQuickfix(delta int) {
+	n, _ := v.quickfixEntries()
+	if n == 0 {
+		v.Status = "no entries"
+		return
+	}
+	v.quickfix.sel = min(max(v.quickfix.sel+delta, 0), n-1)
+	v.jumpToQuickfix()
+	v.Status = "entry " + strconv.Itoa(v.quickfix.sel+1) + "/" + strconv.Itoa(n)
+}
+
+func (v *Viewer) closeQuickfix() {
+	v.quickfix.open = false
+	v.quickfix.focused = false
+}
+
+// focusQuickfix opens the panel and moves the keys to it until Esc or
+// Enter gives them back to the main view; q closes the panel.
+func (v *Viewer) focusQuickfix(reader *bufio.Reader) {
+	qf := &v.quickfix
+	if !qf.open {
+		qf.open = true
+		v.selectQuickfixNear()
+	}
+	qf.focused = true
+	defer func() { qf.focused = false }()
+	for {
+		n, _ := v.quickfixEntries()
+		v.Status = "[j/k move] [Enter jump] [Space preview] [Esc back] [q close]"
+		v.draw()
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 'j':
+			qf.sel++
+		case 'k':
+			qf.sel--
+		case 0x04:
+			qf.sel += quickfixRows / 2
+		case 0x15:
+			qf.sel -= quickfixRows / 2
+		case 'g':
+			qf.sel = 0
+		case 'G':
+			qf.sel = n - 1
+		case ' ':
+			v.jumpToQuickfix()
+		case '\r', '\n':
+			v.jumpToQuickfix()
+			return
+		case 'q':
+			v.closeQuickfix()
+			v.Status = ""
+			return
+		case 0x1b:
+			// Up and Down arrive as Esc sequences; a lone Esc goes back.
+			if seq, _ := reader.Peek(min(reader.Buffered(), 2)); len(seq) == 2 && seq[0] == '[' {
+				_, _ = reader.Discard(2)
+				switch seq[1] {
+				case 'A':
+					qf.sel--
+				case 'B':
+					qf.sel++
+				}
+				continue
+			}
+			v.Status = ""
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"tilo/internal/color"
+)
+
+// rainbowPalette colors the fields of delimited lines in turn.
+var rainbowPalette = []string{"cyan", "yellow", "green", "magenta", "blue", "bright_red"}
+
+// rainbowSample is how many lines are looked at to guess the delimiter.
+const rainbowSample = 50
+
+// setRainbow turns rainbow fields on with the given delimiter ("tab",
+// "space" or a single character), guesses the delimiter when arg is empty,
+// and turns them off again on "off" or when they are on and arg is empty.
+func (v *Viewer) setRainbow(arg string) {
+	var delim rune
+	switch arg {
+	case "":
+		if v.Rainbow != 0 {
+			v.Rainbow = 0
+			v.Status = "rainbow fields off"
+			return
+		}
+		delim = detectDelimiter(v.Lines[:min(len(v.Lines), rainbowSample)])
+	case "off":
+		v.Rainbow = 0
+		v.Status = "rainbow fields off"
+		return
+	case "tab", `\t`:
+		delim = '\t'
+	case "space":
+		delim = ' '
+	default:
+		r, size := utf8.DecodeRuneInString(arg)
+		if size != len(arg) {
+			v.Status = "rainbow: delimiter must be one character, tab or space"
+			return
+		}
+		delim = r
+	}
+	v.Rainbow = delim
+	v.Status = "rainbow fields split on " + delimiterName(delim)
+}
+
+func delimiterName(delim rune) string {
+	switch delim {
+	case '\t':
+		return "tabs"
+	case ' ':
+		return "spaces"
+	}
+	return "'" + string(delim) + "'"
+}
+
+// detectDelimiter picks the delimiter that splits most of lines into the
+// same number of fields, falling back to runs of spaces.
+func detectDelimiter(lines []string) rune {
+	best, bestScore := ' ', 0
+	for _, delim := range []rune{'\t', ',', '|', ';'} {
+		counts := map[int]int{}
+		for _, line := range lines {
+			if n := strings.Count(line, string(delim)); n > 0 {
+				counts[n]++
+			}
+		}
+		for _, score := range counts {
+			if score > bestScore && score*2 > len(lines) {
+				best, bestScore = delim, score
+			}
+		}
+	}
+	return best
+}
+
+// fieldBounds returns the byte ranges of the fields of line. Runs of spaces
+// count as one delimiter, and a comma inside double quotes does not split
+// a CSV field.
+func fieldBounds(line string, delim rune) [][2]int {
+	var fields [][2]int
+	start := -1
+	quoted := false
+	for i, r := range line {
+		if start < 0 {
+			if delim == ' ' && r == ' ' {
+				continue
+			}
+			start = i
+		}
+		switch {
+		case r == '"' && delim != ' ':
+			quoted = !quoted
+		case r == delim && !quoted:
+			fields = append(fields, [2]int{start, i})
+			start = -1
+			if delim != ' ' {
+				// An empty field still takes its color.
+				start = i + 1
+			}
+		}
+	}
+	if start >= 0 && start <= len(line) {
+		fields = append(fields, [2]int{start, len(line)})
+	}
+	return fields
+}
+
+// rainbowSpans colors the fields within text, the part of view row lineIdx
+// starting at rune column startCol.
+func (v *Viewer) rainbowSpans(text string, lineIdx int, startCol int) []color.Span {
+	if v.Rainbow == 0 {
+		return nil
+	}
+	line := v.line(lineIdx)
+	offset := byteOffset(line, startCol)
+	end := offset + len(text)
+	var spans []color.Span
+	for i, f := range fieldBounds(line, v.Rainbow) {
+		if f[1] <= offset || f[0] >= end || f[0] == f[1] {
+			continue
+		}
+		spans = append(spans, color.Span{
+			Start: max(f[0], offset) - offset,
+			End:   min(f[1], end) - offset,
+			Color: rainbowPalette[i%len(rainbowPalette)],
+		})
+	}
+	return spans
+}
+
+// byteOffset returns the byte offset of rune column col in line.
+func byteOffset(line string, col int) int {
+	offset := 0
+	for ; col > 0 && offset < len(line); col-- {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+)
+
+const rateWindow = 10 * time.Second
+
+type rateBucket struct {
+	second int64
+	lines  int
+	errors int
+}
+
+// recordRate counts lines appended while following, and how many of them
+// are errors, in one-second buckets.
+func (v *Viewer) recordRate(start int) {
+	now := time.Now().Unix()
+	if n := len(v.rates); n == 0 || v.rates[n-1].second != now {
+		v.rates = append(v.rates, rateBucket{second: now})
+	}
+	b := &v.rates[len(v.rates)-1]
+	for i := start; i < len(v.Lines); i++ {
+		b.lines++
+		if v.isErrorLine(i) {
+			b.errors++
+		}
+	}
+	v.trimRates(now)
+}
+
+func (v *Viewer) trimRates(now int64) {
+	cutoff := now - int64(rateWindow/time.Second)
+	i := 0
+	for i < len(v.rates) && v.rates[i].second <= cutoff {
+		i++
+	}
+	v.rates = v.rates[i:]
+}
+
+// rateStatus shows lines and errors per second over the last rateWindow.
+func (v *Viewer) rateStatus() string {
+	if !v.Follow {
+		return ""
+	}
+	v.trimRates(time.Now().Unix())
+	lines, errors := 0, 0
+	for _, b := range v.rates {
+		lines += b.lines
+		errors += b.errors
+	}
+	secs := rateWindow.Seconds()
+	status := fmt.Sprintf("%.1f lines/s", float64(lines)/secs)
+	if errors > 0 {
+		status += fmt.Sprintf(" %.1f err/s", float64(errors)/secs)
+	}
+	return status
+}
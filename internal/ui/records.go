@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// continuationRe matches lines that usually continue the record above them:
+// indented lines, Java/Python/Go stack traces and closing JSON brackets.
+var continuationRe = regexp.MustCompile(`^(?:\s|at |Caused by:|\.\.\. \d+ more|Traceback |goroutine \d+ \[|[\w.$]+(?:Exception|Error|Throwable)(?::|$)|[}\])],?\s*$)`)
+
+// isRecordStart reports whether Lines[idx] begins a new logical record.
+// With a record_start pattern configured only matching lines start records.
+func (v *Viewer) isRecordStart(idx int) bool {
+	if idx <= 0 {
+		return true
+	}
+	if idx >= len(v.Lines) {
+		return false
+	}
+	for i := len(v.recordStarts); i <= idx; i++ {
+		line := v.Lines[i]
+		var start bool
+		if v.RecordStart != nil {
+			start = v.RecordStart.MatchString(line)
+		} else {
+			start = line != "" && !continuationRe.MatchString(line)
+		}
+		v.recordStarts = append(v.recordStarts, start)
+	}
+	return v.recordStarts[idx]
+}
+
+// recordBounds returns the [start, end) range of Lines forming the record
+// that contains line idx.
+func (v *Viewer) recordBounds(idx int) (int, int) {
+	start := idx
+	for start > 0 && !v.isRecordStart(start) {
+		start--
+	}
+	end := idx + 1
+	for end < len(v.Lines) && !v.isRecordStart(end) {
+		end++
+	}
+	return start, end
+}
+
+// extendRecordView adds whole records to a filtered view: a record is shown
+// when any of its lines passes the filters.
+func (v *Viewer) extendRecordView(start int) {
+	last := -1
+	if len(v.View) > 0 {
+		last = v.View[len(v.View)-1]
+	}
+	i, _ := v.recordBounds(start)
+	for i < len(v.Lines) {
+		_, end := v.recordBounds(i)
+		for j := max(i, last+1); j < end; j++ {
+			if v.filterMatch(j) {
+				for k := max(i, last+1); k < end; k++ {
+					if !v.foldHidden(k) {
+						v.View = append(v.View, k)
+					}
+				}
+				last = end - 1
+				break
+			}
+		}
+		i = end
+	}
+}
+
+func (v *Viewer) toggleRecordMode() {
+	v.RecordMode = !v.RecordMode
+	if v.filtering() {
+		v.rebuildView()
+	}
+	if v.RecordMode {
+		v.Status = "record mode: filters keep whole records"
+	} else {
+		v.Status = "record mode off"
+	}
+}
+
+func (v *Viewer) nextRecord(dir int) {
+	if v.lineCount() == 0 {
+		return
+	}
+	i := v.Cursor + dir
+	for i >= 0 && i < v.lineCount() && !v.isRecordStart(v.lineIndex(i)) {
+		i += dir
+	}
+	if i < 0 || i >= v.lineCount() {
+		v.Status = "no more records"
+		return
+	}
+	v.jumpTo(i)
+}
+
+func (v *Viewer) copyRecord() {
+	idx := v.lineIndex(v.Cursor)
+	if idx < 0 {
+		v.Status = "no record"
+		return
+	}
+	start, end := v.recordBounds(idx)
+	text := strings.Join(v.Lines[start:end], "\n")
+	if err := clipboard.WriteAll(text); err != nil {
+		v.Status = "clipboard failed"
+		return
+	}
+	v.Status = fmt.Sprintf("copied record (%d lines)", end-start)
+}
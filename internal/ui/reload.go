@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"fmt"
+
+	"tilo/internal/color"
+	"tilo/internal/level"
+)
+
+// reloadRules rebuilds the color rules of every buffer from the config and
+// returns a status message. On an error the old rules stay in place, so a
+// half-edited config does not leave the screen without colors.
+func (l *bufferList) reloadRules() string {
+	if l.reload == nil {
+		return "reload: no config"
+	}
+	names := make([]string, len(l.viewers))
+	for i, v := range l.viewers {
+		names[i] = v.Name
+	}
+	rules, err := l.reload(names)
+	if err != nil {
+		return "reload: " + err.Error()
+	}
+	for i, v := range l.viewers {
+		v.setRules(rules[i])
+	}
+	for _, w := range l.windows {
+		if w != l.viewers[w.buffer] {
+			w.setRules(rules[w.buffer])
+		}
+	}
+	return fmt.Sprintf("rules reloaded (%d)", len(rules[l.current]))
+}
+
+// setRules replaces the color rules, and forgets the line levels detected
+// with the old ones.
+func (v *Viewer) setRules(rules []color.Rule) {
+	v.Rules = rules
+	v.levelDetector = nil
+	v.levels = nil
+	v.resetLevelCounts()
+	v.resetSpikes()
+	if v.MinLevel != level.None {
+		v.rebuildView()
+	}
+}
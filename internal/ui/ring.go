@@ -0,0 +1,100 @@
+package ui
+
+import "tilo/internal/color"
+
+// lineOverhead approximates the memory a line costs beyond its text: the
+// string header in Lines plus its cached level and time.
+const lineOverhead = 48
+
+// trimLines drops the oldest lines once MaxLines or MaxMemory is exceeded,
+// so following a chatty source for hours keeps memory bounded. A tenth of
+// the limit is dropped at a time so the caches are not shifted on every
+// batch. LineBase grows by the number dropped, which keeps line numbers
+// stable.
+func (v *Viewer) trimLines() {
+	n := 0
+	if v.MaxLines > 0 && len(v.Lines) > v.MaxLines {
+		n = len(v.Lines) - v.MaxLines + v.MaxLines/10
+	}
+	if v.MaxMemory > 0 && v.memory > v.MaxMemory {
+		target := v.MaxMemory - v.MaxMemory/10
+		used := v.memory
+		m := 0
+		for m < len(v.Lines) && used > target {
+			used -= int64(len(v.Lines[m]) + lineOverhead)
+			m++
+		}
+		n = max(n, m)
+	}
+	if n <= 0 {
+		return
+	}
+	n = min(n, len(v.Lines)-1)
+	v.dropLines(n)
+}
+
+// dropLines removes the first n lines and moves everything that refers to
+// lines by index along with them.
+func (v *Viewer) dropLines(n int) {
+	cursor := v.lineIndex(v.Cursor) - n
+	top := v.lineIndex(v.Top) - n
+	for _, line := range v.Lines[:n] {
+		v.memory -= int64(len(line) + lineOverhead)
+	}
+	v.dropLevelCounts(n)
+	// Copy rather than reslice so the dropped strings can be collected.
+	v.Lines = append([]string(nil), v.Lines[n:]...)
+	v.LineBase += n
+	v.levels = dropFirst(v.levels, n)
+	v.times = dropFirst(v.times, n)
+	v.ownTimes = dropFirst(v.ownTimes, n)
+	v.recordStarts = dropFirst(v.recordStarts, n)
+	v.dropSpikes(n)
+	// Lines that only repeated dropped ones count as new again.
+	v.dupes = nil
+	v.dupeSeen = nil
+	if v.httpStats != nil {
+		v.httpStats.scanned = max(v.httpStats.scanned-n, 0)
+	}
+	expanded := map[int][]string{}
+	for idx, rows := range v.Expanded {
+		if idx >= n {
+			expanded[idx-n] = rows
+		}
+	}
+	v.Expanded = expanded
+	if v.ansiSpans != nil {
+		spans := map[int][]color.ANSISpan{}
+		for idx, s := range v.ansiSpans {
+			if idx >= n {
+				spans[idx-n] = s
+			}
+		}
+		v.ansiSpans = spans
+	}
+	v.dropMarks(n)
+	folds := map[int]int{}
+	for start, end := range v.Folded {
+		if start >= n {
+			folds[start-n] = end - n
+		}
+	}
+	// Rebuild the view as if the cursor were on the same line it was on.
+	v.View = nil
+	v.Cursor = max(cursor, 0)
+	selecting := v.SelectMode
+	v.setFolds(folds)
+	if top >= 0 {
+		v.Top = v.viewIndex(top)
+	}
+	if selecting != SelectNone {
+		v.Status = "selection cleared: old lines were discarded"
+	}
+}
+
+func dropFirst[T any](s []T, n int) []T {
+	if len(s) <= n {
+		return nil
+	}
+	return append([]T(nil), s[n:]...)
+}
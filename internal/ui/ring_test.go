@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func numberedLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("l%d", i)
+	}
+	return lines
+}
+
+// viewLines returns the input lines the view shows, by index.
+func viewLines(v *Viewer) []int {
+	out := make([]int, v.lineCount())
+	for i := range out {
+		out[i] = v.lineIndex(i)
+	}
+	return out
+}
+
+func TestDropLines(t *testing.T) {
+	v := testViewer(numberedLines(10)...)
+	v.marks = map[byte]Position{'a': {Line: 3}, 'b': {Line: 9, Col: 1}}
+	v.setFolds(map[int]int{1: 3, 6: 9})
+	v.Cursor = v.viewIndex(9)
+	v.Top = v.viewIndex(6)
+	v.dropLines(4)
+
+	if v.LineBase != 4 || v.Lines[0] != "l4" || len(v.Lines) != 6 {
+		t.Fatalf("after dropping 4: LineBase %d, %d lines from %q", v.LineBase, len(v.Lines), v.Lines[0])
+	}
+	if want := map[byte]Position{'b': {Line: 5, Col: 1}}; !reflect.DeepEqual(v.marks, want) {
+		t.Errorf("marks = %v, want %v", v.marks, want)
+	}
+	if want := map[int]int{2: 5}; !reflect.DeepEqual(v.Folded, want) {
+		t.Errorf("folds = %v, want %v", v.Folded, want)
+	}
+	if got, want := viewLines(v), []int{0, 1, 2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("view = %v, want %v", got, want)
+	}
+	if got := v.lineIndex(v.Cursor); got != 5 {
+		t.Errorf("cursor on line %d, want 5", got)
+	}
+	if got := v.lineIndex(v.Top); got != 2 {
+		t.Errorf("top on line %d, want 2", got)
+	}
+}
+
+func TestDropLinesFiltered(t *testing.T) {
+	v := testViewer(numberedLines(10)...)
+	v.addFilter(Filter{Pattern: "[02468]$", Regex: regexp.MustCompile("[02468]$")})
+	v.Cursor = v.viewIndex(6)
+	v.dropLines(3)
+	if got, want := viewLines(v), []int{1, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("view = %v, want %v", got, want)
+	}
+	if got := v.Lines[v.lineIndex(v.Cursor)]; got != "l6" {
+		t.Errorf("cursor on %q, want l6", got)
+	}
+}
+
+func TestTrimLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		lines     []string
+		wantFirst string
+		wantLen   int
+	}{
+		{"under max lines", Options{MaxLines: 20}, numberedLines(15), "l0", 15},
+		// A tenth of the limit more than needed goes, so trimming is rare.
+		{"max lines", Options{MaxLines: 10}, numberedLines(15), "l6", 9},
+		{"max memory", Options{MaxMemory: 500}, strings.Split(strings.Repeat(strings.Repeat("x", 52)+"\n", 10), "\n")[:10], "", 4},
+		{"keeps one line", Options{MaxLines: 1}, numberedLines(3), "l2", 1},
+	}
+	for _, tt := range tests {
+		v := newViewer(Buffer{Name: "test", Lines: tt.lines}, nil, tt.opts, nil)
+		v.trimLines()
+		if len(v.Lines) != tt.wantLen || (tt.wantFirst != "" && v.Lines[0] != tt.wantFirst) {
+			t.Errorf("%s: %d lines from %q, want %d from %q", tt.name, len(v.Lines), v.Lines[0], tt.wantLen, tt.wantFirst)
+		}
+		if v.LineBase != len(tt.lines)-len(v.Lines) {
+			t.Errorf("%s: LineBase %d, want %d", tt.name, v.LineBase, len(tt.lines)-len(v.Lines))
+		}
+	}
+}
+
+func TestDropFirst(t *testing.T) {
+	tests := []struct {
+		s    []int
+		n    int
+		want []int
+	}{
+		{nil, 2, nil},
+		{[]int{1, 2}, 2, nil},
+		{[]int{1, 2}, 3, nil},
+		{[]int{1, 2, 3}, 1, []int{2, 3}},
+		{[]int{1, 2, 3}, 0, []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		if got := dropFirst(tt.s, tt.n); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dropFirst(%v, %d) = %v, want %v", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
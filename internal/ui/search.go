@@ -0,0 +1,326 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"tilo/internal/color"
+)
+
+type CaseMode int
+
+const (
+	CaseIgnore CaseMode = iota
+	CaseSmart
+	CaseSensitive
+)
+
+func ParseCaseMode(s string) (CaseMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "ignore":
+		return CaseIgnore, nil
+	case "smart":
+		return CaseSmart, nil
+	case "sensitive":
+		return CaseSensitive, nil
+	}
+	return CaseIgnore, fmt.Errorf("invalid search case %q (want ignore, smart or sensitive)", s)
+}
+
+func (m CaseMode) String() string {
+	switch m {
+	case CaseSmart:
+		return "smartcase"
+	case CaseSensitive:
+		return "case"
+	}
+	return "nocase"
+}
+
+func (m CaseMode) ignoreCase(query string) bool {
+	switch m {
+	case CaseSensitive:
+		return false
+	case CaseSmart:
+		return !strings.ContainsFunc(query, unicode.IsUpper)
+	}
+	return true
+}
+
+func (v *Viewer) compileQuery(query string) (*regexp.Regexp, error) {
+	pattern := query
+	if !v.Regex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if v.CaseMode.ignoreCase(query) {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+type searchState struct {
+	query      string
+	queryRe    *regexp.Regexp
+	matches    []Position
+	matchIndex int
+	cursor     int
+	cursorCol  int
+	goalCol    int
+	top        int
+	topSub     int
+	hOffset    int
+	followAuto bool
+}
+
+func (v *Viewer) saveSearchState() searchState {
+	return searchState{
+		query:      v.Query,
+		queryRe:    v.QueryRe,
+		matches:    v.Matches,
+		matchIndex: v.MatchIndex,
+		cursor:     v.Cursor,
+		cursorCol:  v.CursorCol,
+		goalCol:    v.GoalCol,
+		top:        v.Top,
+		topSub:     v.TopSub,
+		hOffset:    v.HOffset,
+		followAuto: v.FollowAuto,
+	}
+}
+
+func (v *Viewer) restoreSearchState(s searchState) {
+	v.Query = s.query
+	v.QueryRe = s.queryRe
+	v.Matches = s.matches
+	v.MatchIndex = s.matchIndex
+	v.Cursor = s.cursor
+	v.CursorCol = s.cursorCol
+	v.GoalCol = s.goalCol
+	v.Top = s.top
+	v.TopSub = s.topSub
+	v.HOffset = s.hOffset
+	v.FollowAuto = s.followAuto
+}
+
+// search reads a query from the prompt, previewing matches as it is typed.
+// Canceling the prompt restores the view to where the search started.
+func (v *Viewer) search(reader *bufio.Reader, prefix string, dir int) {
+	saved := v.saveSearchState()
+	preview := func(query string) {
+		v.restoreSearchState(saved)
+		if strings.TrimSpace(query) != "" {
+			v.setQuery(query, dir)
+		}
+		v.Status = ""
+		v.draw()
+	}
+	query, canceled := v.prompt(reader, prefix, v.History, preview)
+	v.restoreSearchState(saved)
+	if canceled {
+		return
+	}
+	defer v.historyStatus(v.History.Add(query))
+	v.setQuery(query, dir)
+}
+
+// historyStatus reports a failure to save the history once the command
+// that added to it has set its own status.
+func (v *Viewer) historyStatus(err error) {
+	if err != nil {
+		v.Status = fmt.Sprintf("history not saved: %v", err)
+	}
+}
+
+func (v *Viewer) setQuery(query string, dir int) {
+	v.Query = strings.TrimSpace(query)
+	v.QueryRe = nil
+	v.Matches = nil
+	v.MatchIndex = 0
+	if v.Query == "" {
+		return
+	}
+	re, err := v.compileQuery(v.Query)
+	if err != nil {
+		v.Status = fmt.Sprintf("invalid regex: %v", err)
+		return
+	}
+	v.QueryRe = re
+	v.Matches = v.findMatches(re)
+	if len(v.Matches) == 0 {
+		v.Status = "no matches"
+		return
+	}
+	v.MatchIndex = v.closestMatchIndex(dir)
+	v.jumpToMatch()
+	v.Status = matchSummary(v.Matches)
+}
+
+func matchSummary(matches []Position) string {
+	lines := 0
+	last := -1
+	for _, m := range matches {
+		if m.Line != last {
+			lines++
+			last = m.Line
+		}
+	}
+	return fmt.Sprintf("%d matches across %d lines", len(matches), lines)
+}
+
+func (v *Viewer) countMatches(pattern string) {
+	pattern = strings.TrimSpace(pattern)
+	re := v.QueryRe
+	if pattern != "" {
+		var err error
+		re, err = v.compileQuery(pattern)
+		if err != nil {
+			v.Status = fmt.Sprintf("invalid regex: %v", err)
+			return
+		}
+	} else {
+		pattern = v.Query
+	}
+	if re == nil {
+		v.Status = "no pattern"
+		return
+	}
+	v.Status = pattern + ": " + matchSummary(v.findMatches(re))
+}
+
+func (v *Viewer) findMatches(re *regexp.Regexp) []Position {
+	var out []Position
+	for i := 0; i < v.lineCount(); i++ {
+		line := v.line(i)
+		for _, idx := range re.FindAllStringIndex(line, -1) {
+			if idx[0] == idx[1] && idx[0] == len(line) && len(line) > 0 {
+				continue
+			}
+			out = append(out, Position{Line: i, Col: utf8.RuneCountInString(line[:idx[0]])})
+		}
+	}
+	return out
+}
+
+func (v *Viewer) refreshMatches() {
+	if v.QueryRe == nil {
+		return
+	}
+	v.Matches = v.findMatches(v.QueryRe)
+	v.MatchIndex = v.closestMatchIndex(1)
+}
+
+func (v *Viewer) toggleRegex() {
+	v.Regex = !v.Regex
+	if v.Regex {
+		v.Status = "regex search"
+	} else {
+		v.Status = "literal search"
+	}
+	if v.Query != "" {
+		status := v.Status
+		v.setQuery(v.Query, 1)
+		if v.Status == "" {
+			v.Status = status
+		}
+	}
+}
+
+func (v *Viewer) cycleCaseMode() {
+	v.CaseMode = (v.CaseMode + 1) % 3
+	status := "search: " + v.CaseMode.String()
+	if v.Query != "" {
+		v.setQuery(v.Query, 1)
+	}
+	if v.Status == "" {
+		v.Status = status
+	}
+}
+
+func (v *Viewer) closestMatchIndex(dir int) int {
+	if len(v.Matches) == 0 {
+		return 0
+	}
+	cur := Position{Line: v.Cursor, Col: v.CursorCol}
+	if dir >= 0 {
+		for i, m := range v.Matches {
+			if m.Line > cur.Line || (m.Line == cur.Line && m.Col >= cur.Col) {
+				return i
+			}
+		}
+		return 0
+	}
+	for i := len(v.Matches) - 1; i >= 0; i-- {
+		m := v.Matches[i]
+		if m.Line < cur.Line || (m.Line == cur.Line && m.Col <= cur.Col) {
+			return i
+		}
+	}
+	return len(v.Matches) - 1
+}
+
+func (v *Viewer) nextMatch(dir int) {
+	if len(v.Matches) == 0 {
+		v.Status = "no matches"
+		return
+	}
+	v.MatchIndex += dir
+	if v.MatchIndex < 0 {
+		v.MatchIndex = len(v.Matches) - 1
+	}
+	if v.MatchIndex >= len(v.Matches) {
+		v.MatchIndex = 0
+	}
+	v.jumpToMatch()
+}
+
+func (v *Viewer) jumpToMatch() {
+	m := v.Matches[v.MatchIndex]
+	v.Cursor = m.Line
+	v.CursorCol = m.Col
+	v.clampCursor()
+	v.GoalCol = v.CursorCol
+	if v.Follow {
+		v.FollowAuto = false
+	}
+	v.Status = ""
+}
+
+// matchSpans highlights query matches in text, which starts at rune column
+// startCol of line lineIdx. The current match is shown in reverse video and
+// the others are underlined. Matches are found on the whole line, so one
+// that is wrapped or scrolled partly out of text still shows; a startCol
+// below 0 means text is not part of the line and is searched on its own,
+// with every match underlined.
+func (v *Viewer) matchSpans(text string, lineIdx int, startCol int) []color.Span {
+	if v.QueryRe == nil {
+		return nil
+	}
+	current := Position{Line: -1}
+	if v.MatchIndex >= 0 && v.MatchIndex < len(v.Matches) {
+		current = v.Matches[v.MatchIndex]
+	}
+	line, offset := text, 0
+	if startCol >= 0 {
+		line = v.line(lineIdx)
+		offset = byteOffset(line, startCol)
+	}
+	end := offset + len(text)
+	var spans []color.Span
+	for _, idx := range v.QueryRe.FindAllStringIndex(line, -1) {
+		if idx[0] == idx[1] || idx[1] <= offset || idx[0] >= end {
+			continue
+		}
+		// The current match is told by where it starts in the line, which
+		// may be before text.
+		style := "underline"
+		if startCol >= 0 && current.Line == lineIdx && current.Col == utf8.RuneCountInString(line[:idx[0]]) {
+			style = "reverse"
+		}
+		spans = append(spans, color.Span{Start: max(idx[0], offset) - offset, End: min(idx[1], end) - offset, Style: style})
+	}
+	return spans
+}
@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"tilo/internal/color"
+)
+
+func testViewer(lines ...string) *Viewer {
+	return newViewer(Buffer{Name: "test", Lines: lines}, nil, Options{}, nil)
+}
+
+func TestCompileQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		regex bool
+		mode  CaseMode
+		text  string
+		match bool
+	}{
+		{"error", false, CaseIgnore, "ERROR here", true},
+		{"Error", false, CaseIgnore, "error here", true},
+		{"error", false, CaseSmart, "ERROR here", true},
+		{"Error", false, CaseSmart, "error here", false},
+		{"Error", false, CaseSmart, "Error here", true},
+		{"error", false, CaseSensitive, "ERROR here", false},
+		{"a.c", false, CaseIgnore, "abc", false},
+		{"a.c", false, CaseIgnore, "a.c", true},
+		{"a.c", true, CaseIgnore, "abc", true},
+		{`\d+ms`, true, CaseSmart, "took 12MS", true},
+		{`\D`, true, CaseSmart, "12", false},
+	}
+	for _, tt := range tests {
+		v := testViewer()
+		v.Regex = tt.regex
+		v.CaseMode = tt.mode
+		re, err := v.compileQuery(tt.query)
+		if err != nil {
+			t.Errorf("compileQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if got := re.MatchString(tt.text); got != tt.match {
+			t.Errorf("compileQuery(%q) regex=%v %v matches %q = %v, want %v", tt.query, tt.regex, tt.mode, tt.text, got, tt.match)
+		}
+	}
+	v := testViewer()
+	v.Regex = true
+	if _, err := v.compileQuery("a("); err == nil {
+		t.Error("compileQuery(a(): no error")
+	}
+}
+
+func TestParseCaseMode(t *testing.T) {
+	tests := []struct {
+		s    string
+		want CaseMode
+		ok   bool
+	}{
+		{"", CaseIgnore, true},
+		{"ignore", CaseIgnore, true},
+		{" Smart ", CaseSmart, true},
+		{"sensitive", CaseSensitive, true},
+		{"upper", CaseIgnore, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseCaseMode(tt.s)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseCaseMode(%q) = %v, %v", tt.s, got, err)
+		}
+	}
+}
+
+func TestMatchSpans(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		current  int
+		text     string
+		startCol int
+		want     []color.Span
+	}{
+		{
+			name: "whole line", line: "ab xab", current: 0, text: "ab xab", startCol: 0,
+			want: []color.Span{{Start: 0, End: 2, Style: "reverse"}, {Start: 4, End: 6, Style: "underline"}},
+		},
+		{
+			name: "second current", line: "ab xab", current: 1, text: "ab xab", startCol: 0,
+			want: []color.Span{{Start: 0, End: 2, Style: "underline"}, {Start: 4, End: 6, Style: "reverse"}},
+		},
+		{
+			// A wrapped segment shows the part of a match that falls in it,
+			// still reversed when the match started before it.
+			name: "segment", line: "ab xab", current: 0, text: "b xa", startCol: 1,
+			want: []color.Span{{Start: 0, End: 1, Style: "reverse"}, {Start: 3, End: 4, Style: "underline"}},
+		},
+		{
+			name: "segment misses", line: "ab xab", current: 0, text: " x", startCol: 2,
+			want: nil,
+		},
+		{
+			name: "multibyte", line: "é ab", current: 0, text: "b", startCol: 3,
+			want: []color.Span{{Start: 0, End: 1, Style: "reverse"}},
+		},
+		{
+			// Table rows are not part of the line, so no match is current.
+			name: "table row", line: "ab xab", current: 0, text: "ab | ab", startCol: -1,
+			want: []color.Span{{Start: 0, End: 2, Style: "underline"}, {Start: 5, End: 7, Style: "underline"}},
+		},
+	}
+	for _, tt := range tests {
+		v := testViewer(tt.line)
+		v.QueryRe = regexp.MustCompile("ab")
+		v.Matches = v.findMatches(v.QueryRe)
+		v.MatchIndex = tt.current
+		if got := v.matchSpans(tt.text, 0, tt.startCol); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matchSpans = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
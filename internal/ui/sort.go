@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+type SortKey int
+
+const (
+	SortNone SortKey = iota
+	SortText
+	SortTime
+)
+
+// setSort reorders the view by line text, or by timestamp with -t. The
+// lines themselves are left in input order.
+func (v *Viewer) setSort(arg string, reverse bool) {
+	key := SortText
+	switch strings.TrimSpace(arg) {
+	case "":
+	case "-t":
+		key = SortTime
+	default:
+		v.Status = "usage: sort[!] [-t]"
+		return
+	}
+	v.SortKey = key
+	v.SortReverse = reverse
+	v.rebuildView()
+	v.Status = ""
+}
+
+func (v *Viewer) clearSort() {
+	if v.SortKey == SortNone {
+		v.Status = "not sorted"
+		return
+	}
+	v.SortKey = SortNone
+	v.SortReverse = false
+	v.rebuildView()
+	v.Status = "input order"
+}
+
+// sortLess orders line indexes by the sort key, either way round.
+func (v *Viewer) sortLess() func(a, b int) bool {
+	less := func(a, b int) bool {
+		if v.SortKey == SortTime {
+			return v.lineTime(a).Before(v.lineTime(b))
+		}
+		return v.Lines[a] < v.Lines[b]
+	}
+	if v.SortReverse {
+		return func(a, b int) bool { return less(b, a) }
+	}
+	return less
+}
+
+// mergeSorted sorts rows, which are new to the view, into the sorted view.
+// Rows that compare equal stay in input order.
+func (v *Viewer) mergeSorted(rows []int) {
+	if len(rows) == 0 {
+		return
+	}
+	less := v.sortLess()
+	rows = slices.Clone(rows)
+	sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	merged := make([]int, 0, len(v.View)+len(rows))
+	i := 0
+	for _, row := range rows {
+		for i < len(v.View) && !less(row, v.View[i]) {
+			merged = append(merged, v.View[i])
+			i++
+		}
+		merged = append(merged, row)
+	}
+	v.View = append(merged, v.View[i:]...)
+}
+
+func (v *Viewer) sortStatus() string {
+	if v.SortKey == SortNone {
+		return ""
+	}
+	status := "sort"
+	if v.SortReverse {
+		status = "sort!"
+	}
+	if v.SortKey == SortTime {
+		status += " -t"
+	}
+	return status
+}
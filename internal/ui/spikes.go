@@ -0,0 +1,162 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+
+	"tilo/internal/color"
+)
+
+const (
+	spikeWindow    = 200
+	spikeMinErrors = 5
+	spikeFactor    = 4
+)
+
+// spikeRegions returns the ranges of Lines where the error rate over a
+// rolling window of spikeWindow lines is spikeFactor times the rate of the
+// input so far. Lines that arrive later are scanned on their own, along
+// with the last window before them, whose rates they change.
+func (v *Viewer) spikeRegions() []posRange {
+	n := len(v.Lines)
+	if v.spikesScanned == n {
+		return v.spikes
+	}
+	if v.errorCounts == nil {
+		v.errorCounts = []int{0}
+	}
+	// errorCounts[i] is the number of error lines before line i.
+	for i := len(v.errorCounts) - 1; i < n; i++ {
+		count := v.errorCounts[i]
+		if v.isErrorLine(i) {
+			count++
+		}
+		v.errorCounts = append(v.errorCounts, count)
+	}
+	from := max(v.spikesScanned-spikeWindow, 0)
+	v.spikesScanned = n
+	prefix := v.errorCounts
+	if prefix[n] == 0 || n < spikeWindow {
+		v.spikes = nil
+		return nil
+	}
+	for len(v.spikes) > 0 && v.spikes[len(v.spikes)-1].start >= from {
+		v.spikes = v.spikes[:len(v.spikes)-1]
+	}
+	inSpike := false
+	if last := len(v.spikes) - 1; last >= 0 && v.spikes[last].end >= from {
+		v.spikes[last].end = from
+		inSpike = true
+	}
+	baseline := float64(prefix[n]) / float64(n)
+	for i := from; i < n; i++ {
+		lo := max(i-spikeWindow/2, 0)
+		hi := min(lo+spikeWindow, n)
+		lo = max(hi-spikeWindow, 0)
+		errors := prefix[hi] - prefix[lo]
+		rate := float64(errors) / float64(hi-lo)
+		spike := errors >= spikeMinErrors && rate >= spikeFactor*baseline
+		switch {
+		case spike && !inSpike:
+			v.spikes = append(v.spikes, posRange{start: i, end: i + 1})
+		case spike:
+			v.spikes[len(v.spikes)-1].end = i + 1
+		}
+		inSpike = spike
+	}
+	return v.spikes
+}
+
+// dropSpikes moves the spikes and error counts along when the first n
+// lines are dropped.
+func (v *Viewer) dropSpikes(n int) {
+	if v.spikesScanned < n {
+		v.resetSpikes()
+		return
+	}
+	var spikes []posRange
+	for _, r := range v.spikes {
+		if r.end > n {
+			spikes = append(spikes, posRange{start: max(r.start-n, 0), end: r.end - n})
+		}
+	}
+	v.spikes = spikes
+	v.spikesScanned -= n
+	if len(v.errorCounts) > n {
+		dropped := v.errorCounts[n]
+		counts := make([]int, len(v.errorCounts)-n)
+		for i := range counts {
+			counts[i] = v.errorCounts[i+n] - dropped
+		}
+		v.errorCounts = counts
+	} else {
+		v.errorCounts = nil
+	}
+}
+
+func (v *Viewer) resetSpikes() {
+	v.spikes = nil
+	v.spikesScanned = 0
+	v.errorCounts = nil
+}
+
+func (v *Viewer) inSpike(idx int) bool {
+	spikes := v.spikeRegions()
+	i := sort.Search(len(spikes), func(i int) bool { return spikes[i].end > idx })
+	return i < len(spikes) && spikes[i].start <= idx
+}
+
+func (v *Viewer) toggleSpikes() {
+	v.ShowSpikes = !v.ShowSpikes
+	if v.ShowSpikes {
+		v.Status = fmt.Sprintf("%d error spikes", len(v.spikeRegions()))
+	} else {
+		v.Status = ""
+	}
+}
+
+// nextSpike moves to the start of the next (dir 1) or previous (dir -1)
+// error spike and turns the spike markers on.
+func (v *Viewer) nextSpike(dir int) {
+	v.ShowSpikes = true
+	spikes := v.spikeRegions()
+	if len(spikes) == 0 {
+		v.Status = "no error spikes"
+		return
+	}
+	cur := v.lineIndex(v.Cursor)
+	target := -1
+	if dir > 0 {
+		for _, r := range spikes {
+			if r.start > cur {
+				target = r.start
+				break
+			}
+		}
+	} else {
+		for i := len(spikes) - 1; i >= 0; i-- {
+			if spikes[i].start < cur {
+				target = spikes[i].start
+				break
+			}
+		}
+	}
+	if target < 0 {
+		v.Status = "no more error spikes"
+		return
+	}
+	v.jumpTo(v.viewIndex(target))
+	for i, r := range spikes {
+		if r.start == target {
+			v.Status = fmt.Sprintf("error spike %d/%d (%d lines)", i+1, len(spikes), r.end-r.start)
+		}
+	}
+}
+
+// spikeColumn is the gutter marker for lines inside an error spike.
+func (v *Viewer) spikeColumn(lineIdx int) string {
+	if v.inSpike(v.lineIndex(lineIdx)) {
+		return color.Wrap("▌", "red", "")
+	}
+	return " "
+}
@@ -0,0 +1,269 @@
+package ui
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+
+	"tilo/internal/level"
+)
+
+// A split shows several windows at once, side by side (vertical) or
+// stacked. Each window is a viewer with its own cursor, search, filters and
+// follow state; a buffer shown in two windows gets a second viewer over the
+// same lines.
+
+// rect is the part of the screen a window draws in. The zero rect means
+// the whole terminal.
+type rect struct {
+	x, y          int
+	width, height int
+}
+
+// inactiveBG is the status bar of the windows that do not have the keys.
+const inactiveBG = "\x1b[40m"
+
+// size is the width and height the viewer draws in: its window in a
+// split, otherwise the terminal.
+func (v *Viewer) size() (int, int) {
+	if v.pane.width > 0 {
+		return v.pane.width, v.pane.height
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+	return width, height
+}
+
+func (l *bufferList) active() *Viewer {
+	return l.windows[l.focus]
+}
+
+// following returns the viewers that show buffer i, which all get its
+// new lines.
+func (l *bufferList) following(i int) []*Viewer {
+	out := []*Viewer{l.viewers[i]}
+	for _, w := range l.windows {
+		if w.buffer == i && w != l.viewers[i] {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// viewerFor returns the viewer to show buffer i in window w: the buffer's
+// own viewer, or a copy of it when another window already shows it.
+func (l *bufferList) viewerFor(i, w int) *Viewer {
+	v := l.viewers[i]
+	for j, other := range l.windows {
+		if j != w && other == v {
+			return l.clone(v)
+		}
+	}
+	return v
+}
+
+// clone makes a second viewer over the lines of src, starting where src
+// is and with the same filters, folds, marks and search.
+func (l *bufferList) clone(src *Viewer) *Viewer {
+	buf := Buffer{
+		Name:     src.Name,
+		Lines:    src.Lines[:len(src.Lines):len(src.Lines)],
+		LineBase: src.LineBase,
+		Rules:    src.Rules,
+	}
+	// The startup filters are already part of src's state.
+	opts := l.opts
+	opts.MinLevel = level.None
+	opts.Filter, opts.Exclude = nil, nil
+	opts.Dedupe, opts.AtEnd = false, false
+	v := newViewer(buf, src.Rules, opts, l.async)
+	v.buffer = src.buffer
+	v.buffers = l
+	v.History = src.History
+	v.CommandHistory = src.CommandHistory
+	v.SourceStatus = src.SourceStatus
+	v.ansiSpans = maps.Clone(src.ansiSpans)
+	// Watches fire once, from the buffer's own viewer.
+	v.Watches = nil
+	v.Follow = src.Follow
+	v.FollowAuto = src.FollowAuto
+	v.Wrap = src.Wrap
+	v.LineNumbers = src.LineNumbers
+	v.Filters = slices.Clone(src.Filters)
+	v.FilterContext = src.FilterContext
+	v.MinLevel = src.MinLevel
+	v.TimeRange = src.TimeRange
+	v.TraceID = src.TraceID
+	v.RecordMode = src.RecordMode
+	v.Dedupe = src.Dedupe
+	v.SortKey = src.SortKey
+	v.SortReverse = src.SortReverse
+	v.Highlights = slices.Clone(src.Highlights)
+	v.marks = maps.Clone(src.marks)
+	v.Query = src.Query
+	v.QueryRe = src.QueryRe
+	v.Regex = src.Regex
+	v.CaseMode = src.CaseMode
+	v.setFolds(maps.Clone(src.Folded))
+	v.Cursor = v.viewIndex(max(src.lineIndex(src.Cursor), 0))
+	v.CursorCol = src.CursorCol
+	v.GoalCol = src.GoalCol
+	v.Top = v.viewIndex(max(src.lineIndex(src.Top), 0))
+	v.MatchIndex = src.MatchIndex
+	v.clampCursor()
+	return v
+}
+
+// split opens a new window after the current one, showing the current
+// buffer or the one named by arg. Splitting the other way than the open
+// windows turns them all.
+func (v *Viewer) split(arg string, vertical bool) {
+	l := v.buffers
+	i := v.buffer
+	if arg != "" {
+		var ok bool
+		if i, ok = l.findBuffer(arg); !ok {
+			v.Status = "no such buffer: " + arg
+			return
+		}
+	}
+	w := l.focus + 1
+	l.windows = append(l.windows[:w], append([]*Viewer{nil}, l.windows[w:]...)...)
+	l.windows[w] = l.viewerFor(i, w)
+	l.vertical = vertical
+	l.focusWindow(w)
+	l.clearScreen()
+}
+
+// closeWindow closes the current window; the last one stays.
+func (v *Viewer) closeWindow() {
+	l := v.buffers
+	if len(l.windows) < 2 {
+		v.Status = "only one window"
+		return
+	}
+	l.windows = append(l.windows[:l.focus], l.windows[l.focus+1:]...)
+	l.focusWindow(min(l.focus, len(l.windows)-1))
+	l.clearScreen()
+}
+
+// onlyWindow closes every window but the current one.
+func (v *Viewer) onlyWindow() {
+	l := v.buffers
+	l.windows = []*Viewer{l.active()}
+	l.focusWindow(0)
+	l.clearScreen()
+}
+
+// nextWindow moves the keys to another window, wrapping around.
+func (v *Viewer) nextWindow(dir int) {
+	l := v.buffers
+	n := len(l.windows)
+	if n < 2 {
+		v.Status = "only one window"
+		return
+	}
+	l.focusWindow(((l.focus+dir)%n + n) % n)
+}
+
+func (l *bufferList) focusWindow(w int) {
+	l.focus = w
+	l.current = l.windows[w].buffer
+}
+
+func (l *bufferList) clearScreen() {
+	fmt.Fprint(os.Stdout, clearScreen)
+	for _, w := range l.windows {
+		w.TopSub = 0
+	}
+}
+
+// layout gives every window its part of the screen. Side-by-side windows
+// are separated by a column of lines.
+func (l *bufferList) layout() {
+	top := 0
+	if l.showTabs() {
+		top = 1
+	}
+	if len(l.windows) == 1 && top == 0 {
+		l.windows[0].pane = rect{}
+		return
+	}
+	width, height := l.screenSize()
+	height -= top
+	n := len(l.windows)
+	total := height
+	if l.vertical {
+		total = width - (n - 1)
+	}
+	pos := 0
+	for i, w := range l.windows {
+		size := total / n
+		if i < total%n {
+			size++
+		}
+		if l.vertical {
+			w.pane = rect{x: pos, y: top, width: max(size, 1), height: height}
+			pos += size + 1
+		} else {
+			w.pane = rect{y: top + pos, width: width, height: max(size, 2)}
+			pos += size
+		}
+	}
+}
+
+// draw draws every window, the current one last so that the terminal
+// cursor ends up in it.
+func (l *bufferList) draw() {
+	l.layout()
+	if l.showTabs() {
+		width, _ := l.screenSize()
+		l.drawTabs(width)
+	}
+	for i, w := range l.windows {
+		w.inactive = len(l.windows) > 1 && i != l.focus
+		if i != l.focus {
+			w.draw()
+		}
+	}
+	if l.vertical && len(l.windows) > 1 {
+		var sep strings.Builder
+		for _, w := range l.windows[:len(l.windows)-1] {
+			x := w.pane.x + w.pane.width + 1
+			for y := w.pane.y + 1; y <= w.pane.y+w.pane.height; y++ {
+				fmt.Fprintf(&sep, "\x1b[%d;%dH│", y, x)
+			}
+		}
+		fmt.Fprint(os.Stdout, sep.String())
+	}
+	l.active().draw()
+}
+
+func (l *bufferList) screenSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+	return width, height
+}
+
+// windowAt returns the index of the window at the 1-based screen cell x,
+// y.
+func (l *bufferList) windowAt(x, y int) (int, bool) {
+	for i, w := range l.windows {
+		p := w.pane
+		if p.width == 0 {
+			return i, true
+		}
+		if x > p.x && x <= p.x+p.width && y > p.y && y <= p.y+p.height {
+			return i, true
+		}
+	}
+	return 0, false
+}
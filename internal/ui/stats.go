@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// durationKeyRe matches duration-like fields; the unit may come from
+	// the value or from a key suffix such as _ms.
+	durationKeyRe = regexp.MustCompile(`(?i)\b(?:duration|latency|elapsed|took|rt|response_time|request_time)(_ms|_us|_s|_sec|_seconds)?"?\s*[:=]\s*"?(\d+(?:\.\d+)?)(ns|us|µs|ms|s|m|h)?\b`)
+	durationRe    = regexp.MustCompile(`\b(\d+(?:\.\d+)?)(ns|us|µs|ms|s|m|h)\b`)
+)
+
+var keyUnits = map[string]string{"_ms": "ms", "_us": "us", "_s": "s", "_sec": "s", "_seconds": "s"}
+
+// lineDuration returns the duration a line reports, preferring named
+// fields over bare tokens like 120ms. A number without a unit is taken as
+// milliseconds.
+func lineDuration(line string) (time.Duration, bool) {
+	if m := durationKeyRe.FindStringSubmatch(line); m != nil {
+		unit := m[3]
+		if unit == "" {
+			unit = keyUnits[strings.ToLower(m[1])]
+		}
+		if unit == "" {
+			unit = "ms"
+		}
+		return parseDuration(m[2], unit)
+	}
+	if m := durationRe.FindStringSubmatch(line); m != nil {
+		return parseDuration(m[1], m[2])
+	}
+	return 0, false
+}
+
+func parseDuration(value, unit string) (time.Duration, bool) {
+	if unit == "us" {
+		unit = "µs"
+	}
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return 0, false
+	}
+	d, err := time.ParseDuration(value + unit)
+	return d, err == nil
+}
+
+// statsRows returns the view rows a report covers: the selected rows when
+// a selection is active, otherwise the whole view.
+func (v *Viewer) statsRows() (int, int) {
+	if v.SelectMode != SelectNone && v.SelectStart != nil {
+		from, to := v.SelectStart.Line, v.Cursor
+		if from > to {
+			from, to = to, from
+		}
+		return from, to + 1
+	}
+	return 0, v.lineCount()
+}
+
+func (v *Viewer) showStats(arg string) {
+	switch strings.TrimSpace(arg) {
+	case "duration", "latency":
+		v.durationStats()
+	default:
+		v.Status = "usage: stats duration"
+	}
+}
+
+func (v *Viewer) durationStats() {
+	from, to := v.statsRows()
+	var ds []time.Duration
+	for i := from; i < to; i++ {
+		if d, ok := lineDuration(v.line(i)); ok {
+			ds = append(ds, d)
+		}
+	}
+	if len(ds) == 0 {
+		v.Status = "no durations found"
+		return
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	pct := func(p float64) time.Duration {
+		i := int(math.Ceil(p/100*float64(len(ds)))) - 1
+		return ds[max(i, 0)]
+	}
+	v.Status = fmt.Sprintf("durations n=%d min=%s p50=%s p95=%s p99=%s max=%s",
+		len(ds), formatDuration(ds[0]), formatDuration(pct(50)), formatDuration(pct(95)), formatDuration(pct(99)), formatDuration(ds[len(ds)-1]))
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return d.String()
+	case d < time.Second:
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64) + "ms"
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,251 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"tilo/internal/color"
+	"tilo/internal/fields"
+)
+
+// TableColumn is one column of the table view. A zero Width lets the
+// column take the rest of the row.
+type TableColumn struct {
+	Field string
+	Width int
+}
+
+// extraColumn collects every field not shown in another column.
+const extraColumn = "extra"
+
+func defaultColumns() []TableColumn {
+	return []TableColumn{
+		{Field: "time", Width: 24},
+		{Field: "level", Width: 5},
+		{Field: "msg", Width: 48},
+		{Field: extraColumn},
+	}
+}
+
+func (v *Viewer) toggleTable() {
+	v.TableView = !v.TableView
+	if len(v.Columns) == 0 {
+		v.Columns = defaultColumns()
+	}
+	if v.TableView {
+		v.Status = "table view"
+	} else {
+		v.Status = "table view off"
+	}
+}
+
+// setColumns replaces the columns with a comma or space separated list of
+// field names and turns the table view on. Without names it toggles it.
+func (v *Viewer) setColumns(arg string) {
+	names := fields.SplitPaths(arg)
+	if len(names) == 0 {
+		v.toggleTable()
+		return
+	}
+	v.Columns = nil
+	for i, name := range names {
+		col := TableColumn{Field: fields.Path(name), Width: 16}
+		if i == len(names)-1 {
+			col.Width = 0
+		}
+		v.Columns = append(v.Columns, col)
+	}
+	v.TableView = true
+	v.Status = ""
+}
+
+// headerRows is the number of rows the table header takes above the
+// content.
+func (v *Viewer) headerRows() int {
+	if v.TableView {
+		return 1
+	}
+	return 0
+}
+
+func (v *Viewer) tableHeader(width int) string {
+	cells := make([]string, len(v.Columns))
+	for i, col := range v.Columns {
+		cells[i] = fitCell(col.Field, col.Width)
+	}
+	header := strings.Repeat(" ", v.gutterWidth()) + strings.Join(cells, " │ ")
+	return color.Wrap(padRight(truncateANSI(header, width), width), "", "bold")
+}
+
+// tableRow lays out the fields of view row i in columns. Lines that are
+// not JSON or logfmt are shown as they are.
+func (v *Viewer) tableRow(i int) string {
+	line := v.line(i)
+	fs := fields.Parse(line)
+	if fs == nil {
+		return line
+	}
+	shown := map[string]bool{}
+	for _, col := range v.Columns {
+		if key, ok := fields.Resolve(fs, col.Field); ok {
+			shown[key] = true
+		}
+	}
+	cells := make([]string, len(v.Columns))
+	for c, col := range v.Columns {
+		value := ""
+		if col.Field == extraColumn {
+			var extra []string
+			for _, f := range fs {
+				if !shown[f.Key] {
+					extra = append(extra, f.Key+"="+f.Value)
+				}
+			}
+			value = strings.Join(extra, " ")
+		} else {
+			value, _ = fields.Get(fs, col.Field)
+		}
+		cells[c] = fitCell(value, col.Width)
+	}
+	return strings.Join(cells, " │ ")
+}
+
+func (v *Viewer) renderTableRow(i int) string {
+	row := v.tableRow(i)
+	if v.Plain {
+		return v.gutter(i) + row
+	}
+	spans := append(v.matchSpans(row, i, -1), v.highlightSpans(row)...)
+	return v.gutter(i) + color.ApplyRulesWithSpans(row, v.Rules, spans)
+}
+
+// fitCell pads or cuts text to width runes, marking cut text with "…".
+func fitCell(text string, width int) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	if width <= 0 {
+		return text
+	}
+	n := utf8.RuneCountInString(text)
+	if n > width {
+		runes := []rune(text)
+		return string(runes[:width-1]) + "…"
+	}
+	return text + strings.Repeat(" ", width-n)
+}
+
+// editColumns lists the table columns and lets the user add, remove,
+// resize and reorder them. Enter shows the table with them; Esc and q put
+// the columns back as they were.
+func (v *Viewer) editColumns(reader *bufio.Reader) {
+	saved := slices.Clone(v.Columns)
+	if len(v.Columns) == 0 {
+		v.Columns = defaultColumns()
+	}
+	selected := 0
+	for {
+		lines := []string{overlayTitle("Columns"), ""}
+		for i, col := range v.Columns {
+			width := "rest"
+			if col.Width > 0 {
+				width = fmt.Sprintf("%d", col.Width)
+			}
+			row := fmt.Sprintf("  %-24s %s", col.Field, width)
+			if i == selected {
+				row = reverseOn + row + reverseOff
+			}
+			lines = append(lines, row)
+		}
+		v.drawOverlay(lines, 0, "[j/k move] [a add] [x remove] [</> width] [J/K reorder] [Enter apply] [Esc/q cancel]")
+		b, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 'j':
+			if selected < len(v.Columns)-1 {
+				selected++
+			}
+		case 'k':
+			if selected > 0 {
+				selected--
+			}
+		case 'a':
+			name, canceled := v.prompt(reader, "column: ", nil, nil)
+			name = strings.TrimSpace(name)
+			if canceled || name == "" {
+				continue
+			}
+			at := selected + 1
+			if len(v.Columns) == 0 {
+				at = 0
+			}
+			col := TableColumn{Field: name, Width: 16}
+			v.Columns = append(v.Columns[:at], append([]TableColumn{col}, v.Columns[at:]...)...)
+			selected = at
+		case 'x':
+			if len(v.Columns) == 0 {
+				continue
+			}
+			v.Columns = append(v.Columns[:selected], v.Columns[selected+1:]...)
+			if selected >= len(v.Columns) && selected > 0 {
+				selected--
+			}
+		case '<', '>':
+			if len(v.Columns) == 0 {
+				continue
+			}
+			col := &v.Columns[selected]
+			if col.Width == 0 {
+				col.Width = 16
+			}
+			if b == '<' {
+				col.Width -= 2
+			} else {
+				col.Width += 2
+			}
+			if col.Width < 2 {
+				col.Width = 2
+			}
+		case 'J':
+			if selected < len(v.Columns)-1 {
+				v.Columns[selected], v.Columns[selected+1] = v.Columns[selected+1], v.Columns[selected]
+				selected++
+			}
+		case 'K':
+			if selected > 0 {
+				v.Columns[selected], v.Columns[selected-1] = v.Columns[selected-1], v.Columns[selected]
+				selected--
+			}
+		case '\r', '\n':
+			v.TableView = true
+			v.Status = fmt.Sprintf("%d columns", len(v.Columns))
+			return
+		case 'q', 0x1b:
+			v.Columns = saved
+			return
+		}
+	}
+}
+
+// extract shows only the lines that have every field in arg, with those
+// fields as the table columns.
+func (v *Viewer) extract(arg string) {
+	paths := fields.SplitPaths(arg)
+	if len(paths) == 0 {
+		v.Status = "usage: :extract .field[,.field...]"
+		return
+	}
+	for i, p := range paths {
+		paths[i] = fields.Path(p)
+	}
+	v.pushWhere(strings.Join(paths, " && "))
+	if v.Status != "" && v.Status != "no matching lines" {
+		return
+	}
+	status := v.Status
+	v.setColumns(arg)
+	v.Status = status
+}
@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tabSpan is where the tab of a buffer was drawn in the tab bar, in
+// 1-based screen columns.
+type tabSpan struct {
+	start, end int
+	buffer     int
+}
+
+// showTabs reports whether the tab bar is drawn: with more than one
+// buffer, unless it was turned off.
+func (l *bufferList) showTabs() bool {
+	return l.tabs && len(l.viewers) > 1
+}
+
+func (l *bufferList) toggleTabs() string {
+	l.tabs = !l.tabs
+	fmt.Fprint(os.Stdout, clearScreen)
+	if l.tabs {
+		return "tab bar on"
+	}
+	return "tab bar off"
+}
+
+func tabLabel(i int, name string) string {
+	if base := filepath.Base(name); base != "." && base != "/" {
+		name = base
+	}
+	return fmt.Sprintf(" %d %s ", i+1, name)
+}
+
+// drawTabs draws the tab bar on the first row of the screen. When the tabs
+// do not fit, the bar starts at a later tab so the current one shows.
+func (l *bufferList) drawTabs(width int) {
+	labels := make([]string, len(l.viewers))
+	for i, v := range l.viewers {
+		labels[i] = tabLabel(i, v.Name)
+	}
+	first := 0
+	for {
+		used := min(first, 1) // the < marking hidden tabs
+		for _, label := range labels[first : l.current+1] {
+			used += visibleWidth(label) + 1
+		}
+		if used <= width || first == l.current {
+			break
+		}
+		first++
+	}
+	var bar strings.Builder
+	l.tabSpans = l.tabSpans[:0]
+	col := 1
+	if first > 0 {
+		bar.WriteString("<")
+		col++
+	}
+	for i := first; i < len(labels); i++ {
+		label := labels[i]
+		if i == l.current {
+			label = reverseOn + label + reverseOff
+		}
+		bar.WriteString(label + " ")
+		w := visibleWidth(labels[i])
+		l.tabSpans = append(l.tabSpans, tabSpan{start: col, end: col + w - 1, buffer: i})
+		col += w + 1
+	}
+	fmt.Fprint(os.Stdout, "\x1b[1;1H"+statusBG+statusFG+padRight(bar.String(), width)+resetStyle)
+}
+
+// tabAt returns the buffer whose tab is at the 1-based screen cell x, y.
+func (l *bufferList) tabAt(x, y int) (int, bool) {
+	if !l.showTabs() || y != 1 {
+		return 0, false
+	}
+	for _, span := range l.tabSpans {
+		if x >= span.start && x <= span.end {
+			return span.buffer, true
+		}
+	}
+	return 0, false
+}
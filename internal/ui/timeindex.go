@@ -0,0 +1,223 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"tilo/internal/timeparse"
+)
+
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (r TimeRange) active() bool {
+	return !r.From.IsZero() || !r.To.IsZero()
+}
+
+func (r TimeRange) contains(t time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+	if !r.From.IsZero() && t.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && t.After(r.To) {
+		return false
+	}
+	return true
+}
+
+func (r TimeRange) String() string {
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return format(r.From) + ".." + format(r.To)
+}
+
+// lineTime returns the cached timestamp of Lines[idx]. Lines without a
+// timestamp inherit the time of the line before them; lines before the first
+// timestamp have a zero time.
+func (v *Viewer) lineTime(idx int) time.Time {
+	if idx < 0 || idx >= len(v.Lines) {
+		return time.Time{}
+	}
+	if v.timeParser == nil {
+		v.timeParser = timeparse.NewParser(v.TimeLayouts)
+	}
+	for i := len(v.times); i <= idx; i++ {
+		t, ok := v.timeParser.Parse(v.Lines[i])
+		if !ok && i > 0 {
+			t = v.times[i-1]
+		}
+		v.times = append(v.times, t)
+		v.ownTimes = append(v.ownTimes, ok)
+	}
+	return v.times[idx]
+}
+
+// hasOwnTime reports whether Lines[idx] carries a timestamp itself rather
+// than inheriting one.
+func (v *Viewer) hasOwnTime(idx int) bool {
+	if idx < 0 || idx >= len(v.Lines) {
+		return false
+	}
+	v.lineTime(idx)
+	return v.ownTimes[idx]
+}
+
+func (v *Viewer) firstTime() time.Time {
+	for i := range v.Lines {
+		if t := v.lineTime(i); !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseTimeBound accepts the formats of timeparse.ParseBound; times of day
+// are taken on the date of the first timestamped line.
+func (v *Viewer) parseTimeBound(s string) (time.Time, error) {
+	ref := v.firstTime()
+	if ref.IsZero() {
+		ref = time.Now()
+	}
+	t, ok := timeparse.ParseBound(s, ref)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid time %q", strings.TrimSpace(s))
+	}
+	return t, nil
+}
+
+func (v *Viewer) setTimeRange(arg string) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		if !v.TimeRange.active() {
+			v.Status = "usage: time <from>..<to>"
+			return
+		}
+		v.TimeRange = TimeRange{}
+		v.rebuildView()
+		v.Status = "time filter cleared"
+		return
+	}
+	fromText, toText, ok := strings.Cut(arg, "..")
+	if !ok {
+		v.Status = "usage: time <from>..<to>"
+		return
+	}
+	var r TimeRange
+	var err error
+	if strings.TrimSpace(fromText) != "" {
+		if r.From, err = v.parseTimeBound(fromText); err != nil {
+			v.Status = err.Error()
+			return
+		}
+	}
+	if strings.TrimSpace(toText) != "" {
+		if r.To, err = v.parseTimeBound(toText); err != nil {
+			v.Status = err.Error()
+			return
+		}
+		if isClockOnly(toText) && r.To.Second() == 0 && r.To.Nanosecond() == 0 {
+			// "10:05" includes everything up to the end of that minute.
+			r.To = r.To.Add(time.Minute - time.Nanosecond)
+		}
+	}
+	if !r.active() {
+		v.Status = "usage: time <from>..<to>"
+		return
+	}
+	v.TimeRange = r
+	v.rebuildView()
+	v.Status = ""
+	if v.lineCount() == 0 {
+		v.Status = "no lines in time range"
+	}
+}
+
+func isClockOnly(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.Count(s, ":") == 1 && !strings.ContainsAny(s, "-T ")
+}
+
+// gotoTime moves to the first visible line at or after the given time. It
+// binary searches the time index, so it assumes the log is in time order.
+func (v *Viewer) gotoTime(arg string) {
+	if strings.TrimSpace(arg) == "" {
+		v.Status = "usage: goto <time>"
+		return
+	}
+	t, err := v.parseTimeBound(arg)
+	if err != nil {
+		v.Status = err.Error()
+		return
+	}
+	v.gotoTimeValue(t)
+}
+
+func (v *Viewer) gotoTimeValue(t time.Time) {
+	n := v.lineCount()
+	i := sort.Search(n, func(i int) bool {
+		return !v.lineTime(v.lineIndex(i)).Before(t)
+	})
+	if i >= n {
+		v.Status = "no line at or after " + t.Format("2006-01-02 15:04:05")
+		return
+	}
+	v.jumpTo(i)
+}
+
+// timeGapAt reports whether view row i starts after a pause longer than the
+// gap threshold.
+func (v *Viewer) timeGapAt(i int) bool {
+	if i <= 0 || i >= v.lineCount() {
+		return false
+	}
+	prev := v.lineTime(v.lineIndex(i - 1))
+	cur := v.lineTime(v.lineIndex(i))
+	if prev.IsZero() || cur.IsZero() {
+		return false
+	}
+	return cur.Sub(prev) > v.gapThreshold()
+}
+
+func (v *Viewer) gapThreshold() time.Duration {
+	if v.GapThreshold <= 0 {
+		return 5 * time.Second
+	}
+	return v.GapThreshold
+}
+
+func (v *Viewer) nextTimeGap(dir int) {
+	for i := v.Cursor + dir; i >= 0 && i < v.lineCount(); i += dir {
+		if v.timeGapAt(i) {
+			prev := v.lineTime(v.lineIndex(i - 1))
+			gap := v.lineTime(v.lineIndex(i)).Sub(prev)
+			v.jumpTo(i)
+			v.Status = "gap of " + gap.String()
+			return
+		}
+	}
+	v.Status = "no time gap over " + v.gapThreshold().String()
+}
+
+func (v *Viewer) setGapThreshold(arg string) {
+	if strings.TrimSpace(arg) == "" {
+		v.Status = "gap threshold " + v.gapThreshold().String()
+		return
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(arg))
+	if err != nil || d <= 0 {
+		v.Status = "usage: gap <duration>, e.g. gap 5s"
+		return
+	}
+	v.GapThreshold = d
+	v.Status = "gap threshold " + d.String()
+}
@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// openKeyboard returns the terminal to read keys from: stdin, or /dev/tty
+// when stdin is a pipe carrying the log, as in less.
+func openKeyboard() (*os.File, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return os.Stdin, nil
+	}
+	// Opened with a plain syscall so the file stays out of the runtime
+	// poller and reads return EAGAIN once it is switched to nonblocking.
+	fd, err := syscall.Open("/dev/tty", syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "/dev/tty"), nil
+}
+
+// HasKeyboard reports whether keys can be read from a terminal, either on
+// stdin or on /dev/tty.
+func HasKeyboard() bool {
+	tty, err := openKeyboard()
+	if err != nil {
+		return false
+	}
+	if tty != os.Stdin {
+		_ = tty.Close()
+	}
+	return true
+}
+
+// bgQueryTimeout bounds the wait for the terminal to answer QueryBackground.
+const bgQueryTimeout = 300 * time.Millisecond
+
+// QueryBackground asks the terminal for its background color (OSC 11) and
+// reports whether it is light. ok is false when the terminal does not say.
+// A device attributes request (DA1) is sent behind the query: terminals
+// answer it in order, so once its reply is in no late OSC 11 reply can turn
+// up as keys.
+func QueryBackground() (isLight bool, ok bool) {
+	tty, err := openKeyboard()
+	if err != nil {
+		return false, false
+	}
+	if tty != os.Stdin {
+		defer tty.Close()
+	}
+	fd := int(tty.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return false, false
+	}
+	defer term.Restore(fd, state)
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		return false, false
+	}
+	defer func() {
+		_ = syscall.SetNonblock(fd, false)
+	}()
+	if _, err := os.Stdout.WriteString("\x1b]11;?\x1b\\\x1b[c"); err != nil {
+		return false, false
+	}
+	var reply []byte
+	buf := make([]byte, 256)
+	deadline := time.Now().Add(bgQueryTimeout)
+	for time.Now().Before(deadline) {
+		n, err := syscall.Read(fd, buf)
+		if n > 0 {
+			reply = append(reply, buf[:n]...)
+			if daReplyRe.Match(reply) {
+				break
+			}
+			continue
+		}
+		if err != nil && err != syscall.EAGAIN && err != syscall.EWOULDBLOCK {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	m := bgReplyRe.FindSubmatch(reply)
+	if m == nil {
+		return false, false
+	}
+	r, g, b := channel(m[1]), channel(m[2]), channel(m[3])
+	return 0.299*r+0.587*g+0.114*b > 0.5, true
+}
+
+var (
+	bgReplyRe = regexp.MustCompile(`\x1b\]11;rgba?:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})`)
+	daReplyRe = regexp.MustCompile(`\x1b\[\?[\d;]*c`)
+)
+
+// channel scales a color channel of an OSC 11 reply, given with one to
+// four hex digits, to 0..1.
+func channel(hex []byte) float64 {
+	v, _ := strconv.ParseUint(string(hex), 16, 16)
+	return float64(v) / float64(uint64(1)<<(4*len(hex))-1)
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -15,6 +18,10 @@ import (
 	"golang.org/x/term"
 
 	"tilo/internal/color"
+	"tilo/internal/geoip"
+	"tilo/internal/history"
+	"tilo/internal/level"
+	"tilo/internal/timeparse"
 )
 
 const (
@@ -30,30 +37,113 @@ const (
 	cursorReset = "\x1b[0 q"
 	enterAlt    = "\x1b[?1049h"
 	exitAlt     = "\x1b[?1049l"
+	clearScreen = "\x1b[2J"
 )
 
 type Viewer struct {
-	Lines       []string
-	Rules       []color.Rule
-	Plain       bool
-	Cursor      int
-	CursorCol   int
-	GoalCol     int
-	Top         int
-	TopSub      int
-	Query       string
-	Matches     []int
-	MatchIndex  int
-	SelectStart *Position
-	SelectMode  SelectionMode
-	Status      string
-	StatusAtTop bool
-	LineNumbers bool
-	Wrap        bool
-	HOffset     int
-	Follow      bool
-	FollowAuto  bool
-	InPrompt    bool
+	Name           string
+	buffers        *bufferList
+	Lines          []string
+	LineBase       int
+	SourceStatus   string
+	MaxLines       int
+	MaxMemory      int64
+	memory         int64
+	ANSI           bool
+	ansiSpans      map[int][]color.ANSISpan
+	View           []int
+	Filters        []Filter
+	FilterContext  int
+	contextLeft    int
+	MinLevel       level.Level
+	levels         []level.Level
+	levelDetector  *level.Detector
+	levelTotals    [level.Fatal + 1]int
+	levelsCounted  int
+	viewLevels     [level.Fatal + 1]int
+	viewCounted    []int
+	TimeRange      TimeRange
+	TimeLayouts    []string
+	GapThreshold   time.Duration
+	ShowDelta      bool
+	ShowLevels     bool
+	ShowHTTP       bool
+	httpStats      *httpStats
+	TraceID        string
+	TableView      bool
+	Columns        []TableColumn
+	RecordMode     bool
+	RecordStart    *regexp.Regexp
+	recordStarts   []bool
+	Folded         map[int]int
+	Expanded       map[int][]string
+	Dedupe         bool
+	Repeats        map[int]int
+	DimDupes       bool
+	ShowChanges    bool
+	Rainbow        rune
+	rows           []screenRow
+	rowsTop        int
+	statusRow      int
+	screenWidth    int
+	mouseDown      *Position
+	dupes          []bool
+	dupeSeen       map[string]struct{}
+	SortKey        SortKey
+	sortBase       []int
+	SortReverse    bool
+	GeoIP          *geoip.DB
+	hostnames      map[string]string
+	async          chan func()
+	rates          []rateBucket
+	Watches        []Watch
+	flashUntil     time.Time
+	hooks          chan hookRun
+	ShowSpikes     bool
+	spikes         []posRange
+	spikesScanned  int
+	errorCounts    []int
+	foldRanges     []posRange
+	times          []time.Time
+	ownTimes       []bool
+	timeParser     *timeparse.Parser
+	Rules          []color.Rule
+	Plain          bool
+	Cursor         int
+	CursorCol      int
+	GoalCol        int
+	Top            int
+	TopSub         int
+	Query          string
+	QueryRe        *regexp.Regexp
+	Regex          bool
+	CaseMode       CaseMode
+	Matches        []Position
+	MatchIndex     int
+	SelectStart    *Position
+	SelectMode     SelectionMode
+	Status         string
+	StatusAtTop    bool
+	LineNumbers    bool
+	Wrap           bool
+	HOffset        int
+	Follow         bool
+	FollowAuto     bool
+	InPrompt       bool
+	History        *history.History
+	Highlights     []Highlight
+	Count          int
+	ScrollStep     int
+	lastFind       *charFind
+	Pending        string
+	pendingCount   int
+	pendingAt      time.Time
+	marks          map[byte]Position
+	quickfix       quickfix
+	pane           rect
+	buffer         int
+	inactive       bool
+	CommandHistory *history.History
 }
 
 type Position struct {
@@ -70,85 +160,257 @@ const (
 	SelectBlock
 )
 
+type Options struct {
+	Plain       bool
+	StatusAtTop bool
+	LineNumbers bool
+	Follow      bool
+	CaseMode    CaseMode
+	History     *history.History
+	Filter      *regexp.Regexp
+	Exclude     *regexp.Regexp
+	MinLevel    level.Level
+	TimeLayouts []string
+	TimeGap     time.Duration
+	RecordMode  bool
+	RecordStart *regexp.Regexp
+	Dedupe      bool
+	GeoIP       *geoip.DB
+	Watches     []Watch
+	AtEnd       bool
+	MaxLines    int
+	MaxMemory   int64
+	ANSI        bool
+	Mouse       bool
+	// Tabs shows a bar listing the buffers when there are several.
+	Tabs bool
+	// Scroll is how far Ctrl-D and Ctrl-U move; 0 means half a screen.
+	Scroll int
+	// Reload reads the config again and returns the rules for each of the
+	// named buffers; ConfigChanged reports when the config file changed.
+	Reload        func(names []string) ([][]color.Rule, error)
+	ConfigChanged <-chan struct{}
+}
+
 type segment struct {
 	start int
 	end   int
 }
 
-func Run(lines []string, rules []color.Rule, plain bool, statusAtTop bool, lineNumbers bool, follow bool, followCh <-chan []string) error {
-	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
-		return errors.New("interactive mode requires a terminal")
+// newViewer sets up the viewer for one buffer with the startup options.
+func newViewer(buf Buffer, rules []color.Rule, opts Options, async chan func()) *Viewer {
+	if buf.Rules != nil {
+		rules = buf.Rules
 	}
-
 	viewer := &Viewer{
-		Lines:       lines,
-		Rules:       rules,
-		Plain:       plain,
-		StatusAtTop: statusAtTop,
-		LineNumbers: lineNumbers,
-		Follow:      follow,
-		FollowAuto:  follow,
-	}
+		Name:         buf.Name,
+		Lines:        buf.Lines,
+		LineBase:     buf.LineBase,
+		Rules:        rules,
+		Plain:        opts.Plain,
+		StatusAtTop:  opts.StatusAtTop,
+		LineNumbers:  opts.LineNumbers,
+		Follow:       opts.Follow,
+		FollowAuto:   opts.Follow,
+		CaseMode:     opts.CaseMode,
+		History:      opts.History,
+		TimeLayouts:  opts.TimeLayouts,
+		GapThreshold: opts.TimeGap,
+		RecordMode:   opts.RecordMode,
+		RecordStart:  opts.RecordStart,
+		GeoIP:        opts.GeoIP,
+		Watches:      opts.Watches,
+		MaxLines:     opts.MaxLines,
+		MaxMemory:    opts.MaxMemory,
+		ANSI:         opts.ANSI,
+		ScrollStep:   opts.Scroll,
+		async:        async,
+	}
+	viewer.parseANSI(0)
+	if viewer.MaxMemory > 0 {
+		for _, line := range viewer.Lines {
+			viewer.memory += int64(len(line) + lineOverhead)
+		}
+	}
+	if opts.MinLevel != level.None {
+		viewer.setMinLevel(opts.MinLevel)
+		viewer.Status = ""
+	}
+	if opts.Filter != nil {
+		viewer.addFilter(Filter{Pattern: opts.Filter.String(), Regex: opts.Filter})
+	}
+	if opts.Exclude != nil {
+		viewer.addFilter(Filter{Pattern: opts.Exclude.String(), Regex: opts.Exclude, Invert: true})
+	}
+	if opts.Dedupe {
+		viewer.Dedupe = true
+		viewer.rebuildView()
+	}
+	if opts.AtEnd {
+		viewer.cursorBottom()
+	}
+	return viewer
+}
 
-	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+func Run(buffers []Buffer, rules []color.Rule, opts Options) error {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("interactive mode requires a terminal")
+	}
+	keyboard, err := openKeyboard()
+	if err != nil {
+		return fmt.Errorf("interactive mode requires a terminal: %w", err)
+	}
+	if keyboard != os.Stdin {
+		defer keyboard.Close()
+	}
+	if len(buffers) == 0 {
+		return errors.New("no input")
+	}
+
+	async := make(chan func(), 16)
+	if opts.History == nil {
+		opts.History = history.New()
+	}
+	list := &bufferList{reload: opts.Reload, mouse: opts.Mouse, tabs: opts.Tabs, opts: opts, async: async}
+	commandHistory := history.New()
+	for i, buf := range buffers {
+		viewer := newViewer(buf, rules, opts, async)
+		viewer.buffer = i
+		viewer.CommandHistory = commandHistory
+		viewer.buffers = list
+		list.viewers = append(list.viewers, viewer)
+	}
+	list.windows = []*Viewer{list.viewers[0]}
+	followCh := followBuffers(buffers)
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	defer signal.Stop(resized)
+	mice := &mouseReader{r: keyboard}
+	fd := int(keyboard.Fd())
+	state, err := term.MakeRaw(fd)
 	if err != nil {
 		return err
 	}
-	defer term.Restore(int(os.Stdin.Fd()), state)
-	fd := int(os.Stdin.Fd())
-	nonblock := follow || followCh != nil
-	if nonblock {
-		if err := syscall.SetNonblock(fd, true); err != nil {
-			return err
-		}
-		defer func() {
-			_ = syscall.SetNonblock(fd, false)
-		}()
+	defer term.Restore(fd, state)
+	// Input is polled so that followed lines and background results (such
+	// as DNS lookups) can redraw the screen while no key is pressed.
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		return err
 	}
+	defer func() {
+		_ = syscall.SetNonblock(fd, false)
+	}()
+	// Overlays and prompts read keys themselves; the mouse is off while
+	// they are open and clicks made meanwhile are dropped.
+	mouse := false
 	setNonblock := func(enable bool) {
-		if nonblock {
-			_ = syscall.SetNonblock(fd, enable)
+		_ = syscall.SetNonblock(fd, enable)
+		if enable {
+			mice.drop()
+		}
+		if on := enable && list.mouse; on != mouse {
+			setMouse(on)
+			mouse = on
 		}
 	}
 
 	fmt.Fprint(os.Stdout, enterAlt)
 	fmt.Fprint(os.Stdout, showCursor)
 	fmt.Fprint(os.Stdout, cursorBlock)
+	setNonblock(true)
 	defer func() {
+		if mouse {
+			setMouse(false)
+		}
 		fmt.Fprint(os.Stdout, cursorReset)
 		fmt.Fprint(os.Stdout, resetStyle)
 		fmt.Fprint(os.Stdout, exitAlt)
 	}()
 
-	reader := bufio.NewReader(os.Stdin)
+	reader := bufio.NewReader(mice)
 	dirty := true
+	var lastDraw time.Time
 	for {
+		viewer := list.active()
+		// Redraw at least every second while following so the rate stays
+		// current when no lines arrive.
+		if viewer.Follow && time.Since(lastDraw) >= time.Second {
+			dirty = true
+		}
 		if dirty {
-			viewer.draw()
+			list.draw()
 			dirty = false
+			lastDraw = time.Now()
 		}
 		b, err := reader.ReadByte()
 		if err != nil {
-			if nonblock && (errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK)) {
-				if followCh != nil {
-					select {
-					case batch, ok := <-followCh:
-						if ok {
-							viewer.appendLines(batch)
-							dirty = true
-						} else {
-							followCh = nil
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK) {
+				if ev, ok := mice.next(); ok {
+					if i, ok := list.tabAt(ev.x, ev.y); ok && !ev.release && ev.button == mouseLeft {
+						viewer.switchBuffer(i)
+						dirty = true
+						continue
+					}
+					// A click gives the keys to the window under it.
+					if w, ok := list.windowAt(ev.x, ev.y); ok && !ev.release && ev.button&mouseMotion == 0 && w != list.focus {
+						list.focusWindow(w)
+						viewer = list.active()
+					}
+					viewer.handleMouse(ev)
+					dirty = true
+					continue
+				}
+				if viewer.expireChord() {
+					dirty = true
+					continue
+				}
+				select {
+				case batch, ok := <-followCh:
+					if ok && batch.note != nil {
+						for _, v := range list.following(batch.buffer) {
+							v.SourceStatus = *batch.note
+						}
+					} else if ok {
+						for _, v := range list.following(batch.buffer) {
+							v.appendLines(batch.lines)
 						}
-					default:
-						time.Sleep(30 * time.Millisecond)
+					} else {
+						followCh = nil
 					}
-				} else {
+					dirty = true
+				case apply := <-async:
+					apply()
+					dirty = true
+				case <-resized:
+					viewer.resize()
+					dirty = true
+				case <-opts.ConfigChanged:
+					viewer.Status = list.reloadRules()
+					dirty = true
+				default:
 					time.Sleep(30 * time.Millisecond)
 				}
 				continue
 			}
 			return err
 		}
+		if viewer.Pending != "" {
+			dirty = true
+			if viewer.continueChord(b) {
+				continue
+			}
+		}
+		if (b >= '1' && b <= '9') || (b == '0' && viewer.Count > 0) {
+			viewer.Count = viewer.Count*10 + int(b-'0')
+			dirty = true
+			continue
+		}
+		count := viewer.Count
+		viewer.Count = 0
+		if viewer.startChord(b, count) {
+			dirty = true
+			continue
+		}
 		switch b {
 		case '\r', '\n':
 			if viewer.Follow {
@@ -157,15 +419,23 @@ func Run(lines []string, rules []color.Rule, plain bool, statusAtTop bool, lineN
 		case 'q':
 			return nil
 		case 'j':
-			viewer.moveCursor(1)
+			viewer.moveCursor(max(count, 1))
 		case 'k':
-			viewer.moveCursor(-1)
+			viewer.moveCursor(-max(count, 1))
 		case 'h':
 			viewer.moveCursorCol(-1)
 		case 'l':
 			viewer.moveCursorCol(1)
-		case 'F':
+		case 'a':
 			viewer.FollowAuto = true
+		case 'f', 'F', 't', 'T':
+			setNonblock(false)
+			viewer.findCharKey(reader, b == 'f' || b == 't', b == 't' || b == 'T', count)
+			setNonblock(true)
+		case ';':
+			viewer.repeatFind(false, count)
+		case ',':
+			viewer.repeatFind(true, count)
 		case '0':
 			viewer.moveLineStart()
 		case 'I':
@@ -182,24 +452,95 @@ func Run(lines []string, rules []color.Rule, plain bool, statusAtTop bool, lineN
 			viewer.moveWordEnd()
 		case 'W':
 			viewer.toggleWrap()
-		case 'g':
-			viewer.cursorTop()
 		case 'G':
-			viewer.cursorBottom()
+			if count > 0 {
+				viewer.gotoLine(count)
+			} else {
+				viewer.rememberJump()
+				viewer.cursorBottom()
+			}
+		case '%':
+			if count > 0 {
+				viewer.gotoPercent(count)
+			}
+		case '&':
+			setNonblock(false)
+			viewer.filterPrompt(reader)
+			setNonblock(true)
+		case '>':
+			viewer.setMinLevel(viewer.MinLevel + 1)
+		case '<':
+			viewer.setMinLevel(viewer.MinLevel - 1)
+		case '}':
+			viewer.nextTimeGap(1)
+		case '{':
+			viewer.nextTimeGap(-1)
+		case 'd':
+			viewer.ShowDelta = !viewer.ShowDelta
+		case 'H':
+			setNonblock(false)
+			viewer.showHistogram(reader)
+			setNonblock(true)
+		case 'S':
+			viewer.ShowLevels = !viewer.ShowLevels
+		case '*':
+			viewer.correlateID()
+		case ']':
+			viewer.nextIDLine(1)
+		case '[':
+			viewer.nextIDLine(-1)
+		case ')':
+			viewer.nextRecord(1)
+		case '(':
+			viewer.nextRecord(-1)
+		case 'E':
+			viewer.nextSpike(1)
+		case 'B':
+			viewer.nextSpike(-1)
+		case 'R':
+			viewer.resolveIP()
+		case '@':
+			viewer.decodeEpoch()
+		case 'i':
+			setNonblock(false)
+			viewer.inspect(reader)
+			setNonblock(true)
+		case 'D':
+			viewer.toggleDedupe()
+		case 'C':
+			viewer.toggleTable()
+		case 'J':
+			viewer.toggleJSON()
+		case 'Y':
+			viewer.copyRecord()
+		case 'u':
+			viewer.popFilter()
+		case 'U':
+			viewer.clearFilters()
+		case ':':
+			setNonblock(false)
+			viewer.commandPrompt(reader)
+			setNonblock(true)
 		case '/':
 			setNonblock(false)
-			query, canceled := viewer.prompt(reader, "/")
+			viewer.search(reader, "/", 1)
 			setNonblock(true)
-			if !canceled {
-				viewer.setQuery(query, 1)
-			}
 		case '?':
 			setNonblock(false)
-			query, canceled := viewer.prompt(reader, "?")
+			viewer.search(reader, "?", -1)
+			setNonblock(true)
+		case 'r':
+			viewer.toggleRegex()
+		case 'c':
+			viewer.cycleCaseMode()
+		case '+':
+			setNonblock(false)
+			viewer.addHighlight(reader, viewer.History)
+			setNonblock(true)
+		case '=':
+			setNonblock(false)
+			viewer.manageHighlights(reader)
 			setNonblock(true)
-			if !canceled {
-				viewer.setQuery(query, -1)
-			}
 		case 'n':
 			viewer.nextMatch(1)
 		case 'N':
@@ -209,39 +550,65 @@ func Run(lines []string, rules []color.Rule, plain bool, statusAtTop bool, lineN
 		case 'V':
 			viewer.toggleSelect(SelectLine)
 		case 'y':
+			// Reached only with a selection; otherwise y starts yy.
 			viewer.copySelection()
+		case 'Q':
+			setNonblock(false)
+			viewer.focusQuickfix(reader)
+			setNonblock(true)
 		case 'L':
 			viewer.LineNumbers = !viewer.LineNumbers
 		case 0x1b:
-			if viewer.SelectMode != SelectNone {
+			if readF1(reader) {
+				setNonblock(false)
+				viewer.showHelp(reader)
+				setNonblock(true)
+			} else if viewer.SelectMode != SelectNone {
 				viewer.clearSelection()
 			} else {
 				viewer.handleEscape(reader)
 			}
 		case 0x16:
 			viewer.toggleSelect(SelectBlock)
+		case 0x04:
+			viewer.halfPage(1, count)
+		case 0x15:
+			viewer.halfPage(-1, count)
+		case 0x05:
+			viewer.scroll(max(count, 1))
+		case 0x19:
+			viewer.scroll(-max(count, 1))
+		case 0x06:
+			viewer.page(max(count, 1))
+		case 0x02:
+			viewer.page(-max(count, 1))
+		case 0x0e:
+			viewer.nextBuffer(1)
+		case 0x10:
+			viewer.nextBuffer(-1)
+		case '\t':
+			setNonblock(false)
+			viewer.pickBuffer(reader)
+			setNonblock(true)
 		}
 		dirty = true
 	}
 }
 
 func (v *Viewer) draw() {
-	width, height, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		width, height = 80, 24
-	}
+	width, height := v.size()
 	fmt.Fprint(os.Stdout, hideCursor)
-	contentHeight := height - 1
+	contentHeight := height - 1 - v.headerRows() - v.panelRows()
 	if contentHeight < 1 {
 		contentHeight = 1
 	}
 
 	contentWidth := v.contentWidth(width)
 	if v.Follow && v.FollowAuto {
-		if len(v.Lines) == 0 {
+		if v.lineCount() == 0 {
 			v.Cursor = 0
 		} else {
-			v.Cursor = len(v.Lines) - 1
+			v.Cursor = v.lineCount() - 1
 		}
 		v.CursorCol = 0
 		v.GoalCol = 0
@@ -249,37 +616,81 @@ func (v *Viewer) draw() {
 	v.clampCursor()
 	v.ensureVisible(contentHeight, contentWidth)
 
-	fmt.Fprint(os.Stdout, moveHome)
+	// Rows are placed one by one, so that a window of a split draws only
+	// its own part of the screen.
+	screenLine := 0
+	emit := func(text string) {
+		fmt.Fprintf(os.Stdout, "\x1b[%d;%dH%s", v.pane.y+screenLine+1, v.pane.x+1, text)
+		screenLine++
+	}
+	v.screenWidth = width
+	v.rowsTop = v.pane.y + v.headerRows()
+	v.statusRow = v.pane.y + height - 1
 	if v.StatusAtTop {
-		fmt.Fprint(os.Stdout, v.renderStatusLine(width))
-		fmt.Fprint(os.Stdout, "\r\n")
+		v.rowsTop++
+		v.statusRow = v.pane.y
+	}
+	v.rows = v.rows[:0]
+	if v.StatusAtTop {
+		emit(v.renderStatusLine(width))
+	}
+	if v.TableView {
+		emit(v.tableHeader(width))
 	}
 	row := 0
 	lineIdx := v.Top
 	sub := v.TopSub
-	for row < contentHeight && lineIdx < len(v.Lines) {
-		line := v.Lines[lineIdx]
+	for row < contentHeight && lineIdx < v.lineCount() {
+		line := v.line(lineIdx)
 		segments := v.wrapSegments(line, contentWidth)
-		if sub >= len(segments) {
+		gap := v.gapRows(lineIdx)
+		if sub >= len(segments)+gap+v.expandRows(lineIdx) {
 			lineIdx++
 			sub = 0
 			continue
 		}
-		seg := segments[sub]
-		display := v.renderSegment(lineIdx, seg.start, seg.end, contentWidth)
-		fmt.Fprint(os.Stdout, padRight(truncateANSI(display, width), width))
-		fmt.Fprint(os.Stdout, "\r\n")
+		if sub < gap {
+			v.rows = append(v.rows, screenRow{line: -1})
+			emit(padRight(color.Wrap("--", "gray", ""), width))
+			row++
+			sub++
+			continue
+		}
+		if sub >= len(segments)+gap {
+			v.rows = append(v.rows, screenRow{line: lineIdx})
+			display := v.renderExpandRow(lineIdx, sub-gap-len(segments))
+			emit(padRight(truncateANSI(display, width), width))
+			row++
+			sub++
+			continue
+		}
+		seg := segments[sub-gap]
+		shown := screenRow{line: lineIdx, col: v.HOffset}
+		if v.Wrap {
+			shown.col = seg.start
+		}
+		v.rows = append(v.rows, shown)
+		var display string
+		if v.TableView {
+			display = v.renderTableRow(lineIdx)
+		} else {
+			display = v.renderSegment(lineIdx, seg.start, seg.end, contentWidth)
+		}
+		emit(padRight(truncateANSI(display, width), width))
 		row++
 		sub++
 	}
 	for row < contentHeight {
-		fmt.Fprint(os.Stdout, strings.Repeat(" ", width))
-		fmt.Fprint(os.Stdout, "\r\n")
+		v.rows = append(v.rows, screenRow{line: -1})
+		emit(strings.Repeat(" ", width))
 		row++
 	}
+	for _, panel := range v.panelLines() {
+		emit(padRight(truncateANSI(panel, width), width))
+	}
 
 	if !v.StatusAtTop {
-		fmt.Fprint(os.Stdout, v.renderStatusLine(width))
+		emit(v.renderStatusLine(width))
 	}
 
 	v.moveCursorToLine()
@@ -291,6 +702,12 @@ func (v *Viewer) statusLine(width int) string {
 		return ""
 	}
 	var parts []string
+	if buffer := v.bufferStatus(); buffer != "" {
+		parts = append(parts, buffer)
+	}
+	if v.SourceStatus != "" {
+		parts = append(parts, v.SourceStatus)
+	}
 	if v.Query != "" && len(v.Matches) > 0 {
 		parts = append(parts, fmt.Sprintf("match %d/%d", v.MatchIndex+1, len(v.Matches)))
 	}
@@ -304,18 +721,43 @@ func (v *Viewer) statusLine(width int) string {
 			parts = append(parts, "visual-block")
 		}
 	}
+	if filter := v.filterStatus(); filter != "" {
+		parts = append(parts, filter)
+	}
+	if sorted := v.sortStatus(); sorted != "" {
+		parts = append(parts, sorted)
+	}
+	if rate := v.rateStatus(); rate != "" {
+		parts = append(parts, rate)
+	}
+	if v.Regex {
+		parts = append(parts, "regex")
+	}
+	parts = append(parts, v.CaseMode.String())
 	if v.Query != "" {
 		parts = append(parts, "/"+v.Query)
 	}
 	if v.Status != "" {
 		parts = append(parts, v.Status)
+	} else if geo := v.geoStatus(); geo != "" {
+		parts = append(parts, geo)
+	}
+	if v.Count > 0 {
+		parts = append(parts, strconv.Itoa(v.Count))
 	}
-	help := "[q quit] [/? search] [n/N next] [h/j/k/l move] [w/b/e word] [0/$/I/A line] [g/G top/bot] [v/V/^V select] [y yank] [L line#] [W wrap] [F follow]"
+	if v.Pending != "" {
+		pending := v.Pending
+		if v.pendingCount > 0 {
+			pending = strconv.Itoa(v.pendingCount) + pending
+		}
+		parts = append(parts, pending)
+	}
+	help := "[q quit] [/? search] [: command] [F1 help]"
 	left := help
 	if len(parts) > 0 {
 		left = strings.Join(parts, " | ") + " | " + help
 	}
-	indicator := fmt.Sprintf("%d/%d", v.Cursor+1, len(v.Lines))
+	indicator := v.positionIndicator()
 	if left == "" {
 		return padLeft(indicator, width)
 	}
@@ -327,6 +769,16 @@ func (v *Viewer) statusLine(width int) string {
 	return left + indicator
 }
 
+// positionIndicator shows the cursor line at the right end of the status
+// bar.
+func (v *Viewer) positionIndicator() string {
+	current := v.Cursor + 1
+	if v.lineCount() == 0 {
+		current = 0
+	}
+	return fmt.Sprintf("%d/%d", current, v.lineCount())
+}
+
 func (v *Viewer) renderStatusLine(width int) string {
 	// Clear line, then paint full-width status bar background.
 	text := v.statusLine(width)
@@ -336,15 +788,19 @@ func (v *Viewer) renderStatusLine(width int) string {
 	} else if visible > width {
 		text = truncateANSI(text, width)
 	}
-	return statusBG + statusFG + text + resetStyle
+	bg := statusBG
+	if v.inactive {
+		bg = inactiveBG
+	}
+	if v.flashing() {
+		bg = flashBG
+	}
+	return bg + statusFG + text + resetStyle
 }
 
 func (v *Viewer) moveCursorToLine() {
-	width, height, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		width, height = 80, 24
-	}
-	contentHeight := height - 1
+	width, height := v.size()
+	contentHeight := height - 1 - v.headerRows() - v.panelRows()
 	if contentHeight < 1 {
 		contentHeight = 1
 	}
@@ -359,9 +815,11 @@ func (v *Viewer) moveCursorToLine() {
 	if v.StatusAtTop {
 		row++
 	}
-	row++
+	row += 1 + v.headerRows()
 	displayCol := v.CursorCol
-	if v.Wrap && contentWidth > 0 {
+	if v.TableView {
+		displayCol = 0
+	} else if v.Wrap && contentWidth > 0 {
 		displayCol = v.CursorCol % contentWidth
 	} else {
 		displayCol = v.CursorCol - v.HOffset
@@ -372,48 +830,31 @@ func (v *Viewer) moveCursorToLine() {
 	if contentWidth > 0 && displayCol >= contentWidth {
 		displayCol = contentWidth - 1
 	}
-	col := 1 + displayCol
-	if v.LineNumbers {
-		col = v.lineNumberWidth() + 2 + displayCol
-	}
+	col := 1 + v.gutterWidth() + displayCol
 	if col < 1 {
 		col = 1
 	}
 	if col > width {
 		col = width
 	}
-	fmt.Fprintf(os.Stdout, "\x1b[%d;%dH", row, col)
+	fmt.Fprintf(os.Stdout, "\x1b[%d;%dH", v.pane.y+row, v.pane.x+col)
 }
 
 func (v *Viewer) lineNumberWidth() int {
-	if len(v.Lines) == 0 {
-		return 1
-	}
-	return len(fmt.Sprintf("%d", len(v.Lines)))
+	return len(strconv.Itoa(v.lineNumber(max(len(v.Lines)-1, 0))))
 }
 
-func (v *Viewer) lineRuneCount(idx int) int {
-	if idx < 0 || idx >= len(v.Lines) {
-		return 0
-	}
-	return utf8.RuneCountInString(v.Lines[idx])
+// lineNumber is the number of Lines[idx] in the input, which is offset by
+// LineBase when the input was loaded from somewhere in the middle.
+func (v *Viewer) lineNumber(idx int) int {
+	return v.LineBase + idx + 1
 }
 
-func (v *Viewer) matchColForLine(lineIdx int) int {
-	if lineIdx < 0 || lineIdx >= len(v.Lines) {
-		return 0
-	}
-	if v.Query == "" {
-		return 0
-	}
-	line := v.Lines[lineIdx]
-	lowerLine := strings.ToLower(line)
-	lowerQuery := strings.ToLower(v.Query)
-	idx := strings.Index(lowerLine, lowerQuery)
-	if idx == -1 {
+func (v *Viewer) lineRuneCount(idx int) int {
+	if idx < 0 || idx >= v.lineCount() {
 		return 0
 	}
-	return utf8.RuneCountInString(line[:idx])
+	return utf8.RuneCountInString(v.line(idx))
 }
 
 func isWordRune(r rune) bool {
@@ -421,10 +862,7 @@ func isWordRune(r rune) bool {
 }
 
 func (v *Viewer) contentWidth(totalWidth int) int {
-	width := totalWidth
-	if v.LineNumbers {
-		width -= v.lineNumberWidth() + 1
-	}
+	width := totalWidth - v.gutterWidth()
 	if width < 1 {
 		width = 1
 	}
@@ -432,10 +870,7 @@ func (v *Viewer) contentWidth(totalWidth int) int {
 }
 
 func (v *Viewer) contentWidthFromHeight() int {
-	width, _, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		width = 80
-	}
+	width, _ := v.size()
 	return v.contentWidth(width)
 }
 
@@ -444,7 +879,7 @@ func (v *Viewer) wrapSegments(line string, width int) []segment {
 		width = 1
 	}
 	runes := []rune(line)
-	if !v.Wrap {
+	if !v.Wrap || v.TableView {
 		return []segment{{start: 0, end: len(runes)}}
 	}
 	if len(runes) == 0 {
@@ -465,29 +900,31 @@ func (v *Viewer) lineSegmentCount(idx int, width int) int {
 	if width < 1 {
 		width = 1
 	}
-	if !v.Wrap {
-		return 1
+	extra := v.gapRows(idx) + v.expandRows(idx)
+	if !v.Wrap || v.TableView {
+		return 1 + extra
 	}
 	count := v.lineRuneCount(idx)
 	if count == 0 {
-		return 1
+		return 1 + extra
 	}
-	return (count + width - 1) / width
+	return (count+width-1)/width + extra
 }
 
 func (v *Viewer) cursorSegmentIndex(width int) int {
+	gap := v.gapRows(v.Cursor)
 	if width < 1 {
-		return 0
+		return gap
 	}
-	if !v.Wrap {
-		return 0
+	if !v.Wrap || v.TableView {
+		return gap
 	}
-	return v.CursorCol / width
+	return v.CursorCol/width + gap
 }
 
 func (v *Viewer) globalSegIndex(line, seg, width int) int {
 	idx := 0
-	for i := 0; i < line && i < len(v.Lines); i++ {
+	for i := 0; i < line && i < v.lineCount(); i++ {
 		idx += v.lineSegmentCount(i, width)
 	}
 	return idx + seg
@@ -498,7 +935,7 @@ func (v *Viewer) fromGlobalSegIndex(idx, width int) (int, int) {
 		return 0, 0
 	}
 	line := 0
-	for line < len(v.Lines) {
+	for line < v.lineCount() {
 		count := v.lineSegmentCount(line, width)
 		if idx < count {
 			return line, idx
@@ -506,10 +943,10 @@ func (v *Viewer) fromGlobalSegIndex(idx, width int) (int, int) {
 		idx -= count
 		line++
 	}
-	if len(v.Lines) == 0 {
+	if v.lineCount() == 0 {
 		return 0, 0
 	}
-	last := len(v.Lines) - 1
+	last := v.lineCount() - 1
 	return last, v.lineSegmentCount(last, width) - 1
 }
 
@@ -520,7 +957,7 @@ func (v *Viewer) cursorRow(height int, width int) int {
 }
 
 func (v *Viewer) renderSegment(lineIdx int, segStart int, segEnd int, contentWidth int) string {
-	line := v.Lines[lineIdx]
+	line := v.line(lineIdx)
 	runes := []rune(line)
 	if segStart < 0 {
 		segStart = 0
@@ -565,42 +1002,49 @@ func (v *Viewer) renderSegment(lineIdx int, segStart int, segEnd int, contentWid
 		overlaps = append(overlaps, segment{start: segStart - start, end: segEnd - start})
 	}
 	if len(overlaps) == 0 {
-		text := v.applyColors(segmentText, lineIdx)
-		if v.LineNumbers {
-			prefix := fmt.Sprintf("%*d ", v.lineNumberWidth(), lineIdx+1)
-			return prefix + text
-		}
-		return text
+		return v.gutter(lineIdx) + v.applyColors(segmentText, lineIdx, start) + v.segmentSuffix(lineIdx, end, len(runes))
 	}
 	var out strings.Builder
 	pos := 0
 	for _, r := range overlaps {
 		if r.start > pos {
-			out.WriteString(v.applyColors(string(subRunes[pos:r.start]), lineIdx))
+			out.WriteString(v.applyColors(string(subRunes[pos:r.start]), lineIdx, start+pos))
 		}
-		highlight := v.applyColors(string(subRunes[r.start:r.end]), lineIdx)
+		highlight := v.applyColors(string(subRunes[r.start:r.end]), lineIdx, start+r.start)
 		out.WriteString(applyReverse(highlight))
 		pos = r.end
 	}
 	if pos < len(subRunes) {
-		out.WriteString(v.applyColors(string(subRunes[pos:]), lineIdx))
+		out.WriteString(v.applyColors(string(subRunes[pos:]), lineIdx, start+pos))
 	}
-	if v.LineNumbers {
-		prefix := fmt.Sprintf("%*d ", v.lineNumberWidth(), lineIdx+1)
-		return prefix + out.String()
+	return v.gutter(lineIdx) + out.String() + v.segmentSuffix(lineIdx, end, len(runes))
+}
+
+// segmentSuffix returns annotations shown after the last segment of a line.
+func (v *Viewer) segmentSuffix(lineIdx int, end int, lineLen int) string {
+	if end < lineLen {
+		return ""
 	}
-	return out.String()
+	return v.repeatMarker(lineIdx) + v.foldMarker(lineIdx)
 }
 
-func (v *Viewer) applyColors(text string, lineIdx int) string {
+func (v *Viewer) applyColors(text string, lineIdx int, startCol int) string {
 	if v.Plain {
 		return text
 	}
-	out := color.ApplyRules(text, v.Rules)
-	return color.HighlightQuery(out, v.Query)
+	spans := append(v.matchSpans(text, lineIdx, startCol), v.idSpans(text)...)
+	spans = append(spans, v.highlightSpans(text)...)
+	spans = append(spans, v.changeSpans(text, lineIdx, startCol)...)
+	spans = append(spans, v.geoSpans(text)...)
+	spans = append(spans, logfmtSpans(text)...)
+	spans = append(spans, v.rainbowSpans(text, lineIdx, startCol)...)
+	if v.DimDupes && v.isDupe(v.lineIndex(lineIdx)) {
+		return color.ApplyRulesWithSpans(text, dupeRules, spans)
+	}
+	return color.ApplyRulesLinked(text, v.Rules, spans, v.inputColors(text, lineIdx, startCol), v.links(text, lineIdx, startCol))
 }
 
-func (v *Viewer) prompt(reader *bufio.Reader, prefix string) (string, bool) {
+func (v *Viewer) prompt(reader *bufio.Reader, prefix string, hist *history.History, onChange func(string)) (string, bool) {
 	v.Status = ""
 	v.InPrompt = true
 	defer func() {
@@ -610,6 +1054,31 @@ func (v *Viewer) prompt(reader *bufio.Reader, prefix string) (string, bool) {
 	v.renderPrompt(prefix, width)
 
 	var buf []rune
+	changed := func() {
+		if onChange != nil {
+			onChange(string(buf))
+		}
+		v.renderPrompt(prefix+string(buf), width)
+	}
+	entries := hist.Entries()
+	histIdx := len(entries)
+	var draft []rune
+	recall := func(delta int) {
+		next := histIdx + delta
+		if next < 0 || next > len(entries) {
+			return
+		}
+		if histIdx == len(entries) {
+			draft = buf
+		}
+		histIdx = next
+		if histIdx == len(entries) {
+			buf = draft
+		} else {
+			buf = []rune(entries[histIdx])
+		}
+		changed()
+	}
 	for {
 		b, err := reader.ReadByte()
 		if err != nil {
@@ -619,11 +1088,25 @@ func (v *Viewer) prompt(reader *bufio.Reader, prefix string) (string, bool) {
 		case '\r', '\n':
 			return string(buf), false
 		case 0x1b:
-			return "", true
+			// A lone Esc cancels; arrow keys arrive as a buffered sequence.
+			if reader.Buffered() < 2 {
+				return "", true
+			}
+			next, _ := reader.ReadByte()
+			code, _ := reader.ReadByte()
+			if next != '[' {
+				return "", true
+			}
+			switch code {
+			case 'A':
+				recall(-1)
+			case 'B':
+				recall(1)
+			}
 		case 0x7f, 0x08:
 			if len(buf) > 0 {
 				buf = buf[:len(buf)-1]
-				v.renderPrompt(prefix+string(buf), width)
+				changed()
 			}
 		default:
 			if b < 32 {
@@ -631,7 +1114,7 @@ func (v *Viewer) prompt(reader *bufio.Reader, prefix string) (string, bool) {
 			}
 			r, _ := utf8.DecodeRune([]byte{b})
 			buf = append(buf, r)
-			v.renderPrompt(prefix+string(buf), width)
+			changed()
 		}
 	}
 }
@@ -734,15 +1217,15 @@ func (v *Viewer) moveLineEnd() {
 }
 
 func (v *Viewer) moveWordForward() {
-	if len(v.Lines) == 0 {
+	if v.lineCount() == 0 {
 		return
 	}
 	lineIdx := v.Cursor
 	col := v.CursorCol
 	for {
-		line := []rune(v.Lines[lineIdx])
+		line := []rune(v.line(lineIdx))
 		if len(line) == 0 {
-			if lineIdx+1 >= len(v.Lines) {
+			if lineIdx+1 >= v.lineCount() {
 				v.Cursor = lineIdx
 				v.CursorCol = 0
 				v.clampCursor()
@@ -757,7 +1240,7 @@ func (v *Viewer) moveWordForward() {
 			col = 0
 		}
 		if col >= len(line) {
-			if lineIdx+1 >= len(v.Lines) {
+			if lineIdx+1 >= v.lineCount() {
 				v.Cursor = lineIdx
 				v.CursorCol = len(line) - 1
 				v.clampCursor()
@@ -788,7 +1271,7 @@ func (v *Viewer) moveWordForward() {
 			v.Status = ""
 			return
 		}
-		if lineIdx+1 >= len(v.Lines) {
+		if lineIdx+1 >= v.lineCount() {
 			v.Cursor = lineIdx
 			v.CursorCol = len(line) - 1
 			v.clampCursor()
@@ -801,7 +1284,7 @@ func (v *Viewer) moveWordForward() {
 }
 
 func (v *Viewer) moveWordBackward() {
-	if len(v.Lines) == 0 {
+	if v.lineCount() == 0 {
 		return
 	}
 	lineIdx := v.Cursor
@@ -814,7 +1297,7 @@ func (v *Viewer) moveWordBackward() {
 			v.Status = ""
 			return
 		}
-		line := []rune(v.Lines[lineIdx])
+		line := []rune(v.line(lineIdx))
 		if len(line) == 0 {
 			lineIdx--
 			col = 0
@@ -830,7 +1313,7 @@ func (v *Viewer) moveWordBackward() {
 		if col == 0 {
 			lineIdx--
 			if lineIdx >= 0 {
-				prev := []rune(v.Lines[lineIdx])
+				prev := []rune(v.line(lineIdx))
 				col = len(prev) - 1
 			}
 			continue
@@ -845,7 +1328,7 @@ func (v *Viewer) moveWordBackward() {
 				v.Status = ""
 				return
 			}
-			line = []rune(v.Lines[lineIdx])
+			line = []rune(v.line(lineIdx))
 			if len(line) == 0 {
 				lineIdx--
 				col = 0
@@ -854,7 +1337,7 @@ func (v *Viewer) moveWordBackward() {
 			if col < 0 {
 				lineIdx--
 				if lineIdx >= 0 {
-					prev := []rune(v.Lines[lineIdx])
+					prev := []rune(v.line(lineIdx))
 					col = len(prev) - 1
 					continue
 				}
@@ -886,15 +1369,15 @@ func (v *Viewer) moveWordBackward() {
 }
 
 func (v *Viewer) moveWordEnd() {
-	if len(v.Lines) == 0 {
+	if v.lineCount() == 0 {
 		return
 	}
 	lineIdx := v.Cursor
 	col := v.CursorCol
 	for {
-		line := []rune(v.Lines[lineIdx])
+		line := []rune(v.line(lineIdx))
 		if len(line) == 0 {
-			if lineIdx+1 >= len(v.Lines) {
+			if lineIdx+1 >= v.lineCount() {
 				v.Cursor = lineIdx
 				v.CursorCol = 0
 				v.clampCursor()
@@ -909,7 +1392,7 @@ func (v *Viewer) moveWordEnd() {
 			col = 0
 		}
 		if col >= len(line) {
-			if lineIdx+1 >= len(v.Lines) {
+			if lineIdx+1 >= v.lineCount() {
 				v.Cursor = lineIdx
 				v.CursorCol = len(line) - 1
 				v.clampCursor()
@@ -948,7 +1431,7 @@ func (v *Viewer) moveWordEnd() {
 			v.Status = ""
 			return
 		}
-		if lineIdx+1 >= len(v.Lines) {
+		if lineIdx+1 >= v.lineCount() {
 			v.Cursor = lineIdx
 			v.CursorCol = len(line) - 1
 			v.clampCursor()
@@ -960,6 +1443,45 @@ func (v *Viewer) moveWordEnd() {
 	}
 }
 
+// moveWordEndBackward moves to the end of the previous word, like vim's ge.
+func (v *Viewer) moveWordEndBackward() {
+	if v.lineCount() == 0 {
+		return
+	}
+	lineIdx := v.Cursor
+	line := []rune(v.line(lineIdx))
+	col := min(v.CursorCol, len(line)-1)
+	for col >= 0 && isWordRune(line[col]) {
+		col--
+	}
+	for {
+		for ; col >= 0; col-- {
+			if isWordRune(line[col]) {
+				v.Cursor = lineIdx
+				v.CursorCol = col
+				v.GoalCol = col
+				v.clampCursor()
+				if v.Follow {
+					v.FollowAuto = false
+				}
+				v.Status = ""
+				return
+			}
+		}
+		if lineIdx == 0 {
+			v.Cursor = 0
+			v.CursorCol = 0
+			v.GoalCol = 0
+			v.clampCursor()
+			v.Status = ""
+			return
+		}
+		lineIdx--
+		line = []rune(v.line(lineIdx))
+		col = len(line) - 1
+	}
+}
+
 func (v *Viewer) toggleWrap() {
 	v.Wrap = !v.Wrap
 	v.HOffset = 0
@@ -978,7 +1500,7 @@ func (v *Viewer) maxHOffset() int {
 }
 
 func (v *Viewer) page(delta int) {
-	_, height, _ := term.GetSize(int(os.Stdout.Fd()))
+	_, height := v.size()
 	contentHeight := height - 2
 	if contentHeight < 1 {
 		contentHeight = 1
@@ -991,14 +1513,36 @@ func (v *Viewer) page(delta int) {
 	}
 }
 
+// halfPage scrolls the view and the cursor by ScrollStep lines, or half
+// the screen while it is 0. A count sets ScrollStep, as in vim.
+func (v *Viewer) halfPage(dir, count int) {
+	if count > 0 {
+		v.ScrollStep = count
+	}
+	height := max(len(v.rows), 1)
+	step := v.ScrollStep
+	if step <= 0 {
+		step = max(height/2, 1)
+	}
+	v.Top = min(max(v.Top+dir*step, 0), max(v.lineCount()-height, 0))
+	v.TopSub = 0
+	v.Cursor += dir * step
+	v.clampCursor()
+	v.applyGoalCol()
+	if v.Follow {
+		v.FollowAuto = false
+	}
+	v.Status = ""
+}
+
 func (v *Viewer) clampCursor() {
 	if v.Cursor < 0 {
 		v.Cursor = 0
 	}
-	if v.Cursor >= len(v.Lines) {
-		v.Cursor = len(v.Lines) - 1
+	if v.Cursor >= v.lineCount() {
+		v.Cursor = v.lineCount() - 1
 	}
-	if len(v.Lines) == 0 {
+	if v.lineCount() == 0 {
 		v.Cursor = 0
 	}
 	maxCol := v.lineRuneCount(v.Cursor)
@@ -1031,6 +1575,15 @@ func (v *Viewer) applyGoalCol() {
 	}
 }
 
+// resize clears the screen after the terminal changed size, so nothing of
+// the old layout is left where the new one draws less. Wrapped lines break
+// differently now, so the view starts at the first row of its top line;
+// the next draw scrolls back to the cursor and clamps HOffset.
+func (v *Viewer) resize() {
+	fmt.Fprint(os.Stdout, clearScreen)
+	v.TopSub = 0
+}
+
 func (v *Viewer) ensureVisible(height int, width int) {
 	if width < 1 {
 		width = 1
@@ -1059,6 +1612,17 @@ func (v *Viewer) ensureVisible(height int, width int) {
 	}
 }
 
+// placeCursorRow scrolls so that the cursor shows on the given row of the
+// content area, e.g. the middle one for zz.
+func (v *Viewer) placeCursorRow(row int) {
+	width := max(v.contentWidth(v.screenWidth), 1)
+	cursorGlobal := v.globalSegIndex(v.Cursor, v.cursorSegmentIndex(width), width)
+	v.Top, v.TopSub = v.fromGlobalSegIndex(max(cursorGlobal-row, 0), width)
+	if v.Follow {
+		v.FollowAuto = false
+	}
+}
+
 func (v *Viewer) cursorTop() {
 	v.Cursor = 0
 	v.CursorCol = 0
@@ -1070,7 +1634,7 @@ func (v *Viewer) cursorTop() {
 }
 
 func (v *Viewer) cursorBottom() {
-	if len(v.Lines) == 0 {
+	if v.lineCount() == 0 {
 		v.Cursor = 0
 		v.CursorCol = 0
 		v.GoalCol = 0
@@ -1079,7 +1643,7 @@ func (v *Viewer) cursorBottom() {
 		}
 		return
 	}
-	v.Cursor = len(v.Lines) - 1
+	v.Cursor = v.lineCount() - 1
 	v.CursorCol = 0
 	v.GoalCol = 0
 	if v.Follow {
@@ -1088,74 +1652,6 @@ func (v *Viewer) cursorBottom() {
 	v.Status = ""
 }
 
-func (v *Viewer) setQuery(query string, dir int) {
-	v.Query = strings.TrimSpace(query)
-	v.Matches = nil
-	v.MatchIndex = 0
-	if v.Query == "" {
-		return
-	}
-	lowerQuery := strings.ToLower(v.Query)
-	for i, line := range v.Lines {
-		if strings.Contains(strings.ToLower(line), lowerQuery) {
-			v.Matches = append(v.Matches, i)
-		}
-	}
-	if len(v.Matches) == 0 {
-		v.Status = "no matches"
-		return
-	}
-	v.MatchIndex = v.closestMatchIndex(dir)
-	v.Cursor = v.Matches[v.MatchIndex]
-	v.CursorCol = v.matchColForLine(v.Cursor)
-	v.GoalCol = v.CursorCol
-	if v.Follow {
-		v.FollowAuto = false
-	}
-	v.Status = ""
-}
-
-func (v *Viewer) closestMatchIndex(dir int) int {
-	if len(v.Matches) == 0 {
-		return 0
-	}
-	if dir >= 0 {
-		for i, line := range v.Matches {
-			if line >= v.Cursor {
-				return i
-			}
-		}
-		return 0
-	}
-	for i := len(v.Matches) - 1; i >= 0; i-- {
-		if v.Matches[i] <= v.Cursor {
-			return i
-		}
-	}
-	return len(v.Matches) - 1
-}
-
-func (v *Viewer) nextMatch(dir int) {
-	if len(v.Matches) == 0 {
-		v.Status = "no matches"
-		return
-	}
-	v.MatchIndex += dir
-	if v.MatchIndex < 0 {
-		v.MatchIndex = len(v.Matches) - 1
-	}
-	if v.MatchIndex >= len(v.Matches) {
-		v.MatchIndex = 0
-	}
-	v.Cursor = v.Matches[v.MatchIndex]
-	v.CursorCol = v.matchColForLine(v.Cursor)
-	v.GoalCol = v.CursorCol
-	if v.Follow {
-		v.FollowAuto = false
-	}
-	v.Status = ""
-}
-
 type posRange struct {
 	start int
 	end   int
@@ -1288,20 +1784,22 @@ func (v *Viewer) copySelection() {
 	if minLine < 0 {
 		minLine = 0
 	}
-	if maxLine >= len(v.Lines) {
-		maxLine = len(v.Lines) - 1
+	if maxLine >= v.lineCount() {
+		maxLine = v.lineCount() - 1
 	}
 	var out []string
 	switch v.SelectMode {
 	case SelectLine:
-		out = append(out, v.Lines[minLine:maxLine+1]...)
+		for i := minLine; i <= maxLine; i++ {
+			out = append(out, v.line(i))
+		}
 	case SelectBlock:
 		minCol, maxCol := start.Col, end.Col
 		if minCol > maxCol {
 			minCol, maxCol = maxCol, minCol
 		}
 		for i := minLine; i <= maxLine; i++ {
-			runes := []rune(v.Lines[i])
+			runes := []rune(v.line(i))
 			if len(runes) == 0 || minCol >= len(runes) {
 				out = append(out, "")
 				continue
@@ -1319,7 +1817,7 @@ func (v *Viewer) copySelection() {
 				out = append(out, "")
 				continue
 			}
-			runes := []rune(v.Lines[i])
+			runes := []rune(v.line(i))
 			var lineOut strings.Builder
 			for _, r := range ranges {
 				if r.start < 0 {
@@ -1348,10 +1846,21 @@ func (v *Viewer) appendLines(lines []string) {
 	if len(lines) == 0 {
 		return
 	}
-	atEnd := v.FollowAuto || v.Cursor >= len(v.Lines)-1
+	atEnd := v.FollowAuto || v.Cursor >= v.lineCount()-1
+	start := len(v.Lines)
 	v.Lines = append(v.Lines, lines...)
+	v.parseANSI(start)
+	if v.MaxMemory > 0 {
+		for _, line := range lines {
+			v.memory += int64(len(line) + lineOverhead)
+		}
+	}
+	v.recordRate(start)
+	v.checkWatches(start)
+	v.extendView(start)
+	v.trimLines()
 	if v.Follow && atEnd {
-		v.Cursor = len(v.Lines) - 1
+		v.Cursor = v.lineCount() - 1
 		v.CursorCol = 0
 		v.GoalCol = 0
 		v.FollowAuto = true
@@ -1362,61 +1871,87 @@ func padRight(s string, width int) string {
 	if width <= 0 {
 		return s
 	}
-	if len(stripANSI(s)) >= width {
+	visible := visibleWidth(s)
+	if visible >= width {
 		return truncateANSI(s, width)
 	}
-	return s + strings.Repeat(" ", width-len(stripANSI(s)))
+	return s + strings.Repeat(" ", width-visible)
 }
 
 func truncateANSI(s string, width int) string {
 	if width <= 0 {
 		return ""
 	}
-	plain := stripANSI(s)
-	if len(plain) <= width {
+	if visibleWidth(s) <= width {
 		return s
 	}
 	var out strings.Builder
 	count := 0
-	inEscape := false
-	for i := 0; i < len(s); i++ {
-		ch := s[i]
-		if ch == '\x1b' {
-			inEscape = true
+	linked := false
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			n := escapeLen(s, i)
+			linked = linked || strings.HasPrefix(s[i:], "\x1b]8;")
+			out.WriteString(s[i : i+n])
+			i += n
+			continue
 		}
-		if !inEscape {
+		if utf8.RuneStart(s[i]) {
 			if count >= width {
 				break
 			}
 			count++
 		}
-		out.WriteByte(ch)
-		if inEscape && ch == 'm' {
-			inEscape = false
-		}
+		out.WriteByte(s[i])
+		i++
 	}
 	out.WriteString("\x1b[0m")
+	if linked {
+		// The hyperlink may have been cut before its end.
+		out.WriteString("\x1b]8;;\x1b\\")
+	}
 	return out.String()
 }
 
 func stripANSI(s string) string {
 	var out strings.Builder
-	inEscape := false
-	for i := 0; i < len(s); i++ {
-		ch := s[i]
-		if ch == '\x1b' {
-			inEscape = true
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			i += escapeLen(s, i)
 			continue
 		}
-		if inEscape {
-			if ch == 'm' {
-				inEscape = false
+		out.WriteByte(s[i])
+		i++
+	}
+	return out.String()
+}
+
+// escapeLen returns the length of the escape sequence starting at s[i]:
+// a CSI sequence such as a color, or an OSC such as a hyperlink, which
+// ends with BEL or ESC \.
+func escapeLen(s string, i int) int {
+	if i+1 >= len(s) {
+		return len(s) - i
+	}
+	switch s[i+1] {
+	case '[':
+		j := i + 2
+		for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+			j++
+		}
+		return min(j+1, len(s)) - i
+	case ']':
+		for j := i + 2; j < len(s); j++ {
+			if s[j] == '\a' {
+				return j + 1 - i
+			}
+			if s[j] == '\x1b' && j+1 < len(s) && s[j+1] == '\\' {
+				return j + 2 - i
 			}
-			continue
 		}
-		out.WriteByte(ch)
+		return len(s) - i
 	}
-	return out.String()
+	return 2
 }
 
 func visibleWidth(s string) int {
@@ -0,0 +1,52 @@
+package ui
+
+import "sort"
+
+// The view maps display rows to indexes in Lines. A nil View shows every
+// line; filters narrow it down without touching the underlying buffer.
+
+func (v *Viewer) lineCount() int {
+	if v.View == nil {
+		return len(v.Lines)
+	}
+	return len(v.View)
+}
+
+func (v *Viewer) lineIndex(i int) int {
+	if v.View == nil {
+		return i
+	}
+	if i < 0 || i >= len(v.View) {
+		return -1
+	}
+	return v.View[i]
+}
+
+func (v *Viewer) line(i int) string {
+	idx := v.lineIndex(i)
+	if idx < 0 || idx >= len(v.Lines) {
+		return ""
+	}
+	return v.Lines[idx]
+}
+
+// viewIndex returns the first view row showing line orig or a later one.
+// A sorted view is searched for orig itself.
+func (v *Viewer) viewIndex(orig int) int {
+	if v.View == nil {
+		return orig
+	}
+	if v.SortKey != SortNone {
+		for i, idx := range v.View {
+			if idx == orig {
+				return i
+			}
+		}
+		return 0
+	}
+	i := sort.SearchInts(v.View, orig)
+	if i >= len(v.View) && len(v.View) > 0 {
+		i = len(v.View) - 1
+	}
+	return i
+}
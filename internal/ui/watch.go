@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Watch is a pattern checked against every line that arrives while
+// following. A match rings the bell and flashes the status bar, and runs
+// Command if one is set.
+type Watch struct {
+	Pattern string
+	Regex   *regexp.Regexp
+	Notify  bool
+	Command string
+}
+
+// hookRun is a watch command waiting to run for a matching line.
+type hookRun struct {
+	watch  Watch
+	number int
+	line   string
+}
+
+const (
+	// hookQueue is how many watch commands may wait to run; matches past
+	// it are dropped rather than starting a process each.
+	hookQueue     = 64
+	flashDuration = 2 * time.Second
+	flashBG       = "\x1b[41m"
+	bell          = "\a"
+)
+
+// checkWatches alerts once on the first watched match among
+// Lines[start:] and queues the command of every match.
+func (v *Viewer) checkWatches(start int) {
+	alerted := false
+	dropped := 0
+	for i := start; i < len(v.Lines); i++ {
+		for _, w := range v.Watches {
+			if !w.Regex.MatchString(v.Lines[i]) {
+				continue
+			}
+			if !alerted {
+				v.alert(w, i)
+				alerted = true
+			}
+			if w.Command != "" && !v.queueHook(hookRun{w, v.lineNumber(i), v.Lines[i]}) {
+				dropped++
+			}
+		}
+	}
+	if dropped > 0 {
+		v.Status = fmt.Sprintf("watch: %d commands dropped, too many queued", dropped)
+	}
+}
+
+// queueHook hands run to the goroutine running watch commands one at a
+// time, starting it on first use. It reports false when the queue is full.
+func (v *Viewer) queueHook(run hookRun) bool {
+	if v.hooks == nil {
+		v.hooks = make(chan hookRun, hookQueue)
+		go v.runHooks(v.hooks)
+	}
+	select {
+	case v.hooks <- run:
+		return true
+	default:
+		return false
+	}
+}
+
+func (v *Viewer) runHooks(hooks <-chan hookRun) {
+	for run := range hooks {
+		if err := runHook(run); err != nil {
+			select {
+			case v.async <- func() {
+				v.Status = fmt.Sprintf("watch command failed: %v", err)
+			}:
+			default:
+			}
+		}
+	}
+}
+
+// runHook runs a watch command through the shell with the matching line on
+// stdin and in TILO_LINE. Its output is discarded.
+func runHook(run hookRun) error {
+	cmd := exec.Command("sh", "-c", run.watch.Command)
+	cmd.Stdin = strings.NewReader(run.line + "\n")
+	cmd.Env = append(os.Environ(),
+		"TILO_LINE="+run.line,
+		"TILO_LINE_NUMBER="+strconv.Itoa(run.number),
+		"TILO_PATTERN="+run.watch.Pattern,
+	)
+	return cmd.Run()
+}
+
+func (v *Viewer) alert(w Watch, idx int) {
+	fmt.Fprint(os.Stdout, bell)
+	v.flashUntil = time.Now().Add(flashDuration)
+	v.Status = fmt.Sprintf("watch %s matched line %d", w.Pattern, v.lineNumber(idx))
+	if w.Notify {
+		go notify("tilo: "+w.Pattern, v.Lines[idx])
+	}
+}
+
+func (v *Viewer) flashing() bool {
+	return time.Now().Before(v.flashUntil)
+}
+
+// addWatch watches for pattern from the command line.
+func (v *Viewer) addWatch(pattern string) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		names := make([]string, len(v.Watches))
+		for i, w := range v.Watches {
+			names[i] = w.Pattern
+		}
+		v.Status = "watching: " + strings.Join(names, ", ")
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		v.Status = fmt.Sprintf("invalid regex: %v", err)
+		return
+	}
+	v.Watches = append(v.Watches, Watch{Pattern: pattern, Regex: re})
+	v.Status = "watching " + pattern
+}
+
+// notify sends a desktop notification when a notifier is available.
+func notify(title, body string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		cmd = exec.Command("osascript", "-e", script)
+	default:
+		cmd = exec.Command("notify-send", title, body)
+	}
+	_ = cmd.Run()
+}